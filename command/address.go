@@ -29,14 +29,31 @@ var (
 	commonLabelStyle = lipgloss.NewStyle().
 				Foreground(lipgloss.Color("220")).
 				MarginRight(1)
+
+	validStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("42")).
+			Bold(true)
+
+	invalidStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("196")).
+			Bold(true)
+
+	warningStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("208")).
+			Bold(true)
 )
 
-func RunAddress(addressArk string) error {
+func RunAddress(addressArk string, opts ServerCheckOptions) error {
 	decoded, err := arklib.DecodeAddressV0(addressArk)
 	if err != nil {
 		return fmt.Errorf("failed to decode address: %w", err)
 	}
 
+	profile, err := loadProfile(opts.Profile)
+	if err != nil {
+		return err
+	}
+
 	var output string
 
 	// Address ARK
@@ -107,6 +124,10 @@ func RunAddress(addressArk string) error {
 		}
 	}
 
+	if profile != nil {
+		output += formatServerAddressChecks(decoded, profile)
+	}
+
 	fmt.Print(output)
 	return nil
 }
@@ -0,0 +1,503 @@
+package command
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	arklib "github.com/arkade-os/arkd/pkg/ark-lib"
+	"github.com/arkade-os/arkd/pkg/ark-lib/offchain"
+	"github.com/arkade-os/arkd/pkg/ark-lib/script"
+	"github.com/arkade-os/arkd/pkg/ark-lib/txutils"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+)
+
+// ArkTxBuildOptions describes the Ark transaction to build
+type ArkTxBuildOptions struct {
+	// Inputs are formatted as txid:vout,amount,taptree,leaf
+	Inputs []string
+	// Outputs are formatted as address:amount
+	Outputs []string
+	// UnrollScript is the hex encoded server unroll closure locking the checkpoint outputs
+	UnrollScript string
+}
+
+func RunArkTxBuild(opts ArkTxBuildOptions) error {
+	if len(opts.Inputs) == 0 {
+		return fmt.Errorf("at least one input is required")
+	}
+	if len(opts.Outputs) == 0 {
+		return fmt.Errorf("at least one output is required")
+	}
+
+	vtxos := make([]offchain.VtxoInput, 0, len(opts.Inputs))
+	for i, input := range opts.Inputs {
+		vtxo, err := parseVtxoInput(input)
+		if err != nil {
+			return fmt.Errorf("invalid input [%d]: %w", i, err)
+		}
+		vtxos = append(vtxos, *vtxo)
+	}
+
+	outputs := make([]*wire.TxOut, 0, len(opts.Outputs))
+	for i, out := range opts.Outputs {
+		txOut, onchain, err := parseOutput(out)
+		if err != nil {
+			return fmt.Errorf("invalid output [%d]: %w", i, err)
+		}
+		if onchain {
+			return fmt.Errorf("invalid output [%d]: ark transactions can only send to ark addresses", i)
+		}
+		outputs = append(outputs, txOut)
+	}
+
+	unrollScript, err := hex.DecodeString(opts.UnrollScript)
+	if err != nil {
+		return fmt.Errorf("failed to decode unroll script: %w", err)
+	}
+
+	arkTx, checkpointTxs, err := offchain.BuildTxs(vtxos, outputs, unrollScript)
+	if err != nil {
+		return fmt.Errorf("failed to build ark transaction: %w", err)
+	}
+
+	var output string
+
+	encodedArkTx, err := arkTx.B64Encode()
+	if err != nil {
+		return fmt.Errorf("failed to encode ark transaction: %w", err)
+	}
+	output += fmt.Sprintf("\n%s\n",
+		sectionStyle.Render("Ark Transaction:"),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("TxId:"),
+		valueStyle.Render(arkTx.UnsignedTx.TxID()),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("PSBT:"),
+		valueStyle.Render(encodedArkTx),
+	)
+
+	output += fmt.Sprintf("\n%s\n",
+		sectionStyle.Render(fmt.Sprintf("Checkpoint Transactions (%d):", len(checkpointTxs))),
+	)
+	for i, checkpointTx := range checkpointTxs {
+		encodedCheckpointTx, err := checkpointTx.B64Encode()
+		if err != nil {
+			return fmt.Errorf("failed to encode checkpoint transaction [%d]: %w", i, err)
+		}
+		output += fmt.Sprintf("%s\n",
+			subLabelStyle.Render(fmt.Sprintf("[%d]:", i)),
+		)
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render("  TxId:"),
+			valueStyle.Render(checkpointTx.UnsignedTx.TxID()),
+		)
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render("  PSBT:"),
+			valueStyle.Render(encodedCheckpointTx),
+		)
+	}
+
+	fmt.Print(output)
+	return nil
+}
+
+// parseVtxoInput parses an ark tx input formatted as txid:vout,amount,taptree,leaf.
+// The leaf is the hex encoded tapscript used to spend the vtxo, it must be part of the taptree.
+func parseVtxoInput(input string) (*offchain.VtxoInput, error) {
+	parts := strings.Split(input, ",")
+	if len(parts) != 4 {
+		return nil, fmt.Errorf("expected txid:vout,amount,taptree,leaf, got %q", input)
+	}
+
+	outpoint, err := parseOutpoint(parts[0])
+	if err != nil {
+		return nil, err
+	}
+
+	amount, err := parseAmount(parts[1])
+	if err != nil {
+		return nil, err
+	}
+
+	taptree, vtxoScript, err := parseTaptree(parts[2])
+	if err != nil {
+		return nil, err
+	}
+
+	leafScript, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode leaf: %w", err)
+	}
+
+	_, tapTree, err := vtxoScript.TapTree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute taptree: %w", err)
+	}
+
+	proof, err := tapTree.GetTaprootMerkleProof(txscript.NewBaseTapLeaf(leafScript).TapHash())
+	if err != nil {
+		return nil, fmt.Errorf("leaf not found in taptree: %w", err)
+	}
+
+	controlBlock, err := txscript.ParseControlBlock(proof.ControlBlock)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse control block: %w", err)
+	}
+
+	if _, err := script.DecodeClosure(leafScript); err != nil {
+		return nil, fmt.Errorf("failed to decode leaf closure: %w", err)
+	}
+
+	return &offchain.VtxoInput{
+		Outpoint: outpoint,
+		Amount:   amount,
+		Tapscript: &waddrmgr.Tapscript{
+			ControlBlock:   controlBlock,
+			RevealedScript: leafScript,
+		},
+		RevealedTapscripts: taptree,
+	}, nil
+}
+
+// ArkTxVerifyOptions describes the Ark transaction to verify
+type ArkTxVerifyOptions struct {
+	Checkpoints []string
+	// Signer is the hex encoded server public key, inferred from the checkpoint unroll closure if empty
+	Signer string
+	// Network is the ark network used to encode the new vtxo addresses
+	Network string
+}
+
+// arkTxVtxo is a vtxo created by an Ark transaction
+type arkTxVtxo struct {
+	Outpoint wire.OutPoint
+	Amount   int64
+	Address  string
+}
+
+func RunArkTxVerify(arkTxInput string, opts ArkTxVerifyOptions) error {
+	arkTxArg, err := readArg(arkTxInput)
+	if err != nil {
+		return fmt.Errorf("failed to read ark transaction: %w", err)
+	}
+	arkTx, err := parsePsbt(arkTxArg)
+	if err != nil {
+		return err
+	}
+
+	checkpointTxs := make([]*psbt.Packet, 0, len(opts.Checkpoints))
+	for i, checkpointInput := range opts.Checkpoints {
+		checkpointArg, err := readArg(checkpointInput)
+		if err != nil {
+			return fmt.Errorf("failed to read checkpoint [%d]: %w", i, err)
+		}
+		checkpointTx, err := parsePsbt(checkpointArg)
+		if err != nil {
+			return fmt.Errorf("checkpoint [%d]: %w", i, err)
+		}
+		checkpointTxs = append(checkpointTxs, checkpointTx)
+	}
+
+	network, err := parseArkNetwork(opts.Network)
+	if err != nil {
+		return err
+	}
+
+	var signer *btcec.PublicKey
+	signerSource := "--signer"
+	if opts.Signer != "" {
+		signer, err = parsePubKey(opts.Signer)
+		if err != nil {
+			return err
+		}
+	} else {
+		signer, err = inferArkTxSigner(arkTx)
+		if err != nil {
+			return fmt.Errorf("failed to infer signer, use --signer: %w", err)
+		}
+		signerSource = "checkpoint unroll closure"
+	}
+
+	var output string
+
+	output += fmt.Sprintf("\n%s\n",
+		sectionStyle.Render("Ark Transaction:"),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("TxId:"),
+		valueStyle.Render(arkTx.UnsignedTx.TxID()),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Signer:"),
+		valueStyle.Render(fmt.Sprintf("%x (%s)", schnorr.SerializePubKey(signer), signerSource)),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Checkpoints:"),
+		valueStyle.Render(fmt.Sprintf("%d", len(checkpointTxs))),
+	)
+
+	verifyErrs := verifyArkTx(arkTx, checkpointTxs, signer)
+	if len(verifyErrs) == 0 {
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render("Verification:"),
+			validStyle.Render("valid"),
+		)
+	} else {
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render("Verification:"),
+			invalidStyle.Render("invalid"),
+		)
+		for _, verifyErr := range verifyErrs {
+			output += fmt.Sprintf("%s%s\n",
+				subLabelStyle.Render("  -"),
+				invalidStyle.Render(verifyErr),
+			)
+		}
+	}
+
+	vtxos := arkTxVtxos(arkTx, signer, network.Addr)
+	output += fmt.Sprintf("\n%s\n",
+		sectionStyle.Render(fmt.Sprintf("New VTXOs (%d):", len(vtxos))),
+	)
+	for i, vtxo := range vtxos {
+		output += fmt.Sprintf("%s\n",
+			subLabelStyle.Render(fmt.Sprintf("[%d]:", i)),
+		)
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render("  Outpoint:"),
+			valueStyle.Render(vtxo.Outpoint.String()),
+		)
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render("  Amount:"),
+			valueStyle.Render(fmt.Sprintf("%d sats", vtxo.Amount)),
+		)
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render("  Address:"),
+			valueStyle.Render(vtxo.Address),
+		)
+	}
+
+	fmt.Print(output)
+	return nil
+}
+
+// verifyArkTx checks the Ark transaction spends the checkpoint outputs and that each checkpoint
+// spends its vtxo with a closure the signer is part of
+func verifyArkTx(arkTx *psbt.Packet, checkpointTxs []*psbt.Packet, signer *btcec.PublicKey) []string {
+	errs := make([]string, 0)
+
+	checkpointOutputs := make(map[wire.OutPoint]*wire.TxOut)
+	for i, checkpointTx := range checkpointTxs {
+		errs = append(errs, verifyCheckpointTx(i, checkpointTx, signer)...)
+
+		if len(checkpointTx.UnsignedTx.TxOut) > 0 {
+			outpoint := wire.OutPoint{Hash: checkpointTx.UnsignedTx.TxHash(), Index: 0}
+			checkpointOutputs[outpoint] = checkpointTx.UnsignedTx.TxOut[0]
+		}
+	}
+
+	var totalIn int64
+	spent := make(map[wire.OutPoint]bool)
+	for i, txIn := range arkTx.UnsignedTx.TxIn {
+		checkpointOutput, ok := checkpointOutputs[txIn.PreviousOutPoint]
+		if !ok {
+			errs = append(errs, fmt.Sprintf("ark tx input [%d]: %s is not a checkpoint output", i, txIn.PreviousOutPoint))
+			continue
+		}
+		spent[txIn.PreviousOutPoint] = true
+		totalIn += checkpointOutput.Value
+
+		prevout := inputPrevout(arkTx, i)
+		if prevout == nil {
+			errs = append(errs, fmt.Sprintf("ark tx input [%d]: missing prevout", i))
+		} else if prevout.Value != checkpointOutput.Value || !bytes.Equal(prevout.PkScript, checkpointOutput.PkScript) {
+			errs = append(errs, fmt.Sprintf("ark tx input [%d]: prevout does not match the checkpoint output", i))
+		}
+
+		for _, leafErr := range verifySpendingLeaf(arkTx, i, checkpointOutput.PkScript, signer) {
+			errs = append(errs, fmt.Sprintf("ark tx input [%d]: %s", i, leafErr))
+		}
+	}
+	for outpoint := range checkpointOutputs {
+		if !spent[outpoint] {
+			errs = append(errs, fmt.Sprintf("checkpoint output %s is not spent by the ark tx", outpoint))
+		}
+	}
+
+	var totalOut int64
+	hasAnchor := false
+	for _, txOut := range arkTx.UnsignedTx.TxOut {
+		if isAnchorOutput(txOut) {
+			hasAnchor = true
+			continue
+		}
+		totalOut += txOut.Value
+	}
+	if !hasAnchor {
+		errs = append(errs, "ark tx: missing anchor output")
+	}
+	if totalIn != totalOut {
+		errs = append(errs, fmt.Sprintf("ark tx: inputs (%d sats) and outputs (%d sats) do not balance", totalIn, totalOut))
+	}
+
+	return errs
+}
+
+// verifyCheckpointTx checks a checkpoint spends a single vtxo with one of its collaborative closures
+// and locks the same amount in its first output, followed by an anchor
+func verifyCheckpointTx(index int, checkpointTx *psbt.Packet, signer *btcec.PublicKey) []string {
+	errs := make([]string, 0)
+	prefix := fmt.Sprintf("checkpoint [%d]", index)
+	tx := checkpointTx.UnsignedTx
+
+	if len(tx.TxIn) != 1 {
+		return append(errs, fmt.Sprintf("%s: expected 1 input, got %d", prefix, len(tx.TxIn)))
+	}
+	if len(tx.TxOut) != 2 {
+		return append(errs, fmt.Sprintf("%s: expected 2 outputs, got %d", prefix, len(tx.TxOut)))
+	}
+	if !isAnchorOutput(tx.TxOut[1]) {
+		errs = append(errs, fmt.Sprintf("%s: missing anchor output", prefix))
+	}
+
+	prevout := inputPrevout(checkpointTx, 0)
+	if prevout == nil {
+		return append(errs, fmt.Sprintf("%s: missing vtxo prevout", prefix))
+	}
+	if prevout.Value != tx.TxOut[0].Value {
+		errs = append(errs, fmt.Sprintf("%s: vtxo amount (%d sats) does not match the checkpoint output (%d sats)", prefix, prevout.Value, tx.TxOut[0].Value))
+	}
+
+	for _, leafErr := range verifySpendingLeaf(checkpointTx, 0, prevout.PkScript, signer) {
+		errs = append(errs, fmt.Sprintf("%s: %s", prefix, leafErr))
+	}
+
+	return errs
+}
+
+// verifySpendingLeaf checks the input reveals the taptree of its prevout and spends it
+// with a leaf of that taptree requiring the signer signature
+func verifySpendingLeaf(p *psbt.Packet, inputIndex int, pkScript []byte, signer *btcec.PublicKey) []string {
+	errs := make([]string, 0)
+
+	vtxoScript, err := inputVtxoScript(p, inputIndex)
+	if err != nil {
+		return append(errs, err.Error())
+	}
+
+	taprootKey, tapTree, err := vtxoScript.TapTree()
+	if err != nil {
+		return append(errs, fmt.Sprintf("failed to compute taptree: %s", err))
+	}
+	expectedPkScript, err := script.P2TRScript(taprootKey)
+	if err != nil {
+		return append(errs, fmt.Sprintf("failed to compute taproot script: %s", err))
+	}
+	if !bytes.Equal(expectedPkScript, pkScript) {
+		errs = append(errs, "revealed taptree does not match the prevout script")
+	}
+
+	leaves := p.Inputs[inputIndex].TaprootLeafScript
+	if len(leaves) != 1 {
+		return append(errs, fmt.Sprintf("expected 1 tapscript leaf, got %d", len(leaves)))
+	}
+
+	leafHash := txscript.NewBaseTapLeaf(leaves[0].Script).TapHash()
+	if _, err := tapTree.GetTaprootMerkleProof(leafHash); err != nil {
+		errs = append(errs, "spending leaf is not part of the revealed taptree")
+	}
+
+	closure, err := script.DecodeClosure(leaves[0].Script)
+	if err != nil {
+		return append(errs, fmt.Sprintf("failed to decode spending leaf: %s", err))
+	}
+	if !containsPubKey(closurePubKeys(closure), signer) {
+		errs = append(errs, "spending leaf does not require the signer signature")
+	}
+
+	return errs
+}
+
+// inputVtxoScript parses the taptree revealed in the VtxoTaprootTree field of an input
+func inputVtxoScript(p *psbt.Packet, inputIndex int) (script.VtxoScript, error) {
+	trees, err := txutils.GetArkPsbtFields(p, inputIndex, txutils.VtxoTaprootTreeField)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read taptree field: %w", err)
+	}
+	if len(trees) == 0 {
+		return nil, fmt.Errorf("missing taptree field")
+	}
+
+	vtxoScript, err := script.ParseVtxoScript(trees[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse taptree field: %w", err)
+	}
+	return vtxoScript, nil
+}
+
+// inferArkTxSigner returns the key of the unroll closure revealed in the first Ark tx input,
+// ie. the first leaf of the checkpoint output taptree
+func inferArkTxSigner(arkTx *psbt.Packet) (*btcec.PublicKey, error) {
+	if len(arkTx.Inputs) == 0 {
+		return nil, fmt.Errorf("ark tx has no input")
+	}
+
+	trees, err := txutils.GetArkPsbtFields(arkTx, 0, txutils.VtxoTaprootTreeField)
+	if err != nil || len(trees) == 0 || len(trees[0]) == 0 {
+		return nil, fmt.Errorf("missing checkpoint taptree on ark tx input [0]")
+	}
+
+	unrollScript, err := hex.DecodeString(trees[0][0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid unroll closure: %w", err)
+	}
+
+	unrollClosure := &script.CSVMultisigClosure{}
+	valid, err := unrollClosure.Decode(unrollScript)
+	if err != nil || !valid || len(unrollClosure.PubKeys) != 1 {
+		return nil, fmt.Errorf("first checkpoint leaf is not a single key unroll closure")
+	}
+	return unrollClosure.PubKeys[0], nil
+}
+
+// arkTxVtxos lists the vtxos created by the Ark transaction, all outputs but the anchor
+func arkTxVtxos(arkTx *psbt.Packet, signer *btcec.PublicKey, hrp string) []arkTxVtxo {
+	txid := arkTx.UnsignedTx.TxHash()
+	vtxos := make([]arkTxVtxo, 0, len(arkTx.UnsignedTx.TxOut))
+
+	for i, txOut := range arkTx.UnsignedTx.TxOut {
+		if isAnchorOutput(txOut) {
+			continue
+		}
+
+		vtxo := arkTxVtxo{
+			Outpoint: wire.OutPoint{Hash: txid, Index: uint32(i)},
+			Amount:   txOut.Value,
+			Address:  fmt.Sprintf("script %x", txOut.PkScript),
+		}
+		if txscript.IsPayToTaproot(txOut.PkScript) {
+			if vtxoTapKey, err := schnorr.ParsePubKey(txOut.PkScript[2:]); err == nil {
+				address := &arklib.Address{
+					HRP:        hrp,
+					Signer:     signer,
+					VtxoTapKey: vtxoTapKey,
+				}
+				if encoded, err := address.EncodeV0(); err == nil {
+					vtxo.Address = encoded
+				}
+			}
+		}
+		vtxos = append(vtxos, vtxo)
+	}
+
+	return vtxos
+}
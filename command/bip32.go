@@ -0,0 +1,242 @@
+package command
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcutil/base58"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// globalXpub is a PSBT_GLOBAL_XPUB entry, the extended key with its origin
+type globalXpub struct {
+	Xpub        string
+	Fingerprint uint32
+	Path        []uint32
+}
+
+// parseGlobalXpubs reads the global xpubs, btcd keeps them as unknown global fields
+func parseGlobalXpubs(p *psbt.Packet) []globalXpub {
+	xpubs := make([]globalXpub, 0)
+	for _, unknown := range p.Unknowns {
+		if len(unknown.Key) != 1+78 || unknown.Key[0] != byte(psbt.XpubType) {
+			continue
+		}
+		if len(unknown.Value) < 4 || len(unknown.Value)%4 != 0 {
+			continue
+		}
+
+		// the serialized key is base58 encoded with its double sha256 checksum
+		serialized := unknown.Key[1:]
+		checksum := chainhash.DoubleHashB(serialized)[:4]
+		xpub := globalXpub{
+			Xpub:        base58.Encode(append(append([]byte{}, serialized...), checksum...)),
+			Fingerprint: binary.LittleEndian.Uint32(unknown.Value[:4]),
+		}
+		for i := 4; i < len(unknown.Value); i += 4 {
+			xpub.Path = append(xpub.Path, binary.LittleEndian.Uint32(unknown.Value[i:i+4]))
+		}
+		xpubs = append(xpubs, xpub)
+	}
+	return xpubs
+}
+
+// formatKeyOrigin formats a key origin as fingerprint/path, eg. d34db33f/86'/0'/0'
+func formatKeyOrigin(fingerprint uint32, path []uint32) string {
+	fpBytes := make([]byte, 4)
+	binary.BigEndian.PutUint32(fpBytes, fingerprint)
+	return hex.EncodeToString(fpBytes) + strings.TrimPrefix(formatBip32Path(path), "m")
+}
+
+// formatDescriptorKey formats a derived key as a descriptor key expression. If a global xpub
+// derives the key, it is used with the remaining path, eg. [d34db33f/86'/0'/0']xpub.../0/1,
+// otherwise the key is given with its full origin, eg. [d34db33f/86'/0'/0'/0/1]02...
+func formatDescriptorKey(fingerprint uint32, path []uint32, pubKey []byte, xpubs []globalXpub) string {
+	if len(path) == 0 && fingerprint == 0 {
+		return hex.EncodeToString(pubKey)
+	}
+
+	for _, xpub := range xpubs {
+		if xpub.Fingerprint != fingerprint || len(xpub.Path) > len(path) {
+			continue
+		}
+		if !pathHasPrefix(path, xpub.Path) || !xpubDerives(xpub.Xpub, path[len(xpub.Path):], pubKey) {
+			continue
+		}
+		return fmt.Sprintf("[%s]%s%s",
+			formatKeyOrigin(fingerprint, xpub.Path),
+			xpub.Xpub,
+			strings.TrimPrefix(formatBip32Path(path[len(xpub.Path):]), "m"),
+		)
+	}
+
+	return fmt.Sprintf("[%s]%s", formatKeyOrigin(fingerprint, path), hex.EncodeToString(pubKey))
+}
+
+func pathHasPrefix(path, prefix []uint32) bool {
+	for i, index := range prefix {
+		if path[i] != index {
+			return false
+		}
+	}
+	return true
+}
+
+// xpubDerives checks the xpub derives the public key, compressed or x-only, with the given unhardened path
+func xpubDerives(xpub string, path []uint32, pubKey []byte) bool {
+	key, err := hdkeychain.NewKeyFromString(xpub)
+	if err != nil {
+		return false
+	}
+	for _, index := range path {
+		if index >= hdkeychain.HardenedKeyStart {
+			return false
+		}
+		if key, err = key.Derive(index); err != nil {
+			return false
+		}
+	}
+	derived, err := key.ECPubKey()
+	if err != nil {
+		return false
+	}
+	compressed := derived.SerializeCompressed()
+	return bytes.Equal(compressed, pubKey) || bytes.Equal(compressed[1:], pubKey)
+}
+
+// wrapDescriptor wraps a key expression in the descriptor of a single key output script,
+// it returns an empty string for other scripts
+func wrapDescriptor(pkScript, redeemScript []byte, key string) string {
+	switch txscript.GetScriptClass(pkScript) {
+	case txscript.WitnessV0PubKeyHashTy:
+		return fmt.Sprintf("wpkh(%s)", key)
+	case txscript.PubKeyHashTy:
+		return fmt.Sprintf("pkh(%s)", key)
+	case txscript.ScriptHashTy:
+		if txscript.GetScriptClass(redeemScript) == txscript.WitnessV0PubKeyHashTy {
+			return fmt.Sprintf("sh(wpkh(%s))", key)
+		}
+	}
+	return ""
+}
+
+// formatBip32Derivations formats the BIP32 derivations of an input or output, with the descriptor
+// of the spent or created script when it is a single key one
+func formatBip32Derivations(derivations []*psbt.Bip32Derivation, pkScript, redeemScript []byte, xpubs []globalXpub) string {
+	var output string
+
+	output += fmt.Sprintf("%s\n",
+		subLabelStyle.Render("  Bip32Derivation:"),
+	)
+	for j, der := range derivations {
+		fpBytes := make([]byte, 4)
+		binary.BigEndian.PutUint32(fpBytes, der.MasterKeyFingerprint)
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render(fmt.Sprintf("    [%d] MasterFingerprint:", j)),
+			valueStyle.Render(hex.EncodeToString(fpBytes)),
+		)
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render(fmt.Sprintf("    [%d] Path:", j)),
+			valueStyle.Render(formatBip32Path(der.Bip32Path)),
+		)
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render(fmt.Sprintf("    [%d] PubKey:", j)),
+			valueStyle.Render(hex.EncodeToString(der.PubKey)),
+		)
+
+		key := formatDescriptorKey(der.MasterKeyFingerprint, der.Bip32Path, der.PubKey, xpubs)
+		if descriptor := wrapDescriptor(pkScript, redeemScript, key); descriptor != "" {
+			key = descriptor
+		}
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render(fmt.Sprintf("    [%d] Descriptor:", j)),
+			valueStyle.Render(key),
+		)
+	}
+
+	return output
+}
+
+// formatTaprootBip32Derivations formats the taproot BIP32 derivations grouped by spend path:
+// the internal key first, then the keys of each leaf, identified by its hash
+// and by its index in the input TaprootLeafScript field when known
+func formatTaprootBip32Derivations(derivations []*psbt.TaprootBip32Derivation, internalKey []byte, leafScripts []*psbt.TaprootTapLeafScript, xpubs []globalXpub) string {
+	var output string
+
+	output += fmt.Sprintf("%s\n",
+		subLabelStyle.Render("  TaprootBip32Derivation:"),
+	)
+
+	// without leaves, the output is a single key one
+	keyPathOnly := len(leafScripts) == 0
+	for _, der := range derivations {
+		if len(der.LeafHashes) > 0 {
+			keyPathOnly = false
+		}
+	}
+
+	leafHashes := make([]string, 0)
+	leafKeys := make(map[string][]string)
+	for _, der := range derivations {
+		key := formatDescriptorKey(der.MasterKeyFingerprint, der.Bip32Path, der.XOnlyPubKey, xpubs)
+
+		if len(der.LeafHashes) == 0 || bytes.Equal(der.XOnlyPubKey, internalKey) {
+			value := key
+			if keyPathOnly {
+				value = fmt.Sprintf("tr(%s)", key)
+			}
+			output += fmt.Sprintf("%s%s\n",
+				subLabelStyle.Render("    Key Path:"),
+				valueStyle.Render(value),
+			)
+		}
+
+		for _, leafHash := range der.LeafHashes {
+			hash := hex.EncodeToString(leafHash)
+			if _, ok := leafKeys[hash]; !ok {
+				leafHashes = append(leafHashes, hash)
+			}
+			leafKeys[hash] = append(leafKeys[hash], key)
+		}
+	}
+
+	for _, hash := range leafHashes {
+		label := fmt.Sprintf("    Leaf %s:", hash)
+		for j, leaf := range leafScripts {
+			tapHash := txscript.NewTapLeaf(leaf.LeafVersion, leaf.Script).TapHash()
+			if hex.EncodeToString(tapHash[:]) == hash {
+				label = fmt.Sprintf("    Leaf %s (TaprootLeafScript [%d]):", hash, j)
+				break
+			}
+		}
+		output += fmt.Sprintf("%s\n",
+			subLabelStyle.Render(label),
+		)
+		for _, key := range leafKeys[hash] {
+			output += fmt.Sprintf("%s%s\n",
+				subLabelStyle.Render("      -"),
+				valueStyle.Render(key),
+			)
+		}
+	}
+
+	return output
+}
+
+// inputPkScript returns the script spent by the input, nil if the PSBT has no prevout for it
+func inputPkScript(in psbt.PInput, txIn *wire.TxIn) []byte {
+	if in.WitnessUtxo != nil {
+		return in.WitnessUtxo.PkScript
+	}
+	if in.NonWitnessUtxo != nil && int(txIn.PreviousOutPoint.Index) < len(in.NonWitnessUtxo.TxOut) {
+		return in.NonWitnessUtxo.TxOut[txIn.PreviousOutPoint.Index].PkScript
+	}
+	return nil
+}
@@ -0,0 +1,245 @@
+package command
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/arkade-os/arkd/pkg/ark-lib/script"
+	"github.com/arkade-os/arkd/pkg/ark-lib/txutils"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	txTypeUnknown       = "Unknown"
+	txTypeCommitment    = "Commitment transaction"
+	txTypeVtxoTreeNode  = "VTXO tree node"
+	txTypeConnectorNode = "Connector tree node"
+	txTypeForfeit       = "Forfeit transaction"
+	txTypeCheckpoint    = "Checkpoint transaction"
+	txTypeArk           = "Ark transaction"
+	txTypeIntentProof   = "Intent proof"
+)
+
+// txClassification is the Ark specific shape detected for a PSBT
+type txClassification struct {
+	Type        string
+	Reason      string
+	InputRoles  []string
+	OutputRoles []string
+}
+
+// classifyPsbt recognises the Ark transaction type of a PSBT from its inputs, outputs,
+// P2A anchors and ARK PSBT fields, and labels the role of each input and output
+func classifyPsbt(p *psbt.Packet) txClassification {
+	tx := p.UnsignedTx
+	c := txClassification{
+		Type:        txTypeUnknown,
+		InputRoles:  make([]string, len(tx.TxIn)),
+		OutputRoles: make([]string, len(tx.TxOut)),
+	}
+
+	for i := range tx.TxIn {
+		c.InputRoles[i] = "input"
+	}
+	for i, txOut := range tx.TxOut {
+		c.OutputRoles[i] = defaultOutputRole(txOut)
+	}
+
+	hasAnchor := len(tx.TxOut) > 0 && isAnchorOutput(tx.TxOut[len(tx.TxOut)-1])
+	allInputsWithTaptree := len(tx.TxIn) > 0
+	for i := range tx.TxIn {
+		if !hasArkPsbtField(p, i, txutils.VtxoTaprootTreeField) {
+			allInputsWithTaptree = false
+		}
+	}
+
+	switch {
+	case isIntentProof(p):
+		c.Type = txTypeIntentProof
+		c.Reason = "first input spends a zero-value prevout locked by the same script as the second input (BIP322 toSpend)"
+		c.InputRoles[0] = "toSpend (message commitment)"
+		for i := 1; i < len(tx.TxIn); i++ {
+			c.InputRoles[i] = "proven coin"
+		}
+		for i, txOut := range tx.TxOut {
+			if bytes.Equal(txOut.PkScript, []byte{txscript.OP_RETURN}) {
+				c.OutputRoles[i] = "empty OP_RETURN (no outputs registered)"
+			} else {
+				c.OutputRoles[i] = "registered output"
+			}
+		}
+
+	case len(tx.TxIn) == 1 && hasAnchor && hasArkPsbtField(p, 0, txutils.CosignerPublicKeyField):
+		if hasArkPsbtField(p, 0, txutils.VtxoTreeExpiryField) {
+			c.Type = txTypeVtxoTreeNode
+			c.Reason = "single input with cosigner keys and tree expiry, P2A anchor"
+			c.InputRoles[0] = "batch output or parent node output"
+			c.setNonAnchorOutputRoles(tx, "child node or vtxo")
+		} else {
+			c.Type = txTypeConnectorNode
+			c.Reason = "single input with cosigner keys and no tree expiry, P2A anchor"
+			c.InputRoles[0] = "connector output or parent node output"
+			c.setNonAnchorOutputRoles(tx, "child node or connector")
+		}
+
+	case allInputsWithTaptree && hasAnchor:
+		if len(tx.TxIn) == 1 && len(tx.TxOut) == 2 && !spendsCheckpointOutput(p, 0) {
+			c.Type = txTypeCheckpoint
+			c.Reason = "single vtxo input with revealed taptree, single output and P2A anchor"
+			c.InputRoles[0] = "vtxo"
+			c.OutputRoles[0] = "checkpoint output"
+			break
+		}
+
+		c.Type = txTypeArk
+		c.Reason = "inputs with revealed taptree, P2A anchor"
+		for i := range tx.TxIn {
+			if spendsCheckpointOutput(p, i) {
+				c.InputRoles[i] = "checkpoint output"
+			} else {
+				c.InputRoles[i] = "vtxo"
+			}
+		}
+		for i, txOut := range tx.TxOut[:len(tx.TxOut)-1] {
+			if script.IsSubDustScript(txOut.PkScript) {
+				c.OutputRoles[i] = "sub-dust vtxo"
+			} else {
+				c.OutputRoles[i] = "vtxo"
+			}
+		}
+
+	case len(tx.TxIn) >= 2 && len(tx.TxOut) == 2 && hasAnchor:
+		c.Type = txTypeForfeit
+		c.Reason = "several inputs, single output and P2A anchor"
+		for i := range tx.TxIn {
+			if len(p.Inputs[i].TaprootLeafScript) > 0 {
+				c.InputRoles[i] = "vtxo"
+			} else {
+				c.InputRoles[i] = "connector"
+			}
+		}
+		c.OutputRoles[0] = "forfeit output (server)"
+
+	case !hasAnchor && len(tx.TxOut) > 0 && txscript.IsPayToTaproot(tx.TxOut[0].PkScript):
+		c.Type = txTypeCommitment
+		c.Reason = "no P2A anchor and taproot first output (heuristic)"
+		for i := range tx.TxIn {
+			if hasArkPsbtField(p, i, txutils.VtxoTaprootTreeField) {
+				c.InputRoles[i] = "boarding input"
+			} else {
+				c.InputRoles[i] = "server wallet input"
+			}
+		}
+		c.OutputRoles[0] = "batch output (vtxo tree root)"
+		if len(tx.TxOut) > 1 && txscript.IsPayToTaproot(tx.TxOut[1].PkScript) {
+			c.OutputRoles[1] = "connector output"
+		}
+	}
+
+	return c
+}
+
+func (c *txClassification) setNonAnchorOutputRoles(tx *wire.MsgTx, role string) {
+	for i, txOut := range tx.TxOut {
+		if !isAnchorOutput(txOut) {
+			c.OutputRoles[i] = role
+		}
+	}
+}
+
+func defaultOutputRole(txOut *wire.TxOut) string {
+	switch {
+	case isAnchorOutput(txOut):
+		return "P2A anchor"
+	case len(txOut.PkScript) > 0 && txOut.PkScript[0] == txscript.OP_RETURN:
+		return "OP_RETURN"
+	default:
+		return "output"
+	}
+}
+
+// isAnchorOutput returns true if the output is a P2A anchor
+func isAnchorOutput(txOut *wire.TxOut) bool {
+	return bytes.Equal(txOut.PkScript, txutils.ANCHOR_PKSCRIPT)
+}
+
+// hasArkPsbtField returns true if the given input carries at least one field of the given type
+func hasArkPsbtField[T any](p *psbt.Packet, inputIndex int, coder txutils.ArkPsbtFieldCoder[T]) bool {
+	fields, err := txutils.GetArkPsbtFields(p, inputIndex, coder)
+	return err == nil && len(fields) > 0
+}
+
+// isIntentProof detects the BIP322-like structure of intent proofs: the first input spends
+// the toSpend tx whose only output is locked by the pkscript of the second input
+func isIntentProof(p *psbt.Packet) bool {
+	if len(p.Inputs) < 2 || len(p.UnsignedTx.TxOut) == 0 {
+		return false
+	}
+
+	first, second := p.Inputs[0].WitnessUtxo, p.Inputs[1].WitnessUtxo
+	if first == nil || second == nil {
+		return false
+	}
+
+	return first.Value == 0 &&
+		p.UnsignedTx.TxIn[0].PreviousOutPoint.Index == 0 &&
+		bytes.Equal(first.PkScript, second.PkScript)
+}
+
+// spendsCheckpointOutput returns true if the input spends the collaborative leaf of a checkpoint
+// output, ie. a taptree made of the server unroll closure followed by the owner's closure.
+// Unlike the default vtxo script, where the exit key is the owner (first key of the collaborative
+// closure), the unroll key of a checkpoint is the server key which comes after the owner's.
+func spendsCheckpointOutput(p *psbt.Packet, inputIndex int) bool {
+	trees, err := txutils.GetArkPsbtFields(p, inputIndex, txutils.VtxoTaprootTreeField)
+	if err != nil || len(trees) == 0 || len(trees[0]) != 2 {
+		return false
+	}
+
+	leaves := p.Inputs[inputIndex].TaprootLeafScript
+	if len(leaves) == 0 || hex.EncodeToString(leaves[0].Script) != trees[0][1] {
+		return false
+	}
+
+	unrollScript, err := hex.DecodeString(trees[0][0])
+	if err != nil {
+		return false
+	}
+
+	unrollClosure := &script.CSVMultisigClosure{}
+	valid, err := unrollClosure.Decode(unrollScript)
+	if err != nil || !valid || len(unrollClosure.PubKeys) != 1 {
+		return false
+	}
+
+	collaborativeClosure, err := script.DecodeClosure(leaves[0].Script)
+	if err != nil {
+		return false
+	}
+
+	pubKeys := closurePubKeys(collaborativeClosure)
+	return len(pubKeys) > 1 &&
+		!containsPubKey(pubKeys[:1], unrollClosure.PubKeys[0]) &&
+		containsPubKey(pubKeys, unrollClosure.PubKeys[0])
+}
+
+// formatClassification formats the detected transaction type
+func formatClassification(c txClassification) string {
+	var output string
+
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Type:"),
+		valueStyle.Render(c.Type),
+	)
+	if c.Reason != "" {
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render("Detected by:"),
+			valueStyle.Render(c.Reason),
+		)
+	}
+
+	return output
+}
@@ -0,0 +1,14 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassifyPsbtUnknown(t *testing.T) {
+	c := classifyPsbt(newSpendingPsbt(t, []byte{0x51}))
+	require.Equal(t, txTypeUnknown, c.Type)
+	require.Equal(t, []string{"input"}, c.InputRoles)
+	require.Equal(t, []string{"output"}, c.OutputRoles)
+}
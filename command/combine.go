@@ -0,0 +1,276 @@
+package command
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+const (
+	// maxDiffValueLen is the number of bytes of a field value displayed by psbt diff
+	maxDiffValueLen = 40
+	// diffContextLen is the number of bytes displayed before the first difference of two values
+	diffContextLen = 8
+)
+
+// psbtFieldChange is a field of a PSBT map added or modified by another PSBT
+type psbtFieldChange struct {
+	// Location is "global", "input [i]" or "output [i]"
+	Location string
+	Field    string
+	A        []byte
+	B        []byte
+}
+
+func RunPsbtCombine(psbtInputs []string) error {
+	if len(psbtInputs) < 2 {
+		return fmt.Errorf("at least two psbts are required")
+	}
+
+	raws := make([]*rawPsbt, 0, len(psbtInputs))
+	var txid string
+	for i, psbtInput := range psbtInputs {
+		content, err := readArg(psbtInput)
+		if err != nil {
+			return fmt.Errorf("failed to read psbt [%d]: %w", i, err)
+		}
+		p, err := parsePsbt(content)
+		if err != nil {
+			return fmt.Errorf("psbt [%d]: %w", i, err)
+		}
+		if i == 0 {
+			txid = p.UnsignedTx.TxID()
+		} else if p.UnsignedTx.TxID() != txid {
+			return fmt.Errorf("psbt [%d] is a different transaction: %s, expected %s", i, p.UnsignedTx.TxID(), txid)
+		}
+
+		raw, err := newRawPsbt(p)
+		if err != nil {
+			return fmt.Errorf("psbt [%d]: %w", i, err)
+		}
+		raws = append(raws, raw)
+	}
+
+	combined, added, err := combineRawPsbts(raws)
+	if err != nil {
+		return err
+	}
+
+	p, err := combined.packet()
+	if err != nil {
+		return fmt.Errorf("failed to parse combined psbt: %w", err)
+	}
+	encoded, err := p.B64Encode()
+	if err != nil {
+		return fmt.Errorf("failed to encode psbt: %w", err)
+	}
+
+	var output string
+
+	output += fmt.Sprintf("\n%s\n",
+		sectionStyle.Render(fmt.Sprintf("Merged Fields (%d):", len(added))),
+	)
+	for _, field := range added {
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render("-"),
+			valueStyle.Render(field),
+		)
+	}
+	output += fmt.Sprintf("\n%s\n%s\n",
+		sectionStyle.Render("PSBT:"),
+		valueStyle.Render(encoded),
+	)
+
+	fmt.Print(output)
+	return nil
+}
+
+// combineRawPsbts merges the fields of the PSBTs of the same transaction into the first one.
+// It returns the fields added to the first PSBT, and fails if a field has different values.
+func combineRawPsbts(raws []*rawPsbt) (*rawPsbt, []string, error) {
+	combined := raws[0]
+	added := make([]string, 0)
+	conflicts := make([]string, 0)
+	for i, raw := range raws[1:] {
+		source := i + 1
+		merge := func(scope psbtScope, location string, base *[]psbtKeyValue, entries []psbtKeyValue) {
+			for _, entry := range entries {
+				name := psbtFieldName(scope, entry.Key)
+				index := findPsbtKey(*base, entry.Key)
+				if index < 0 {
+					*base = append(*base, entry)
+					added = append(added, fmt.Sprintf("%s %s from psbt [%d]", location, name, source))
+					continue
+				}
+				if !bytes.Equal((*base)[index].Value, entry.Value) {
+					conflicts = append(conflicts, fmt.Sprintf("%s %s: psbt [%d] has a different value", location, name, source))
+				}
+			}
+		}
+
+		merge(psbtScopeGlobal, "global", &combined.Global, raw.Global)
+		for j := range raw.Inputs {
+			merge(psbtScopeInput, fmt.Sprintf("input [%d]", j), &combined.Inputs[j], raw.Inputs[j])
+		}
+		for j := range raw.Outputs {
+			merge(psbtScopeOutput, fmt.Sprintf("output [%d]", j), &combined.Outputs[j], raw.Outputs[j])
+		}
+	}
+
+	if len(conflicts) > 0 {
+		return nil, nil, fmt.Errorf("conflicting fields:\n  %s", strings.Join(conflicts, "\n  "))
+	}
+	return combined, added, nil
+}
+
+func RunPsbtDiff(psbtInputA, psbtInputB string) error {
+	raws := make([]*rawPsbt, 0, 2)
+	for _, psbtInput := range []string{psbtInputA, psbtInputB} {
+		content, err := readArg(psbtInput)
+		if err != nil {
+			return fmt.Errorf("failed to read psbt: %w", err)
+		}
+		p, err := parsePsbt(content)
+		if err != nil {
+			return err
+		}
+		raw, err := newRawPsbt(p)
+		if err != nil {
+			return err
+		}
+		raws = append(raws, raw)
+	}
+	changes := diffRawPsbts(raws[0], raws[1])
+
+	var output string
+
+	output += fmt.Sprintf("\n%s\n",
+		sectionStyle.Render(fmt.Sprintf("Differences (%d):", len(changes))),
+	)
+	if len(changes) == 0 {
+		output += fmt.Sprintf("%s\n",
+			validStyle.Render("    identical"),
+		)
+	}
+
+	location := ""
+	for _, change := range changes {
+		if change.Location != location {
+			location = change.Location
+			output += fmt.Sprintf("%s\n",
+				subLabelStyle.Render(location+":"),
+			)
+		}
+
+		switch {
+		case change.A == nil:
+			output += fmt.Sprintf("%s%s\n",
+				subLabelStyle.Render("  + "+change.Field+":"),
+				validStyle.Render(formatDiffValue(change.B, 0)),
+			)
+		case change.B == nil:
+			output += fmt.Sprintf("%s%s\n",
+				subLabelStyle.Render("  - "+change.Field+":"),
+				invalidStyle.Render(formatDiffValue(change.A, 0)),
+			)
+		default:
+			offset := firstDiffOffset(change.A, change.B)
+			output += fmt.Sprintf("%s%s\n",
+				subLabelStyle.Render("  ~ "+change.Field+":"),
+				valueStyle.Render(fmt.Sprintf("differs at byte %d", offset)),
+			)
+			output += fmt.Sprintf("%s%s\n",
+				subLabelStyle.Render("      a:"),
+				warningStyle.Render(formatDiffValue(change.A, offset)),
+			)
+			output += fmt.Sprintf("%s%s\n",
+				subLabelStyle.Render("      b:"),
+				warningStyle.Render(formatDiffValue(change.B, offset)),
+			)
+		}
+	}
+
+	fmt.Print(output)
+	return nil
+}
+
+// diffRawPsbts lists the fields removed, added or modified from a to b, map by map
+func diffRawPsbts(a, b *rawPsbt) []psbtFieldChange {
+	changes := diffPsbtMaps(psbtScopeGlobal, "global", a.Global, b.Global)
+	for i := 0; i < max(len(a.Inputs), len(b.Inputs)); i++ {
+		changes = append(changes, diffPsbtMaps(psbtScopeInput, fmt.Sprintf("input [%d]", i), mapAt(a.Inputs, i), mapAt(b.Inputs, i))...)
+	}
+	for i := 0; i < max(len(a.Outputs), len(b.Outputs)); i++ {
+		changes = append(changes, diffPsbtMaps(psbtScopeOutput, fmt.Sprintf("output [%d]", i), mapAt(a.Outputs, i), mapAt(b.Outputs, i))...)
+	}
+	return changes
+}
+
+// diffPsbtMaps lists the fields removed, added or modified from a to b
+func diffPsbtMaps(scope psbtScope, location string, a, b []psbtKeyValue) []psbtFieldChange {
+	changes := make([]psbtFieldChange, 0)
+
+	for _, entry := range a {
+		change := psbtFieldChange{Location: location, Field: psbtFieldName(scope, entry.Key), A: entry.Value}
+		index := findPsbtKey(b, entry.Key)
+		if index < 0 {
+			changes = append(changes, change)
+			continue
+		}
+		if !bytes.Equal(b[index].Value, entry.Value) {
+			change.B = b[index].Value
+			changes = append(changes, change)
+		}
+	}
+
+	for _, entry := range b {
+		if findPsbtKey(a, entry.Key) < 0 {
+			changes = append(changes, psbtFieldChange{Location: location, Field: psbtFieldName(scope, entry.Key), B: entry.Value})
+		}
+	}
+
+	return changes
+}
+
+// mapAt returns the i-th map, empty if the PSBT has fewer inputs or outputs
+func mapAt(maps [][]psbtKeyValue, i int) []psbtKeyValue {
+	if i < len(maps) {
+		return maps[i]
+	}
+	return nil
+}
+
+// firstDiffOffset returns the offset of the first byte differing between a and b,
+// the length of the shortest one if it is a prefix of the other
+func firstDiffOffset(a, b []byte) int {
+	n := min(len(a), len(b))
+	for i := 0; i < n; i++ {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	return n
+}
+
+// formatDiffValue formats a field value, long ones such as transactions being truncated
+// to a window starting a few bytes before the given offset
+func formatDiffValue(value []byte, offset int) string {
+	if len(value) == 0 {
+		return "<empty>"
+	}
+	if len(value) <= maxDiffValueLen {
+		return hex.EncodeToString(value)
+	}
+
+	start := max(0, min(offset-diffContextLen, len(value)-maxDiffValueLen))
+	end := start + maxDiffValueLen
+	formatted := hex.EncodeToString(value[start:end])
+	if start > 0 {
+		formatted = "..." + formatted
+	}
+	if end < len(value) {
+		formatted += "..."
+	}
+	return fmt.Sprintf("%s (%d bytes)", formatted, len(value))
+}
@@ -0,0 +1,138 @@
+package command
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/arkade-os/arkd/pkg/ark-lib/script"
+	"github.com/arkade-os/arkd/pkg/ark-lib/txutils"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/require"
+)
+
+// copyPsbt returns a deep copy of the packet, as another party would hold it
+func copyPsbt(t *testing.T, p *psbt.Packet) *psbt.Packet {
+	t.Helper()
+
+	var buf bytes.Buffer
+	require.NoError(t, p.Serialize(&buf))
+	copied, err := psbt.NewFromRawBytes(&buf, false)
+	require.NoError(t, err)
+	return copied
+}
+
+func newRawPsbts(t *testing.T, packets ...*psbt.Packet) []*rawPsbt {
+	t.Helper()
+
+	raws := make([]*rawPsbt, 0, len(packets))
+	for _, p := range packets {
+		raw, err := newRawPsbt(p)
+		require.NoError(t, err)
+		raws = append(raws, raw)
+	}
+	return raws
+}
+
+func TestCombineRawPsbts(t *testing.T) {
+	alice := newPrivKey(t)
+	bob := newPrivKey(t)
+	closure := &script.MultisigClosure{PubKeys: []*btcec.PublicKey{alice.PubKey(), bob.PubKey()}}
+	leafScript, err := closure.Script()
+	require.NoError(t, err)
+
+	base := newScriptSpendPsbt(t, leafScript)
+	signedByAlice := copyPsbt(t, base)
+	signScriptSpend(t, signedByAlice, alice, txscript.SigHashDefault, txscript.SigHashDefault)
+	signedByBob := copyPsbt(t, base)
+	signScriptSpend(t, signedByBob, bob, txscript.SigHashDefault, txscript.SigHashDefault)
+	// unknown fields are merged like the others
+	err = txutils.SetArkPsbtField(signedByBob, 0, txutils.CosignerPublicKeyField, txutils.IndexedCosignerPublicKey{
+		Index:     0,
+		PublicKey: bob.PubKey(),
+	})
+	require.NoError(t, err)
+
+	combined, added, err := combineRawPsbts(newRawPsbts(t, signedByAlice, signedByBob))
+	require.NoError(t, err)
+	require.Len(t, added, 2)
+	bobSig := signedByBob.Inputs[0].TaprootScriptSpendSig[0]
+	require.Equal(t, fmt.Sprintf("input [0] TaprootScriptSpendSig %x%x from psbt [1]", bobSig.XOnlyPubKey, bobSig.LeafHash), added[0])
+	require.Equal(t, "input [0] CosignerPublicKey 00000000 from psbt [1]", added[1])
+
+	p, err := combined.packet()
+	require.NoError(t, err)
+	require.Len(t, p.Inputs[0].TaprootScriptSpendSig, 2)
+	checks := verifyInputSignatures(p, 0)
+	require.Len(t, checks, 2)
+	for _, check := range checks {
+		require.Equal(t, sigStatusValid, check.Status, check.Reason)
+	}
+	cosigners, err := txutils.GetArkPsbtFields(p, 0, txutils.CosignerPublicKeyField)
+	require.NoError(t, err)
+	require.Len(t, cosigners, 1)
+
+	// the same field with another value is a conflict
+	otherPrevout := copyPsbt(t, base)
+	otherPrevout.Inputs[0].WitnessUtxo = &wire.TxOut{Value: 1, PkScript: base.Inputs[0].WitnessUtxo.PkScript}
+	_, _, err = combineRawPsbts(newRawPsbts(t, base, signedByAlice, otherPrevout))
+	require.EqualError(t, err, "conflicting fields:\n  input [0] WitnessUtxo: psbt [2] has a different value")
+}
+
+func TestDiffRawPsbts(t *testing.T) {
+	key := newPrivKey(t)
+	pkScript, err := txscript.PayToTaprootScript(txscript.ComputeTaprootKeyNoScript(key.PubKey()))
+	require.NoError(t, err)
+
+	a := newSpendingPsbt(t, pkScript)
+	a.Inputs[0].TaprootInternalKey = schnorr.SerializePubKey(key.PubKey())
+	b := copyPsbt(t, a)
+	b.UnsignedTx.LockTime = 100
+	b.Inputs[0].TaprootInternalKey = nil
+	b.Inputs[0].TaprootKeySpendSig = make([]byte, 64)
+
+	raws := newRawPsbts(t, a, b)
+	changes := diffRawPsbts(raws[0], raws[1])
+	require.Len(t, changes, 3)
+	require.Equal(t, "global", changes[0].Location)
+	require.Equal(t, "UnsignedTx", changes[0].Field)
+	require.Equal(t, psbtFieldChange{
+		Location: "input [0]",
+		Field:    "TaprootInternalKey",
+		A:        schnorr.SerializePubKey(key.PubKey()),
+	}, changes[1])
+	require.Equal(t, psbtFieldChange{Location: "input [0]", Field: "TaprootKeySpendSig", B: make([]byte, 64)}, changes[2])
+
+	// the txs only differ by their locktime, past the first bytes displayed
+	offset := firstDiffOffset(changes[0].A, changes[0].B)
+	require.Equal(t, len(changes[0].A)-4, offset)
+	require.NotEqual(t, formatDiffValue(changes[0].A, offset), formatDiffValue(changes[0].B, offset))
+}
+
+func TestFormatDiffValue(t *testing.T) {
+	require.Equal(t, "<empty>", formatDiffValue(nil, 0))
+	require.Equal(t, "0102", formatDiffValue([]byte{1, 2}, 0))
+
+	a := bytes.Repeat([]byte{0xaa}, 100)
+	b := bytes.Clone(a)
+	b[60] = 0xbb
+	offset := firstDiffOffset(a, b)
+	require.Equal(t, 60, offset)
+
+	// the window starts a few bytes before the difference
+	window := hex.EncodeToString(a[52:92])
+	require.Equal(t, "..."+window+"... (100 bytes)", formatDiffValue(a, offset))
+	require.Equal(t, fmt.Sprintf("...%sbb%s... (100 bytes)", hex.EncodeToString(a[52:60]), hex.EncodeToString(a[61:92])), formatDiffValue(b, offset))
+
+	// values differing near their end show their last bytes
+	require.Equal(t, "..."+hex.EncodeToString(a[60:])+" (100 bytes)", formatDiffValue(a, 99))
+	require.Equal(t, hex.EncodeToString(a[:40])+"... (100 bytes)", formatDiffValue(a, 0))
+
+	// a value prefix of the other differs at its end
+	require.Equal(t, 50, firstDiffOffset(a[:50], a))
+}
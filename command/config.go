@@ -0,0 +1,101 @@
+package command
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// DefaultProfile is the config profile used by noa server info when none is given
+const DefaultProfile = "default"
+
+// noaConfig is the noa config file, holding the server info cached by noa server info
+type noaConfig struct {
+	Profiles map[string]*serverProfile `json:"profiles"`
+}
+
+// serverProfile is the info of an arkd server, cached to validate scripts and addresses against it
+type serverProfile struct {
+	// Name is the key of the profile in the config file
+	Name string `json:"-"`
+	// Server is the base URL the info was fetched from
+	Server string `json:"server"`
+	// FetchedAt is the unix time of the last fetch
+	FetchedAt int64      `json:"fetchedAt"`
+	Info      serverInfo `json:"info"`
+}
+
+// configPath returns the path of the config file, in the user config directory
+func configPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate config directory: %w", err)
+	}
+	return filepath.Join(dir, "noa", "config.json"), nil
+}
+
+// loadConfig reads the config file, an empty config is returned if it does not exist yet
+func loadConfig() (*noaConfig, error) {
+	config := &noaConfig{Profiles: make(map[string]*serverProfile)}
+
+	path, err := configPath()
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return config, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+	if err := json.Unmarshal(content, config); err != nil {
+		return nil, fmt.Errorf("failed to decode config %s: %w", path, err)
+	}
+	if config.Profiles == nil {
+		config.Profiles = make(map[string]*serverProfile)
+	}
+	for name, profile := range config.Profiles {
+		profile.Name = name
+	}
+	return config, nil
+}
+
+// saveConfig writes the config file, creating its directory if needed
+func saveConfig(config *noaConfig) (string, error) {
+	path, err := configPath()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return "", fmt.Errorf("failed to create config directory: %w", err)
+	}
+	content, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode config: %w", err)
+	}
+	if err := os.WriteFile(path, append(content, '\n'), 0o600); err != nil {
+		return "", fmt.Errorf("failed to write config: %w", err)
+	}
+	return path, nil
+}
+
+// loadProfile returns the named profile. Without name, the default profile is returned
+// if noa server info cached it, nil otherwise.
+func loadProfile(name string) (*serverProfile, error) {
+	config, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		return config.Profiles[DefaultProfile], nil
+	}
+	profile, ok := config.Profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("profile %q not found, fetch it with noa server info --server <url> --profile %s", name, name)
+	}
+	return profile, nil
+}
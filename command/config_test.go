@@ -0,0 +1,31 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadProfile(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	// nothing is checked before server info caches the default profile
+	profile, err := loadProfile("")
+	require.NoError(t, err)
+	require.Nil(t, profile)
+	_, err = loadProfile("testnet")
+	require.ErrorContains(t, err, `profile "testnet" not found`)
+
+	config, err := loadConfig()
+	require.NoError(t, err)
+	config.Profiles[DefaultProfile] = &serverProfile{Server: "http://localhost:7070"}
+	_, err = saveConfig(config)
+	require.NoError(t, err)
+
+	profile, err = loadProfile("")
+	require.NoError(t, err)
+	require.NotNil(t, profile)
+	require.Equal(t, DefaultProfile, profile.Name)
+	require.Equal(t, "http://localhost:7070", profile.Server)
+}
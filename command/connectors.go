@@ -0,0 +1,194 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// ConnectorsDecodeOptions describes the forfeit txs to match against the connector tree
+type ConnectorsDecodeOptions struct {
+	Forfeits []string
+}
+
+// connector is an output of a connector tree leaf, spent by a forfeit tx
+type connector struct {
+	Outpoint wire.OutPoint
+	Amount   int64
+	// Forfeit is the index of the forfeit tx spending the connector, -1 if unused
+	Forfeit int
+}
+
+// forfeitMatch relates a forfeit tx to the connector and vtxos it spends
+type forfeitMatch struct {
+	Packet *psbt.Packet
+	// Connector is the index of the spent connector, -1 if none of the inputs is a connector
+	Connector int
+	Vtxos     []wire.OutPoint
+	Errors    []string
+}
+
+func RunConnectorsDecode(inputs []string, opts ConnectorsDecodeOptions) error {
+	txTree, err := parseTxTree(inputs)
+	if err != nil {
+		return err
+	}
+
+	forfeits := make([]*psbt.Packet, 0, len(opts.Forfeits))
+	for _, forfeitInput := range opts.Forfeits {
+		content, err := readArg(forfeitInput)
+		if err != nil {
+			return fmt.Errorf("failed to read forfeit: %w", err)
+		}
+		// a file may hold several forfeits, one per line
+		for _, encoded := range strings.Fields(content) {
+			p, err := parsePsbt(encoded)
+			if err != nil {
+				return fmt.Errorf("forfeit [%d]: %w", len(forfeits), err)
+			}
+			forfeits = append(forfeits, p)
+		}
+	}
+
+	connectors := make([]connector, 0)
+	for _, leaf := range orderedLeaves(txTree) {
+		for i, txOut := range leaf.UnsignedTx.TxOut {
+			if isAnchorOutput(txOut) {
+				continue
+			}
+			connectors = append(connectors, connector{
+				Outpoint: wire.OutPoint{Hash: leaf.UnsignedTx.TxHash(), Index: uint32(i)},
+				Amount:   txOut.Value,
+				Forfeit:  -1,
+			})
+		}
+	}
+
+	matches := matchForfeits(forfeits, connectors)
+
+	var output string
+
+	output += fmt.Sprintf("\n%s\n",
+		sectionStyle.Render("Connector Tree:"),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Root:"),
+		valueStyle.Render(txTree.Root.UnsignedTx.TxID()),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Nodes:"),
+		valueStyle.Render(fmt.Sprintf("%d", countTxTreeNodes(txTree))),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Leaves:"),
+		valueStyle.Render(fmt.Sprintf("%d", len(txTree.Leaves()))),
+	)
+	output += fmt.Sprintf("\n%s\n\n",
+		sectionStyle.Render("Nodes:"),
+	)
+	output += renderTxTree(txTree, "").String() + "\n"
+
+	output += fmt.Sprintf("\n%s\n",
+		sectionStyle.Render(fmt.Sprintf("Connectors (%d):", len(connectors))),
+	)
+	for i, c := range connectors {
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render(fmt.Sprintf("[%d]:", i)),
+			valueStyle.Render(fmt.Sprintf("%s (%d sats)", c.Outpoint, c.Amount)),
+		)
+		if len(forfeits) == 0 {
+			continue
+		}
+		if c.Forfeit < 0 {
+			output += fmt.Sprintf("%s%s\n",
+				subLabelStyle.Render("  Forfeit:"),
+				warningStyle.Render("unused"),
+			)
+		} else {
+			output += fmt.Sprintf("%s%s\n",
+				subLabelStyle.Render("  Forfeit:"),
+				valueStyle.Render(fmt.Sprintf("[%d] %s", c.Forfeit, forfeits[c.Forfeit].UnsignedTx.TxID())),
+			)
+		}
+	}
+
+	if len(forfeits) > 0 {
+		output += fmt.Sprintf("\n%s\n",
+			sectionStyle.Render(fmt.Sprintf("Forfeits (%d):", len(forfeits))),
+		)
+		for i, match := range matches {
+			output += fmt.Sprintf("%s%s\n",
+				subLabelStyle.Render(fmt.Sprintf("[%d]:", i)),
+				valueStyle.Render(match.Packet.UnsignedTx.TxID()),
+			)
+			if match.Connector >= 0 {
+				output += fmt.Sprintf("%s%s\n",
+					subLabelStyle.Render("  Connector:"),
+					valueStyle.Render(fmt.Sprintf("[%d] %s", match.Connector, connectors[match.Connector].Outpoint)),
+				)
+			}
+			for _, vtxo := range match.Vtxos {
+				output += fmt.Sprintf("%s%s\n",
+					subLabelStyle.Render("  VTXO:"),
+					valueStyle.Render(vtxo.String()),
+				)
+			}
+			for _, matchErr := range match.Errors {
+				output += fmt.Sprintf("%s%s\n",
+					subLabelStyle.Render("  -"),
+					invalidStyle.Render(matchErr),
+				)
+			}
+		}
+	}
+
+	fmt.Print(output)
+	return nil
+}
+
+// matchForfeits finds the connector spent by each forfeit, the other inputs being the forfeited vtxos.
+// The connectors are updated with the index of the forfeit spending them.
+func matchForfeits(forfeits []*psbt.Packet, connectors []connector) []forfeitMatch {
+	byOutpoint := make(map[wire.OutPoint]int, len(connectors))
+	for i, c := range connectors {
+		byOutpoint[c.Outpoint] = i
+	}
+
+	matches := make([]forfeitMatch, 0, len(forfeits))
+	for i, p := range forfeits {
+		match := forfeitMatch{Packet: p, Connector: -1}
+
+		for _, txIn := range p.UnsignedTx.TxIn {
+			connectorIndex, ok := byOutpoint[txIn.PreviousOutPoint]
+			if !ok {
+				match.Vtxos = append(match.Vtxos, txIn.PreviousOutPoint)
+				continue
+			}
+
+			if match.Connector >= 0 {
+				match.Errors = append(match.Errors, fmt.Sprintf("spends several connectors, %s is unexpected", txIn.PreviousOutPoint))
+				continue
+			}
+			match.Connector = connectorIndex
+
+			if previous := connectors[connectorIndex].Forfeit; previous >= 0 {
+				match.Errors = append(match.Errors, fmt.Sprintf("connector already spent by forfeit [%d]", previous))
+				continue
+			}
+			connectors[connectorIndex].Forfeit = i
+		}
+
+		if match.Connector < 0 {
+			match.Errors = append(match.Errors, "none of the inputs is a connector of the tree, it points at a non-existent connector")
+		}
+		if len(match.Vtxos) == 0 {
+			match.Errors = append(match.Errors, "does not spend any vtxo")
+		}
+
+		matches = append(matches, match)
+	}
+
+	return matches
+}
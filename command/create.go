@@ -0,0 +1,189 @@
+package command
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/arkade-os/arkd/pkg/ark-lib/script"
+	"github.com/arkade-os/arkd/pkg/ark-lib/txutils"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// PsbtCreateOptions describes the transaction to create
+type PsbtCreateOptions struct {
+	// Inputs are formatted as txid:vout:amount:pkscript[:sequence], the pkscript may be an address
+	Inputs []string
+	// Outputs are formatted as destination:amount, see parseDestination
+	Outputs []string
+	// Taptrees attach taproot data to inputs, formatted as index:taptree[:leaf]
+	Taptrees []string
+	LockTime uint32
+	Version  int32
+}
+
+func RunPsbtCreate(opts PsbtCreateOptions) error {
+	if len(opts.Inputs) == 0 {
+		return fmt.Errorf("at least one input is required")
+	}
+	if len(opts.Outputs) == 0 {
+		return fmt.Errorf("at least one output is required")
+	}
+
+	tx := wire.NewMsgTx(opts.Version)
+	tx.LockTime = opts.LockTime
+
+	prevouts := make([]*wire.TxOut, 0, len(opts.Inputs))
+	for i, input := range opts.Inputs {
+		txIn, prevout, err := parseCreateInput(input, opts.LockTime)
+		if err != nil {
+			return fmt.Errorf("invalid input [%d]: %w", i, err)
+		}
+		tx.AddTxIn(txIn)
+		prevouts = append(prevouts, prevout)
+	}
+
+	for i, out := range opts.Outputs {
+		txOut, _, err := parseOutput(out)
+		if err != nil {
+			return fmt.Errorf("invalid output [%d]: %w", i, err)
+		}
+		tx.AddTxOut(txOut)
+	}
+
+	p, err := psbt.NewFromUnsignedTx(tx)
+	if err != nil {
+		return fmt.Errorf("failed to create psbt: %w", err)
+	}
+	for i, prevout := range prevouts {
+		p.Inputs[i].WitnessUtxo = prevout
+	}
+
+	for i, taptree := range opts.Taptrees {
+		if err := attachTaptree(p, taptree); err != nil {
+			return fmt.Errorf("invalid taptree [%d]: %w", i, err)
+		}
+	}
+
+	encoded, err := p.B64Encode()
+	if err != nil {
+		return fmt.Errorf("failed to encode psbt: %w", err)
+	}
+
+	var output string
+
+	output += fmt.Sprintf("\n%s\n",
+		sectionStyle.Render("Transaction:"),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("TxId:"),
+		valueStyle.Render(tx.TxID()),
+	)
+	output += formatPsbtSummary(summarizePsbt(p))
+	output += fmt.Sprintf("\n%s\n%s\n",
+		sectionStyle.Render("PSBT:"),
+		valueStyle.Render(encoded),
+	)
+
+	fmt.Print(output)
+	return nil
+}
+
+// parseCreateInput parses an input formatted as txid:vout:amount:pkscript[:sequence].
+// Without sequence, the input is final unless a locktime is set, which requires a non final input.
+func parseCreateInput(input string, lockTime uint32) (*wire.TxIn, *wire.TxOut, error) {
+	parts := strings.Split(input, ":")
+	if len(parts) != 4 && len(parts) != 5 {
+		return nil, nil, fmt.Errorf("expected txid:vout:amount:pkscript[:sequence], got %q", input)
+	}
+
+	outpoint, err := parseOutpoint(parts[0] + ":" + parts[1])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	amount, err := parseAmount(parts[2])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pkScript, _, err := parseDestination(parts[3])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sequence := uint32(wire.MaxTxInSequenceNum)
+	if lockTime > 0 {
+		sequence = wire.MaxTxInSequenceNum - 1
+	}
+	if len(parts) == 5 {
+		value, err := strconv.ParseUint(parts[4], 10, 32)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid sequence %q: %w", parts[4], err)
+		}
+		sequence = uint32(value)
+	}
+
+	txIn := wire.NewTxIn(outpoint, nil, nil)
+	txIn.Sequence = sequence
+	return txIn, &wire.TxOut{Value: amount, PkScript: pkScript}, nil
+}
+
+// attachTaptree sets the VtxoTaprootTree field of an input formatted as index:taptree[:leaf],
+// and the TaprootLeafScript of the leaf if given. The taptree must match the input script.
+func attachTaptree(p *psbt.Packet, attachment string) error {
+	parts := strings.Split(attachment, ":")
+	if len(parts) != 2 && len(parts) != 3 {
+		return fmt.Errorf("expected index:taptree[:leaf]")
+	}
+
+	inputIndex, err := strconv.Atoi(parts[0])
+	if err != nil || inputIndex < 0 || inputIndex >= len(p.Inputs) {
+		return fmt.Errorf("invalid input index %q", parts[0])
+	}
+
+	taptree, vtxoScript, err := parseTaptree(parts[1])
+	if err != nil {
+		return err
+	}
+
+	tapKey, tapTree, err := vtxoScript.TapTree()
+	if err != nil {
+		return fmt.Errorf("failed to compute taptree: %w", err)
+	}
+	pkScript, err := script.P2TRScript(tapKey)
+	if err != nil {
+		return fmt.Errorf("failed to create pk script: %w", err)
+	}
+	if !bytes.Equal(pkScript, p.Inputs[inputIndex].WitnessUtxo.PkScript) {
+		return fmt.Errorf("taptree does not match the script of input [%d]", inputIndex)
+	}
+
+	if err := setArkPsbtField(p, inputIndex, txutils.VtxoTaprootTreeField, taptree); err != nil {
+		return err
+	}
+
+	if len(parts) == 2 {
+		return nil
+	}
+
+	leafScript, err := hex.DecodeString(parts[2])
+	if err != nil {
+		return fmt.Errorf("failed to decode leaf: %w", err)
+	}
+	proof, err := tapTree.GetTaprootMerkleProof(txscript.NewBaseTapLeaf(leafScript).TapHash())
+	if err != nil {
+		return fmt.Errorf("leaf not found in taptree: %w", err)
+	}
+
+	p.Inputs[inputIndex].TaprootLeafScript = append(p.Inputs[inputIndex].TaprootLeafScript, &psbt.TaprootTapLeafScript{
+		ControlBlock: proof.ControlBlock,
+		Script:       proof.Script,
+		LeafVersion:  txscript.BaseLeafVersion,
+	})
+	return nil
+}
@@ -0,0 +1,41 @@
+package command
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCreateInput(t *testing.T) {
+	txid := chainhash.Hash{1}.String()
+	input := txid + ":1:10000:0014" + strings.Repeat("00", 20)
+
+	// without sequence, the input is final
+	txIn, prevout, err := parseCreateInput(input, 0)
+	require.NoError(t, err)
+	require.Equal(t, wire.OutPoint{Hash: chainhash.Hash{1}, Index: 1}, txIn.PreviousOutPoint)
+	require.Equal(t, uint32(wire.MaxTxInSequenceNum), txIn.Sequence)
+	require.Equal(t, int64(10_000), prevout.Value)
+	require.Len(t, prevout.PkScript, 22)
+
+	// unless a locktime is set, which a final input would disable
+	txIn, _, err = parseCreateInput(input, 800_000)
+	require.NoError(t, err)
+	require.Equal(t, uint32(wire.MaxTxInSequenceNum-1), txIn.Sequence)
+
+	// an explicit sequence is kept as is
+	txIn, _, err = parseCreateInput(input+":144", 800_000)
+	require.NoError(t, err)
+	require.Equal(t, uint32(144), txIn.Sequence)
+	txIn, _, err = parseCreateInput(input+":4294967295", 800_000)
+	require.NoError(t, err)
+	require.Equal(t, uint32(wire.MaxTxInSequenceNum), txIn.Sequence)
+
+	_, _, err = parseCreateInput(input+":4294967296", 0)
+	require.ErrorContains(t, err, `invalid sequence "4294967296"`)
+	_, _, err = parseCreateInput(txid+":1:10000", 0)
+	require.ErrorContains(t, err, "expected txid:vout:amount:pkscript[:sequence]")
+}
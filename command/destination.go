@@ -0,0 +1,126 @@
+package command
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	arklib "github.com/arkade-os/arkd/pkg/ark-lib"
+	"github.com/arkade-os/arkd/pkg/ark-lib/txutils"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// onchainNetworks lists the chain params tried when decoding an onchain address
+var onchainNetworks = []*chaincfg.Params{
+	&chaincfg.MainNetParams,
+	&chaincfg.TestNet3Params,
+	&chaincfg.SigNetParams,
+	&chaincfg.RegressionNetParams,
+}
+
+// parseDestination resolves an Ark address, an onchain address, a P2A anchor ("p2a" or "anchor")
+// or a hex encoded raw script to its output script, the boolean is true if the destination is onchain
+func parseDestination(destination string) ([]byte, bool, error) {
+	if arkAddress, err := arklib.DecodeAddressV0(destination); err == nil {
+		pkScript, err := arkAddress.GetPkScript()
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to get ark address script: %w", err)
+		}
+		return pkScript, false, nil
+	}
+
+	if strings.EqualFold(destination, "p2a") || strings.EqualFold(destination, "anchor") {
+		return txutils.ANCHOR_PKSCRIPT, true, nil
+	}
+
+	address, err := decodeOnchainAddress(destination)
+	if err != nil {
+		if pkScript, err := hex.DecodeString(destination); err == nil && len(pkScript) > 0 {
+			return pkScript, true, nil
+		}
+		return nil, false, fmt.Errorf("invalid destination %q: not an ark address, onchain address, anchor or hex script", destination)
+	}
+
+	pkScript, err := txscript.PayToAddrScript(address)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get onchain address script: %w", err)
+	}
+	return pkScript, true, nil
+}
+
+// decodeOnchainAddress decodes a bitcoin address of any supported network
+func decodeOnchainAddress(address string) (btcutil.Address, error) {
+	for _, params := range onchainNetworks {
+		decoded, err := btcutil.DecodeAddress(address, params)
+		if err == nil && decoded.IsForNet(params) {
+			return decoded, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid onchain address %q", address)
+}
+
+// parseOutput parses an output formatted as address:amount,
+// the boolean is true if the address is onchain
+func parseOutput(s string) (*wire.TxOut, bool, error) {
+	separator := strings.LastIndex(s, ":")
+	if separator < 0 {
+		return nil, false, fmt.Errorf("expected address:amount, got %q", s)
+	}
+
+	pkScript, onchain, err := parseDestination(s[:separator])
+	if err != nil {
+		return nil, false, err
+	}
+
+	amount, err := parseAmount(s[separator+1:])
+	if err != nil {
+		return nil, false, err
+	}
+
+	return &wire.TxOut{Value: amount, PkScript: pkScript}, onchain, nil
+}
+
+// arkNetworks lists the ark networks selectable by name
+var arkNetworks = []arklib.Network{
+	arklib.Bitcoin,
+	arklib.BitcoinTestNet,
+	arklib.BitcoinTestNet4,
+	arklib.BitcoinSigNet,
+	arklib.BitcoinMutinyNet,
+	arklib.BitcoinRegTest,
+}
+
+// parseArkNetwork returns the ark network with the given name
+func parseArkNetwork(name string) (*arklib.Network, error) {
+	for _, network := range arkNetworks {
+		if network.Name == name {
+			return &network, nil
+		}
+	}
+
+	names := make([]string, 0, len(arkNetworks))
+	for _, network := range arkNetworks {
+		names = append(names, network.Name)
+	}
+	return nil, fmt.Errorf("unknown network %q, expected one of %s", name, strings.Join(names, ", "))
+}
+
+// onchainParams returns the chain params of the ark network, used to encode onchain addresses and WIFs
+func onchainParams(network *arklib.Network) *chaincfg.Params {
+	switch network.Name {
+	case arklib.Bitcoin.Name:
+		return &chaincfg.MainNetParams
+	case arklib.BitcoinSigNet.Name:
+		return &chaincfg.SigNetParams
+	case arklib.BitcoinMutinyNet.Name:
+		return &arklib.MutinyNetSigNetParams
+	case arklib.BitcoinRegTest.Name:
+		return &chaincfg.RegressionNetParams
+	default:
+		// testnet4 shares the testnet3 address and key prefixes
+		return &chaincfg.TestNet3Params
+	}
+}
@@ -0,0 +1,506 @@
+package command
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	arklib "github.com/arkade-os/arkd/pkg/ark-lib"
+	"github.com/arkade-os/arkd/pkg/ark-lib/script"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// EsploraOptions selects the Esplora API used to add onchain context to the decoded transactions
+type EsploraOptions struct {
+	// URL is the base URL of the Esplora API, eg. https://mempool.space/api, empty to stay offline
+	URL string
+}
+
+// errEsploraNotFound is returned for unknown transactions and blocks
+var errEsploraNotFound = errors.New("not found")
+
+// esploraClient queries the Esplora REST API
+type esploraClient struct {
+	baseURL string
+	http    *http.Client
+}
+
+type esploraStatus struct {
+	Confirmed   bool   `json:"confirmed"`
+	BlockHeight int64  `json:"block_height,omitempty"`
+	BlockHash   string `json:"block_hash,omitempty"`
+	BlockTime   int64  `json:"block_time,omitempty"`
+}
+
+type esploraVout struct {
+	ScriptPubKey string `json:"scriptpubkey"`
+	Value        int64  `json:"value"`
+}
+
+type esploraTx struct {
+	Txid     string        `json:"txid"`
+	Version  int32         `json:"version"`
+	Locktime uint32        `json:"locktime"`
+	Vout     []esploraVout `json:"vout"`
+	Status   esploraStatus `json:"status"`
+}
+
+type esploraBlock struct {
+	ID                string `json:"id"`
+	Height            int64  `json:"height"`
+	Timestamp         int64  `json:"timestamp"`
+	MedianTime        int64  `json:"mediantime"`
+	PreviousBlockHash string `json:"previousblockhash,omitempty"`
+}
+
+func newEsploraClient(rawURL string) (*esploraClient, error) {
+	baseURL, err := parseBaseURL(rawURL, "--esplora")
+	if err != nil {
+		return nil, err
+	}
+
+	return &esploraClient{
+		baseURL: baseURL,
+		http:    &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// get sends a GET request and returns the response body
+func (c *esploraClient) get(path string) ([]byte, error) {
+	resp, err := c.http.Get(c.baseURL + path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach esplora: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read esplora response: %w", err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return body, nil
+	case http.StatusNotFound:
+		return nil, errEsploraNotFound
+	default:
+		return nil, fmt.Errorf("esplora error (%s): %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+}
+
+func (c *esploraClient) getJSON(path string, out any) error {
+	body, err := c.get(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to decode esplora response: %w", err)
+	}
+	return nil
+}
+
+func (c *esploraClient) tx(txid string) (*esploraTx, error) {
+	var tx esploraTx
+	if err := c.getJSON("/tx/"+url.PathEscape(txid), &tx); err != nil {
+		return nil, err
+	}
+	return &tx, nil
+}
+
+// rawTx fetches the serialized transaction, needed as NonWitnessUtxo of legacy inputs
+func (c *esploraClient) rawTx(txid string) (*wire.MsgTx, error) {
+	body, err := c.get("/tx/" + url.PathEscape(txid) + "/hex")
+	if err != nil {
+		return nil, err
+	}
+	txBytes, err := hex.DecodeString(strings.TrimSpace(string(body)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid tx %s: %w", txid, err)
+	}
+	var tx wire.MsgTx
+	if err := tx.Deserialize(bytes.NewReader(txBytes)); err != nil {
+		return nil, fmt.Errorf("invalid tx %s: %w", txid, err)
+	}
+	if tx.TxID() != txid {
+		return nil, fmt.Errorf("esplora returned tx %s, expected %s", tx.TxID(), txid)
+	}
+	return &tx, nil
+}
+
+func (c *esploraClient) block(hash string) (*esploraBlock, error) {
+	var block esploraBlock
+	if err := c.getJSON("/block/"+url.PathEscape(hash), &block); err != nil {
+		return nil, err
+	}
+	return &block, nil
+}
+
+func (c *esploraClient) tip() (*esploraBlock, error) {
+	hash, err := c.get("/blocks/tip/hash")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tip: %w", err)
+	}
+	return c.block(strings.TrimSpace(string(hash)))
+}
+
+// onchainContext resolves prevouts and confirmations, caching the fetched transactions and blocks
+type onchainContext struct {
+	client *esploraClient
+	tip    *esploraBlock
+	txs    map[string]*esploraTx
+	blocks map[string]*esploraBlock
+}
+
+// newOnchainContext returns nil without Esplora URL, the onchain context is not displayed then
+func newOnchainContext(opts EsploraOptions) (*onchainContext, error) {
+	if opts.URL == "" {
+		return nil, nil
+	}
+
+	client, err := newEsploraClient(opts.URL)
+	if err != nil {
+		return nil, err
+	}
+	tip, err := client.tip()
+	if err != nil {
+		return nil, err
+	}
+
+	return &onchainContext{
+		client: client,
+		tip:    tip,
+		txs:    make(map[string]*esploraTx),
+		blocks: make(map[string]*esploraBlock),
+	}, nil
+}
+
+// tx fetches a transaction, nil if esplora doesn't know it
+func (o *onchainContext) tx(txid string) (*esploraTx, error) {
+	if tx, ok := o.txs[txid]; ok {
+		return tx, nil
+	}
+	tx, err := o.client.tx(txid)
+	if err != nil && !errors.Is(err, errEsploraNotFound) {
+		return nil, err
+	}
+	o.txs[txid] = tx
+	return tx, nil
+}
+
+func (o *onchainContext) block(hash string) (*esploraBlock, error) {
+	if block, ok := o.blocks[hash]; ok {
+		return block, nil
+	}
+	block, err := o.client.block(hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get block %s: %w", hash, err)
+	}
+	o.blocks[hash] = block
+	return block, nil
+}
+
+// prevout returns the spent output and the status of its transaction, nil if unknown
+func (o *onchainContext) prevout(outpoint wire.OutPoint) (*wire.TxOut, *esploraStatus, error) {
+	tx, err := o.tx(outpoint.Hash.String())
+	if err != nil || tx == nil {
+		return nil, nil, err
+	}
+	if int(outpoint.Index) >= len(tx.Vout) {
+		return nil, nil, fmt.Errorf("%s has no output %d", outpoint.Hash, outpoint.Index)
+	}
+
+	vout := tx.Vout[outpoint.Index]
+	pkScript, err := hex.DecodeString(vout.ScriptPubKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid script of %s: %w", outpoint, err)
+	}
+	return &wire.TxOut{Value: vout.Value, PkScript: pkScript}, &tx.Status, nil
+}
+
+// fillPrevouts sets the missing WitnessUtxo of the inputs spending a witness program, and the
+// missing NonWitnessUtxo of legacy inputs. It returns the name of the field filled by input.
+func (o *onchainContext) fillPrevouts(p *psbt.Packet) (map[int]string, error) {
+	filled := make(map[int]string)
+	for i, txIn := range p.UnsignedTx.TxIn {
+		if p.Inputs[i].WitnessUtxo != nil || p.Inputs[i].NonWitnessUtxo != nil {
+			continue
+		}
+		prevout, _, err := o.prevout(txIn.PreviousOutPoint)
+		if err != nil {
+			return nil, fmt.Errorf("input [%d]: %w", i, err)
+		}
+		if prevout == nil {
+			continue
+		}
+
+		if txscript.IsWitnessProgram(prevout.PkScript) {
+			p.Inputs[i].WitnessUtxo = prevout
+			filled[i] = "WitnessUtxo"
+			continue
+		}
+		prevTx, err := o.client.rawTx(txIn.PreviousOutPoint.Hash.String())
+		if err != nil {
+			return nil, fmt.Errorf("input [%d]: %w", i, err)
+		}
+		p.Inputs[i].NonWitnessUtxo = prevTx
+		filled[i] = "NonWitnessUtxo"
+	}
+	return filled, nil
+}
+
+// formatOnchainContext formats the confirmation of the transaction and of its prevouts, and whether
+// its locktime, the sequence locks of its inputs and the timelocks of the leaves they reveal
+// (leafScripts, by input) are satisfied by a spend in the next block
+func (o *onchainContext) formatOnchainContext(tx *wire.MsgTx, leafScripts map[int][][]byte, filled map[int]string) (string, error) {
+	var output string
+
+	output += fmt.Sprintf("\n%s\n",
+		sectionStyle.Render("Onchain (esplora):"),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Tip:"),
+		valueStyle.Render(fmt.Sprintf("height %d, median time %s", o.tip.Height, formatTimestamp(o.tip.MedianTime))),
+	)
+
+	self, err := o.tx(tx.TxID())
+	if err != nil {
+		return "", err
+	}
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Status:"),
+		o.formatConfirmation(self),
+	)
+
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("LockTime:"),
+		o.formatLockTimeStatus(tx),
+	)
+
+	for i, txIn := range tx.TxIn {
+		output += fmt.Sprintf("%s\n",
+			subLabelStyle.Render(fmt.Sprintf("[%d]:", i)),
+		)
+
+		prevout, status, err := o.prevout(txIn.PreviousOutPoint)
+		if err != nil {
+			return "", fmt.Errorf("input [%d]: %w", i, err)
+		}
+		if prevout == nil {
+			output += fmt.Sprintf("%s%s\n",
+				subLabelStyle.Render("  Prevout:"),
+				warningStyle.Render("not found"),
+			)
+			continue
+		}
+
+		source := ""
+		if field, ok := filled[i]; ok {
+			source = ", set as " + field
+		}
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render("  Prevout:"),
+			valueStyle.Render(fmt.Sprintf("%d sats, %x%s", prevout.Value, prevout.PkScript, source)),
+		)
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render("  Confirmation:"),
+			o.formatConfirmation(o.txs[txIn.PreviousOutPoint.Hash.String()]),
+		)
+
+		if tx.Version >= 2 && txIn.Sequence&wire.SequenceLockTimeDisabled == 0 {
+			lock := sequenceLocktime(txIn.Sequence)
+			lockStatus, err := o.relativeLockStatus(lock, status)
+			if err != nil {
+				return "", err
+			}
+			output += fmt.Sprintf("%s%s\n",
+				subLabelStyle.Render("  Sequence Lock:"),
+				valueStyle.Render(formatLocktimeDuration(lock)+", ")+lockStatus,
+			)
+		}
+
+		for j, leafScript := range leafScripts[i] {
+			closure, err := script.DecodeClosure(leafScript)
+			if err != nil {
+				continue
+			}
+			var lockDesc, lockStatus string
+			switch c := closure.(type) {
+			case *script.CSVMultisigClosure:
+				lockDesc = "CSV " + formatLocktimeDuration(c.Locktime)
+				lockStatus, err = o.relativeLockStatus(c.Locktime, status)
+			case *script.ConditionCSVMultisigClosure:
+				lockDesc = "CSV " + formatLocktimeDuration(c.Locktime)
+				lockStatus, err = o.relativeLockStatus(c.Locktime, status)
+			case *script.CLTVMultisigClosure:
+				lockDesc = "CLTV " + formatLocktimeValue(uint32(c.Locktime))
+				lockStatus = o.absoluteLockStatus(uint32(c.Locktime))
+			default:
+				continue
+			}
+			if err != nil {
+				return "", err
+			}
+			output += fmt.Sprintf("%s%s\n",
+				subLabelStyle.Render(fmt.Sprintf("  Leaf [%d] Timelock:", j)),
+				valueStyle.Render(lockDesc+", ")+lockStatus,
+			)
+		}
+	}
+
+	return output, nil
+}
+
+// formatFee formats the fee and fee rate of a signed transaction, empty if a prevout is unknown
+func (o *onchainContext) formatFee(tx *wire.MsgTx) (string, error) {
+	var totalIn, totalOut int64
+	for _, txIn := range tx.TxIn {
+		prevout, _, err := o.prevout(txIn.PreviousOutPoint)
+		if err != nil || prevout == nil {
+			return "", err
+		}
+		totalIn += prevout.Value
+	}
+	for _, txOut := range tx.TxOut {
+		totalOut += txOut.Value
+	}
+
+	fee := totalIn - totalOut
+	return fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Fee:"),
+		valueStyle.Render(fmt.Sprintf("%d sats (%.2f sat/vB)", fee, float64(fee)/float64(txVSize(tx)))),
+	), nil
+}
+
+// formatConfirmation formats the confirmation status of a transaction, nil meaning unknown to esplora
+func (o *onchainContext) formatConfirmation(tx *esploraTx) string {
+	switch {
+	case tx == nil:
+		return warningStyle.Render("not found")
+	case !tx.Status.Confirmed:
+		return warningStyle.Render("unconfirmed, in mempool")
+	default:
+		return validStyle.Render(fmt.Sprintf("confirmed at height %d (%d confirmations)",
+			tx.Status.BlockHeight, o.tip.Height-tx.Status.BlockHeight+1))
+	}
+}
+
+// formatLockTimeStatus reports whether the locktime of the transaction allows it in the next block
+func (o *onchainContext) formatLockTimeStatus(tx *wire.MsgTx) string {
+	if tx.LockTime == 0 {
+		return valueStyle.Render("none")
+	}
+
+	enforced := false
+	for _, txIn := range tx.TxIn {
+		if txIn.Sequence != wire.MaxTxInSequenceNum {
+			enforced = true
+		}
+	}
+	if !enforced {
+		return valueStyle.Render(formatLocktimeValue(tx.LockTime) + ", not enforced (all inputs are final)")
+	}
+	return valueStyle.Render(formatLocktimeValue(tx.LockTime)+", ") + o.absoluteLockStatus(tx.LockTime)
+}
+
+// absoluteLockStatus reports whether a locktime is satisfied by the next block
+func (o *onchainContext) absoluteLockStatus(lock uint32) string {
+	if arklib.AbsoluteLocktime(lock).IsSeconds() {
+		if int64(lock) < o.tip.MedianTime {
+			return validStyle.Render("satisfied")
+		}
+		return warningStyle.Render(fmt.Sprintf("not satisfied, %s left", time.Duration(int64(lock)-o.tip.MedianTime)*time.Second))
+	}
+
+	if int64(lock) <= o.tip.Height {
+		return validStyle.Render("satisfied")
+	}
+	return warningStyle.Render(fmt.Sprintf("not satisfied, %d blocks left", int64(lock)-o.tip.Height))
+}
+
+// relativeLockStatus reports whether a relative locktime from the confirmation of the prevout
+// is satisfied by the next block (BIP68: time locks start at the median time of the block
+// before the one confirming the prevout)
+func (o *onchainContext) relativeLockStatus(lock arklib.RelativeLocktime, status *esploraStatus) (string, error) {
+	if lock.Value == 0 {
+		return validStyle.Render("satisfied"), nil
+	}
+	if status == nil || !status.Confirmed {
+		return warningStyle.Render("not satisfied, the prevout is unconfirmed"), nil
+	}
+
+	if lock.Type == arklib.LocktimeTypeBlock {
+		age := o.tip.Height + 1 - status.BlockHeight
+		if age >= int64(lock.Value) {
+			return validStyle.Render("satisfied"), nil
+		}
+		return warningStyle.Render(fmt.Sprintf("not satisfied, %d blocks left", int64(lock.Value)-age)), nil
+	}
+
+	confirmingBlock, err := o.block(status.BlockHash)
+	if err != nil {
+		return "", err
+	}
+	startTime := confirmingBlock.MedianTime
+	if confirmingBlock.PreviousBlockHash != "" {
+		previousBlock, err := o.block(confirmingBlock.PreviousBlockHash)
+		if err != nil {
+			return "", err
+		}
+		startTime = previousBlock.MedianTime
+	}
+
+	elapsed := o.tip.MedianTime - startTime
+	if elapsed >= int64(lock.Value) {
+		return validStyle.Render("satisfied"), nil
+	}
+	return warningStyle.Render(fmt.Sprintf("not satisfied, %s left", time.Duration(int64(lock.Value)-elapsed)*time.Second)), nil
+}
+
+// sequenceLocktime decodes the BIP68 relative locktime of an input sequence
+func sequenceLocktime(sequence uint32) arklib.RelativeLocktime {
+	value := sequence & wire.SequenceLockTimeMask
+	if sequence&wire.SequenceLockTimeIsSeconds != 0 {
+		return arklib.RelativeLocktime{Type: arklib.LocktimeTypeSecond, Value: value << wire.SequenceLockTimeGranularity}
+	}
+	return arklib.RelativeLocktime{Type: arklib.LocktimeTypeBlock, Value: value}
+}
+
+// formatLocktimeValue formats an absolute locktime as a height or a timestamp
+func formatLocktimeValue(lock uint32) string {
+	if arklib.AbsoluteLocktime(lock).IsSeconds() {
+		return formatTimestamp(int64(lock))
+	}
+	return fmt.Sprintf("height %d", lock)
+}
+
+// psbtLeafScripts lists the TaprootLeafScript scripts of each input
+func psbtLeafScripts(p *psbt.Packet) map[int][][]byte {
+	leaves := make(map[int][][]byte)
+	for i, in := range p.Inputs {
+		for _, leaf := range in.TaprootLeafScript {
+			leaves[i] = append(leaves[i], leaf.Script)
+		}
+	}
+	return leaves
+}
+
+// witnessLeafScripts lists the leaf revealed by each taproot script path spend, given the
+// prevouts of the inputs (nil if unknown)
+func witnessLeafScripts(tx *wire.MsgTx, prevouts []*wire.TxOut) map[int][][]byte {
+	leaves := make(map[int][][]byte)
+	for i, txIn := range tx.TxIn {
+		if spend, ok := detectTaprootSpend(txIn.Witness, prevouts[i]); ok && spend.ScriptPath {
+			leaves[i] = [][]byte{spend.LeafScript}
+		}
+	}
+	return leaves
+}
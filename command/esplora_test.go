@@ -0,0 +1,243 @@
+package command
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	arklib "github.com/arkade-os/arkd/pkg/ark-lib"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	esploraTestBaseHeight = 100
+	esploraTestTipHeight  = 110
+	// the prevout is confirmed in this block, relative time locks start at the previous one
+	esploraTestConfirmationHeight = 105
+	esploraTestBaseMedianTime     = 1_700_000_000
+	esploraTestBlockInterval      = 512
+)
+
+// esploraTestFixtures holds the test chain and its transactions
+type esploraTestFixtures struct {
+	fixtures  esploraFixtures
+	confirmed *wire.MsgTx
+	mempool   *wire.MsgTx
+}
+
+func esploraTestBlockHash(height int64) string {
+	return chainhash.Hash{byte(height), 0xb1}.String()
+}
+
+func esploraTestMedianTime(height int64) int64 {
+	return esploraTestBaseMedianTime + (height-esploraTestBaseHeight)*esploraTestBlockInterval
+}
+
+func newEsploraTestFixtures(t *testing.T) esploraTestFixtures {
+	t.Helper()
+
+	var test esploraTestFixtures
+	for height := int64(esploraTestBaseHeight); height <= esploraTestTipHeight; height++ {
+		block := esploraBlock{
+			ID:         esploraTestBlockHash(height),
+			Height:     height,
+			Timestamp:  esploraTestMedianTime(height) + 60,
+			MedianTime: esploraTestMedianTime(height),
+		}
+		if height > esploraTestBaseHeight {
+			block.PreviousBlockHash = esploraTestBlockHash(height - 1)
+		}
+		test.fixtures.Blocks = append(test.fixtures.Blocks, block)
+	}
+
+	key := newPrivKey(t)
+	taprootScript, err := txscript.PayToTaprootScript(txscript.ComputeTaprootKeyNoScript(key.PubKey()))
+	require.NoError(t, err)
+	legacyScript, err := txscript.NewScriptBuilder().
+		AddOp(txscript.OP_DUP).
+		AddOp(txscript.OP_HASH160).
+		AddData(make([]byte, 20)).
+		AddOp(txscript.OP_EQUALVERIFY).
+		AddOp(txscript.OP_CHECKSIG).
+		Script()
+	require.NoError(t, err)
+
+	test.confirmed = wire.NewMsgTx(2)
+	test.confirmed.AddTxIn(wire.NewTxIn(&wire.OutPoint{Hash: chainhash.Hash{0xaa}}, nil, nil))
+	test.confirmed.AddTxOut(wire.NewTxOut(10_000, taprootScript))
+	test.confirmed.AddTxOut(wire.NewTxOut(20_000, legacyScript))
+
+	test.mempool = wire.NewMsgTx(2)
+	test.mempool.AddTxIn(wire.NewTxIn(&wire.OutPoint{Hash: chainhash.Hash{0xbb}}, nil, nil))
+	test.mempool.AddTxOut(wire.NewTxOut(30_000, taprootScript))
+
+	test.fixtures.Txs = []esploraTxFixture{
+		{
+			Hex: serializeTestTx(t, test.confirmed),
+			Status: esploraStatus{
+				Confirmed:   true,
+				BlockHeight: esploraTestConfirmationHeight,
+				BlockHash:   esploraTestBlockHash(esploraTestConfirmationHeight),
+				BlockTime:   esploraTestMedianTime(esploraTestConfirmationHeight) + 60,
+			},
+		},
+		{Hex: serializeTestTx(t, test.mempool)},
+	}
+	return test
+}
+
+func serializeTestTx(t *testing.T, tx *wire.MsgTx) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	require.NoError(t, tx.Serialize(&buf))
+	return hex.EncodeToString(buf.Bytes())
+}
+
+// newTestOnchainContext serves the fixtures like noa mock esplora
+func newTestOnchainContext(t *testing.T, fixtures esploraFixtures) *onchainContext {
+	t.Helper()
+
+	handler, err := newMockEsploraHandler(fixtures)
+	require.NoError(t, err)
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	onchain, err := newOnchainContext(EsploraOptions{URL: server.URL})
+	require.NoError(t, err)
+	return onchain
+}
+
+func TestFillPrevouts(t *testing.T) {
+	test := newEsploraTestFixtures(t)
+	onchain := newTestOnchainContext(t, test.fixtures)
+	require.Equal(t, int64(esploraTestTipHeight), onchain.tip.Height)
+
+	confirmedHash := test.confirmed.TxHash()
+	mempoolHash := test.mempool.TxHash()
+	p, err := psbt.New(
+		[]*wire.OutPoint{
+			{Hash: confirmedHash, Index: 0},
+			{Hash: confirmedHash, Index: 1},
+			{Hash: mempoolHash, Index: 0},
+			{Hash: chainhash.Hash{0xcc}, Index: 0},
+		},
+		[]*wire.TxOut{wire.NewTxOut(1_000, test.confirmed.TxOut[0].PkScript)},
+		2, 0, []uint32{wire.MaxTxInSequenceNum, wire.MaxTxInSequenceNum, wire.MaxTxInSequenceNum, wire.MaxTxInSequenceNum},
+	)
+	require.NoError(t, err)
+	existing := &wire.TxOut{Value: 1, PkScript: test.mempool.TxOut[0].PkScript}
+	p.Inputs[2].WitnessUtxo = existing
+
+	filled, err := onchain.fillPrevouts(p)
+	require.NoError(t, err)
+	require.Equal(t, map[int]string{0: "WitnessUtxo", 1: "NonWitnessUtxo"}, filled)
+
+	// witness program prevouts are set as WitnessUtxo
+	require.Equal(t, test.confirmed.TxOut[0], p.Inputs[0].WitnessUtxo)
+	require.Nil(t, p.Inputs[0].NonWitnessUtxo)
+
+	// legacy prevouts need the full previous transaction
+	require.Nil(t, p.Inputs[1].WitnessUtxo)
+	require.NotNil(t, p.Inputs[1].NonWitnessUtxo)
+	require.Equal(t, confirmedHash, p.Inputs[1].NonWitnessUtxo.TxHash())
+
+	// existing prevouts are kept and unknown ones left out
+	require.Same(t, existing, p.Inputs[2].WitnessUtxo)
+	require.Nil(t, p.Inputs[3].WitnessUtxo)
+	require.Nil(t, p.Inputs[3].NonWitnessUtxo)
+}
+
+func TestRelativeLockStatus(t *testing.T) {
+	test := newEsploraTestFixtures(t)
+	onchain := newTestOnchainContext(t, test.fixtures)
+	status := &test.fixtures.Txs[0].Status
+	satisfied := validStyle.Render("satisfied")
+
+	// the next block is the 7th one including the confirming block
+	blockAge := uint32(esploraTestTipHeight + 1 - esploraTestConfirmationHeight)
+	lockStatus, err := onchain.relativeLockStatus(arklib.RelativeLocktime{Type: arklib.LocktimeTypeBlock, Value: blockAge}, status)
+	require.NoError(t, err)
+	require.Equal(t, satisfied, lockStatus)
+
+	lockStatus, err = onchain.relativeLockStatus(arklib.RelativeLocktime{Type: arklib.LocktimeTypeBlock, Value: blockAge + 1}, status)
+	require.NoError(t, err)
+	require.Equal(t, warningStyle.Render("not satisfied, 1 blocks left"), lockStatus)
+
+	// time locks start at the median time of the block before the confirming one
+	elapsed := uint32(esploraTestMedianTime(esploraTestTipHeight) - esploraTestMedianTime(esploraTestConfirmationHeight-1))
+	lockStatus, err = onchain.relativeLockStatus(arklib.RelativeLocktime{Type: arklib.LocktimeTypeSecond, Value: elapsed}, status)
+	require.NoError(t, err)
+	require.Equal(t, satisfied, lockStatus)
+
+	lockStatus, err = onchain.relativeLockStatus(arklib.RelativeLocktime{Type: arklib.LocktimeTypeSecond, Value: elapsed + 512}, status)
+	require.NoError(t, err)
+	require.Equal(t, warningStyle.Render("not satisfied, 8m32s left"), lockStatus)
+
+	// unconfirmed prevouts only satisfy empty locks
+	lockStatus, err = onchain.relativeLockStatus(arklib.RelativeLocktime{Type: arklib.LocktimeTypeBlock, Value: 1}, &esploraStatus{})
+	require.NoError(t, err)
+	require.Equal(t, warningStyle.Render("not satisfied, the prevout is unconfirmed"), lockStatus)
+
+	lockStatus, err = onchain.relativeLockStatus(arklib.RelativeLocktime{Type: arklib.LocktimeTypeBlock}, nil)
+	require.NoError(t, err)
+	require.Equal(t, satisfied, lockStatus)
+}
+
+func TestAbsoluteLockStatus(t *testing.T) {
+	test := newEsploraTestFixtures(t)
+	onchain := newTestOnchainContext(t, test.fixtures)
+	satisfied := validStyle.Render("satisfied")
+
+	require.Equal(t, satisfied, onchain.absoluteLockStatus(esploraTestTipHeight))
+	require.Equal(t, warningStyle.Render("not satisfied, 1 blocks left"), onchain.absoluteLockStatus(esploraTestTipHeight+1))
+
+	// BIP113: time locks must be below the median time past
+	tipMedianTime := uint32(esploraTestMedianTime(esploraTestTipHeight))
+	require.Equal(t, satisfied, onchain.absoluteLockStatus(tipMedianTime-1))
+	require.Equal(t, warningStyle.Render("not satisfied, 1m0s left"), onchain.absoluteLockStatus(tipMedianTime+60))
+}
+
+func TestFormatConfirmation(t *testing.T) {
+	test := newEsploraTestFixtures(t)
+	onchain := newTestOnchainContext(t, test.fixtures)
+
+	unknown, err := onchain.tx(chainhash.Hash{0xcc}.String())
+	require.NoError(t, err)
+	require.Equal(t, warningStyle.Render("not found"), onchain.formatConfirmation(unknown))
+
+	mempool, err := onchain.tx(test.mempool.TxID())
+	require.NoError(t, err)
+	require.Equal(t, warningStyle.Render("unconfirmed, in mempool"), onchain.formatConfirmation(mempool))
+
+	confirmed, err := onchain.tx(test.confirmed.TxID())
+	require.NoError(t, err)
+	require.Equal(t, validStyle.Render("confirmed at height 105 (6 confirmations)"), onchain.formatConfirmation(confirmed))
+}
+
+func TestEsploraErrors(t *testing.T) {
+	test := newEsploraTestFixtures(t)
+	onchain := newTestOnchainContext(t, test.fixtures)
+
+	_, err := onchain.client.tx(chainhash.Hash{0xcc}.String())
+	require.True(t, errors.Is(err, errEsploraNotFound))
+	_, err = onchain.client.rawTx(chainhash.Hash{0xcc}.String())
+	require.True(t, errors.Is(err, errEsploraNotFound))
+	_, err = onchain.block(chainhash.Hash{0xcc}.String())
+	require.True(t, errors.Is(err, errEsploraNotFound))
+
+	// other errors carry the response body
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "rate limited", http.StatusTooManyRequests)
+	}))
+	t.Cleanup(server.Close)
+	_, err = newOnchainContext(EsploraOptions{URL: server.URL})
+	require.EqualError(t, err, "failed to get tip: esplora error (429 Too Many Requests): rate limited")
+}
@@ -0,0 +1,365 @@
+package command
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+
+	arklib "github.com/arkade-os/arkd/pkg/ark-lib"
+	"github.com/arkade-os/arkd/pkg/ark-lib/script"
+	"github.com/arkade-os/arkd/pkg/ark-lib/tree"
+	"github.com/arkade-os/arkd/pkg/ark-lib/txutils"
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// cpfpChildVSize is the estimated size of the child bumping an exit tx: it spends the P2A anchor
+// and a P2TR wallet utxo (key path) and sends the change to a P2TR output
+const cpfpChildVSize = 153
+
+// ExitPathOptions describes the vtxo to exit
+type ExitPathOptions struct {
+	Tree []string
+	Vtxo string
+	// Taptree is the hex encoded vtxo taptree, used to compute the exit delay
+	Taptree string
+	// FeeRate is the package fee rate in sat/vB used to estimate the CPFP fees
+	FeeRate float64
+	// OutDir is the directory the exit txs are exported to
+	OutDir string
+}
+
+// exitStep is a tx of the exit branch, in broadcast order
+type exitStep struct {
+	Packet *psbt.Packet
+	// Raw is the hex encoded signed tx, empty if the tx is not fully signed
+	Raw    string
+	Anchor *wire.OutPoint
+	VSize  int64
+	Fee    int64
+	Expiry *arklib.RelativeLocktime
+}
+
+func RunExitPath(opts ExitPathOptions) error {
+	txTree, err := parseTxTree(opts.Tree)
+	if err != nil {
+		return err
+	}
+
+	vtxo, err := parseOutpoint(opts.Vtxo)
+	if err != nil {
+		return err
+	}
+
+	if opts.FeeRate <= 0 {
+		return fmt.Errorf("invalid fee rate %v, must be positive", opts.FeeRate)
+	}
+
+	branch := txTreeBranch(txTree, vtxo.Hash.String())
+	if branch == nil {
+		return fmt.Errorf("vtxo %s not found in the tree leaves", vtxo)
+	}
+
+	leafTx := branch[len(branch)-1].UnsignedTx
+	if int(vtxo.Index) >= len(leafTx.TxOut) || isAnchorOutput(leafTx.TxOut[vtxo.Index]) {
+		return fmt.Errorf("vtxo %s is not an output of leaf %s", vtxo, leafTx.TxID())
+	}
+	vtxoOutput := leafTx.TxOut[vtxo.Index]
+
+	steps := make([]exitStep, 0, len(branch))
+	var totalFee int64
+	for _, p := range branch {
+		step := buildExitStep(p, opts.FeeRate)
+		totalFee += step.Fee
+		steps = append(steps, step)
+	}
+
+	var exitDelay *arklib.RelativeLocktime
+	if opts.Taptree != "" {
+		exitDelay, err = vtxoExitDelay(opts.Taptree, vtxoOutput.PkScript)
+		if err != nil {
+			return err
+		}
+	}
+
+	var output string
+
+	output += fmt.Sprintf("\n%s\n",
+		sectionStyle.Render("Exit Path:"),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("VTXO:"),
+		valueStyle.Render(vtxo.String()),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Amount:"),
+		valueStyle.Render(fmt.Sprintf("%d sats", vtxoOutput.Value)),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Requires:"),
+		valueStyle.Render(fmt.Sprintf("commitment tx %s confirmed", branch[0].UnsignedTx.TxIn[0].PreviousOutPoint.Hash)),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Transactions:"),
+		valueStyle.Render(fmt.Sprintf("%d", len(steps))),
+	)
+
+	output += fmt.Sprintf("\n%s\n",
+		sectionStyle.Render("Broadcast Order:"),
+	)
+	for i, step := range steps {
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render(fmt.Sprintf("[%d]:", i)),
+			valueStyle.Render(step.Packet.UnsignedTx.TxID()),
+		)
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render("  Spends:"),
+			valueStyle.Render(step.Packet.UnsignedTx.TxIn[0].PreviousOutPoint.String()),
+		)
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render("  CSV Wait:"),
+			valueStyle.Render("none (key path spend)"),
+		)
+		if step.Expiry != nil {
+			output += fmt.Sprintf("%s%s\n",
+				subLabelStyle.Render("  Deadline:"),
+				warningStyle.Render(fmt.Sprintf("confirm within %s of the parent, the server can sweep afterwards", formatLocktimeDuration(*step.Expiry))),
+			)
+		}
+		if step.Anchor != nil {
+			output += fmt.Sprintf("%s%s\n",
+				subLabelStyle.Render("  CPFP Anchor:"),
+				valueStyle.Render(step.Anchor.String()),
+			)
+		} else {
+			output += fmt.Sprintf("%s%s\n",
+				subLabelStyle.Render("  CPFP Anchor:"),
+				warningStyle.Render("missing, the tx can't be fee bumped"),
+			)
+		}
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render("  Estimated Fee:"),
+			valueStyle.Render(fmt.Sprintf("%d sats (%d vB + %d vB child)", step.Fee, step.VSize, cpfpChildVSize)),
+		)
+		if step.Raw != "" {
+			output += fmt.Sprintf("%s%s\n",
+				subLabelStyle.Render("  Tx:"),
+				valueStyle.Render(step.Raw),
+			)
+		} else {
+			encoded, err := step.Packet.B64Encode()
+			if err != nil {
+				return fmt.Errorf("failed to encode tx %s: %w", step.Packet.UnsignedTx.TxID(), err)
+			}
+			label := "  PSBT (unsigned):"
+			if hasSignatures(step.Packet) {
+				label = "  PSBT (partially signed):"
+			}
+			output += fmt.Sprintf("%s%s\n",
+				subLabelStyle.Render(label),
+				valueStyle.Render(encoded),
+			)
+		}
+	}
+
+	output += fmt.Sprintf("\n%s\n",
+		sectionStyle.Render("Unilateral Exit:"),
+	)
+	if exitDelay != nil {
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render("CSV Wait:"),
+			valueStyle.Render(fmt.Sprintf("%s after the leaf confirms, then spend the vtxo with its exit leaf", formatLocktimeDuration(*exitDelay))),
+		)
+	} else {
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render("CSV Wait:"),
+			warningStyle.Render("unknown, pass --taptree to compute the vtxo exit delay"),
+		)
+	}
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Total Estimated Fees:"),
+		valueStyle.Render(fmt.Sprintf("%d sats at %v sat/vB", totalFee, opts.FeeRate)),
+	)
+
+	if opts.OutDir != "" {
+		files, err := exportExitSteps(steps, opts.OutDir)
+		if err != nil {
+			return err
+		}
+		output += fmt.Sprintf("%s\n",
+			subLabelStyle.Render("Exported:"),
+		)
+		for _, file := range files {
+			output += fmt.Sprintf("%s%s\n",
+				subLabelStyle.Render("  -"),
+				valueStyle.Render(file),
+			)
+		}
+	}
+
+	fmt.Print(output)
+	return nil
+}
+
+// txTreeBranch returns the txs from the root to the leaf with the given txid, nil if not found
+func txTreeBranch(txTree *tree.TxTree, leafTxid string) []*psbt.Packet {
+	if len(txTree.Children) == 0 {
+		if txTree.Root.UnsignedTx.TxID() == leafTxid {
+			return []*psbt.Packet{txTree.Root}
+		}
+		return nil
+	}
+
+	for _, index := range sortedChildIndexes(txTree) {
+		if branch := txTreeBranch(txTree.Children[index], leafTxid); branch != nil {
+			return append([]*psbt.Packet{txTree.Root}, branch...)
+		}
+	}
+	return nil
+}
+
+// buildExitStep finalizes the tx if it is signed and estimates the fee of its CPFP package
+func buildExitStep(p *psbt.Packet, feeRate float64) exitStep {
+	step := exitStep{Packet: p}
+
+	for i, txOut := range p.UnsignedTx.TxOut {
+		if isAnchorOutput(txOut) {
+			step.Anchor = &wire.OutPoint{Hash: p.UnsignedTx.TxHash(), Index: uint32(i)}
+			break
+		}
+	}
+
+	if expiries, err := txutils.GetArkPsbtFields(p, 0, txutils.VtxoTreeExpiryField); err == nil && len(expiries) > 0 {
+		step.Expiry = &expiries[0]
+	}
+
+	// finalize a copy, the packet is exported as is if it can't be extracted
+	if raw, err := extractSignedTx(p); err == nil {
+		step.Raw = raw
+	}
+
+	step.VSize = treeTxVSize(p)
+	step.Fee = int64(math.Ceil(float64(step.VSize+cpfpChildVSize) * feeRate))
+	return step
+}
+
+// treeTxVSize estimates the vsize of a signed tree tx. Tree PSBTs carry neither their prevouts
+// nor a final witness, but their inputs are always taproot key path spends.
+func treeTxVSize(p *psbt.Packet) int64 {
+	tx := p.UnsignedTx
+	// segwit marker and flag
+	weight := int64(tx.SerializeSizeStripped())*blockchain.WitnessScaleFactor + 2
+	for range tx.TxIn {
+		weight += 1 + schnorrSigWitnessSize
+	}
+	return (weight + blockchain.WitnessScaleFactor - 1) / blockchain.WitnessScaleFactor
+}
+
+// extractSignedTx finalizes a copy of the packet and returns the hex encoded signed tx.
+// The key path witnesses are built from the signatures, tree PSBTs lacking the prevouts
+// the btcd finalizer requires.
+func extractSignedTx(p *psbt.Packet) (string, error) {
+	encoded, err := p.B64Encode()
+	if err != nil {
+		return "", err
+	}
+	finalized, err := psbt.NewFromRawBytes(bytes.NewReader([]byte(encoded)), true)
+	if err != nil {
+		return "", err
+	}
+
+	for _, result := range finalizePsbt(finalized) {
+		if result.Err != nil {
+			return "", fmt.Errorf("input [%d]: %w", result.Input, result.Err)
+		}
+	}
+	tx, err := psbt.Extract(finalized)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := tx.Serialize(&buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf.Bytes()), nil
+}
+
+// hasSignatures reports whether an input of the packet carries a signature or a final witness
+func hasSignatures(p *psbt.Packet) bool {
+	for _, in := range p.Inputs {
+		if len(in.TaprootKeySpendSig) > 0 || len(in.TaprootScriptSpendSig) > 0 || len(in.PartialSigs) > 0 ||
+			len(in.FinalScriptWitness) > 0 || len(in.FinalScriptSig) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// exportExitSteps writes each exit tx to the directory, prefixed by its broadcast position.
+// Signed txs are written as raw hex, others as base64 PSBTs.
+func exportExitSteps(steps []exitStep, dir string) ([]string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+
+	files := make([]string, 0, len(steps))
+	for i, step := range steps {
+		txid := step.Packet.UnsignedTx.TxID()
+		content := step.Raw
+		name := fmt.Sprintf("%02d-%s.hex", i, txid)
+		if content == "" {
+			encoded, err := step.Packet.B64Encode()
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode tx %s: %w", txid, err)
+			}
+			content = encoded
+			name = fmt.Sprintf("%02d-%s.psbt", i, txid)
+		}
+
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content+"\n"), 0o644); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", path, err)
+		}
+		files = append(files, path)
+	}
+	return files, nil
+}
+
+// vtxoExitDelay returns the smallest exit delay of the vtxo taptree, which must match the vtxo script
+func vtxoExitDelay(taptreeHex string, pkScript []byte) (*arklib.RelativeLocktime, error) {
+	_, vtxoScript, err := parseTaptree(taptreeHex)
+	if err != nil {
+		return nil, err
+	}
+
+	taprootKey, _, err := vtxoScript.TapTree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute taptree: %w", err)
+	}
+	expectedPkScript, err := script.P2TRScript(taprootKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute taproot script: %w", err)
+	}
+	if !bytes.Equal(expectedPkScript, pkScript) {
+		return nil, fmt.Errorf("taptree does not match the vtxo script")
+	}
+
+	exitDelay, err := vtxoScript.SmallestExitDelay()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get exit delay: %w", err)
+	}
+	return exitDelay, nil
+}
+
+// formatLocktimeDuration formats a relative locktime as a number of blocks or seconds
+func formatLocktimeDuration(lt arklib.RelativeLocktime) string {
+	if lt.Type == arklib.LocktimeTypeSecond {
+		return fmt.Sprintf("%d seconds", lt.Value)
+	}
+	return fmt.Sprintf("%d blocks", lt.Value)
+}
@@ -0,0 +1,107 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/arkade-os/arkd/pkg/ark-lib/tree"
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/require"
+)
+
+// TestBuildExitStepVSize checks the estimation of a tree tx without prevout against the signed tx
+func TestBuildExitStepVSize(t *testing.T) {
+	key := newPrivKey(t)
+	pkScript, err := txscript.PayToTaprootScript(txscript.ComputeTaprootKeyNoScript(key.PubKey()))
+	require.NoError(t, err)
+
+	p, err := psbt.New(
+		[]*wire.OutPoint{{Hash: chainhash.Hash{1}}},
+		[]*wire.TxOut{
+			{Value: 5_000, PkScript: pkScript},
+			{Value: 5_000, PkScript: pkScript},
+			{Value: 0, PkScript: []byte{txscript.OP_1, txscript.OP_DATA_2, 0x4e, 0x73}},
+		},
+		3, 0, []uint32{wire.MaxTxInSequenceNum},
+	)
+	require.NoError(t, err)
+
+	step := buildExitStep(p, 2)
+
+	signed := p.UnsignedTx.Copy()
+	signed.TxIn[0].Witness = wire.TxWitness{make([]byte, 64)}
+	weight := blockchain.GetTransactionWeight(btcutil.NewTx(signed))
+	expectedVSize := (weight + blockchain.WitnessScaleFactor - 1) / blockchain.WitnessScaleFactor
+
+	require.Equal(t, expectedVSize, step.VSize)
+	require.Equal(t, 2*(expectedVSize+cpfpChildVSize), step.Fee)
+	require.NotNil(t, step.Anchor)
+	require.Equal(t, uint32(2), step.Anchor.Index)
+}
+
+// newTreeTx returns an unsigned tree tx spending the outpoint to a P2TR output and an anchor
+func newTreeTx(t *testing.T, prevout wire.OutPoint, pkScript []byte) *psbt.Packet {
+	t.Helper()
+
+	p, err := psbt.New(
+		[]*wire.OutPoint{&prevout},
+		[]*wire.TxOut{
+			{Value: 10_000, PkScript: pkScript},
+			{Value: 0, PkScript: []byte{txscript.OP_1, txscript.OP_DATA_2, 0x4e, 0x73}},
+		},
+		3, 0, []uint32{wire.MaxTxInSequenceNum},
+	)
+	require.NoError(t, err)
+	return p
+}
+
+func TestExitStepsSignedBranch(t *testing.T) {
+	key := newPrivKey(t)
+	pkScript, err := txscript.PayToTaprootScript(txscript.ComputeTaprootKeyNoScript(key.PubKey()))
+	require.NoError(t, err)
+
+	root := newTreeTx(t, wire.OutPoint{Hash: chainhash.Hash{1}}, pkScript)
+	leaf := newTreeTx(t, wire.OutPoint{Hash: root.UnsignedTx.TxHash()}, pkScript)
+	txTree := &tree.TxTree{
+		Root:     root,
+		Children: map[uint32]*tree.TxTree{0: {Root: leaf}},
+	}
+	branch := txTreeBranch(txTree, leaf.UnsignedTx.TxID())
+	require.Len(t, branch, 2)
+
+	// tree PSBTs are signed without their prevouts
+	sig := make([]byte, 64)
+	sig[0] = 0x01
+	root.Inputs[0].TaprootKeySpendSig = sig
+
+	steps := []exitStep{buildExitStep(root, 1), buildExitStep(leaf, 1)}
+	require.NotEmpty(t, steps[0].Raw)
+	require.Empty(t, steps[1].Raw)
+	require.False(t, hasSignatures(steps[1].Packet))
+
+	signed, err := parseTx(steps[0].Raw)
+	require.NoError(t, err)
+	require.Equal(t, root.UnsignedTx.TxHash(), signed.TxHash())
+	require.Equal(t, wire.TxWitness{sig}, signed.TxIn[0].Witness)
+	require.Equal(t, steps[0].VSize, txVSize(signed))
+	// the packet itself is left untouched
+	require.Equal(t, sig, root.Inputs[0].TaprootKeySpendSig)
+
+	dir := t.TempDir()
+	files, err := exportExitSteps(steps, dir)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		filepath.Join(dir, fmt.Sprintf("00-%s.hex", root.UnsignedTx.TxID())),
+		filepath.Join(dir, fmt.Sprintf("01-%s.psbt", leaf.UnsignedTx.TxID())),
+	}, files)
+	content, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+	require.Equal(t, steps[0].Raw+"\n", string(content))
+}
@@ -0,0 +1,796 @@
+package command
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/arkade-os/arkd/pkg/ark-lib/script"
+	"github.com/arkade-os/arkd/pkg/ark-lib/tree"
+	"github.com/arkade-os/arkd/pkg/ark-lib/txutils"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// ExploreOptions configures the interactive explorer
+type ExploreOptions struct {
+	// Server is the arkd REST API the parent and child txs missing from the input are fetched from, optional
+	Server string
+}
+
+var (
+	exploreCursorStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("39")).
+				Bold(true)
+
+	exploreMatchStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("0")).
+				Background(lipgloss.Color("220"))
+
+	exploreHelpStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("240"))
+
+	exploreLinkStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("39"))
+)
+
+// exploreNode is a collapsible section of the explorer, its body being the output of the decoders
+type exploreNode struct {
+	title    string
+	body     string
+	children []*exploreNode
+	expanded bool
+	// txid is the transaction opened by enter, empty if the node links to none
+	txid string
+	// spentOutpoint is an output whose spending transaction is opened by enter
+	spentOutpoint *wire.OutPoint
+}
+
+// exploreRow is a line of the flattened outline: a node header, or a line of its body
+type exploreRow struct {
+	node   *exploreNode
+	header bool
+	depth  int
+	line   string
+}
+
+// exploreLoadedMsg is sent once a transaction missing from the input is fetched from the server
+type exploreLoadedMsg struct {
+	packet *psbt.Packet
+	err    error
+}
+
+type exploreModel struct {
+	txs      map[string]*psbt.Packet
+	spenders map[wire.OutPoint]string
+	txTree   *tree.TxTree
+	client   *indexerClient
+
+	current  string
+	history  []string
+	outlines map[string][]*exploreNode
+	cursors  map[string]int
+
+	rows   []exploreRow
+	cursor int
+	offset int
+	width  int
+	height int
+
+	search    textinput.Model
+	searching bool
+	query     string
+	status    string
+}
+
+// RunExplore opens a full screen explorer of a PSBT, a list of PSBTs or a tx tree,
+// the input being parsed like the one of tree decode
+func RunExplore(inputs []string, opts ExploreOptions) error {
+	packets, txTree, err := parseExploreInput(inputs)
+	if err != nil {
+		return err
+	}
+
+	var client *indexerClient
+	if opts.Server != "" {
+		if client, err = newIndexerClient(opts.Server); err != nil {
+			return err
+		}
+	}
+
+	search := textinput.New()
+	search.Prompt = "/"
+	search.Placeholder = "search"
+
+	m := &exploreModel{
+		txs:      make(map[string]*psbt.Packet),
+		spenders: make(map[wire.OutPoint]string),
+		txTree:   txTree,
+		client:   client,
+		outlines: make(map[string][]*exploreNode),
+		cursors:  make(map[string]int),
+		search:   search,
+	}
+	for _, p := range packets {
+		m.addTx(p)
+	}
+	m.open(packets[0].UnsignedTx.TxID(), false)
+
+	_, err = tea.NewProgram(m, tea.WithAltScreen()).Run()
+	return err
+}
+
+// parseExploreInput parses the input as a tx tree, or as unrelated PSBTs if they don't form one
+func parseExploreInput(inputs []string) ([]*psbt.Packet, *tree.TxTree, error) {
+	txTree, treeErr := parseTxTree(inputs)
+	if treeErr == nil {
+		packets := make([]*psbt.Packet, 0)
+		_ = txTree.Apply(func(node *tree.TxTree) (bool, error) {
+			packets = append(packets, node.Root)
+			return true, nil
+		})
+		if len(packets) == 1 {
+			txTree = nil
+		}
+		return packets, txTree, nil
+	}
+
+	packets := make([]*psbt.Packet, 0, len(inputs))
+	for _, input := range inputs {
+		content, err := readArg(input)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read input: %w", err)
+		}
+		for _, encoded := range strings.Fields(content) {
+			p, err := parsePsbt(encoded)
+			if err != nil {
+				return nil, nil, treeErr
+			}
+			packets = append(packets, p)
+		}
+	}
+	if len(packets) == 0 {
+		return nil, nil, fmt.Errorf("missing psbt")
+	}
+	return packets, nil, nil
+}
+
+// addTx loads a transaction, indexing the outputs it spends to navigate from parents to children
+func (m *exploreModel) addTx(p *psbt.Packet) {
+	txid := p.UnsignedTx.TxID()
+	m.txs[txid] = p
+	for _, txIn := range p.UnsignedTx.TxIn {
+		m.spenders[txIn.PreviousOutPoint] = txid
+	}
+	// the links of the loaded txs may change
+	for loaded := range m.outlines {
+		if loaded != txid {
+			delete(m.outlines, loaded)
+		}
+	}
+}
+
+// open displays a loaded transaction, pushing the current one to the history
+func (m *exploreModel) open(txid string, pushHistory bool) {
+	if m.current != "" {
+		m.cursors[m.current] = m.cursor
+		if pushHistory {
+			m.history = append(m.history, m.current)
+		}
+	}
+	m.current = txid
+	if _, ok := m.outlines[txid]; !ok {
+		m.outlines[txid] = m.buildOutline(m.txs[txid])
+	}
+	m.cursor = m.cursors[txid]
+	m.offset = 0
+	m.status = ""
+	m.refresh()
+}
+
+// canFollow reports whether the transaction a node links to is loaded or can be fetched
+func (m *exploreModel) canFollow(node *exploreNode) bool {
+	if node.txid != "" {
+		_, ok := m.txs[node.txid]
+		return ok || m.client != nil
+	}
+	return node.spentOutpoint != nil && m.client != nil
+}
+
+// follow opens the transaction a node links to, fetching it from the server if it is not loaded
+func (m *exploreModel) follow(node *exploreNode) tea.Cmd {
+	if node.txid != "" {
+		if _, ok := m.txs[node.txid]; ok {
+			m.open(node.txid, true)
+			return nil
+		}
+		if m.client == nil {
+			m.status = fmt.Sprintf("tx %s is not loaded, use --server to fetch it", node.txid)
+			return nil
+		}
+		m.status = fmt.Sprintf("fetching %s...", node.txid)
+		return m.fetch(node.txid, nil)
+	}
+
+	if node.spentOutpoint != nil {
+		if m.client == nil {
+			m.status = fmt.Sprintf("no loaded tx spends %s, use --server to fetch it", node.spentOutpoint)
+			return nil
+		}
+		m.status = fmt.Sprintf("fetching the spender of %s...", node.spentOutpoint)
+		return m.fetch("", node.spentOutpoint)
+	}
+
+	return nil
+}
+
+// fetch gets a virtual tx from the server, given its txid or the outpoint of a vtxo it spends
+func (m *exploreModel) fetch(txid string, outpoint *wire.OutPoint) tea.Cmd {
+	client := m.client
+	return func() tea.Msg {
+		if outpoint != nil {
+			vtxos, err := client.getVtxos(url.Values{"outpoints": {outpoint.String()}})
+			if err != nil {
+				return exploreLoadedMsg{err: err}
+			}
+			if len(vtxos) == 0 || vtxos[0].SpentBy == "" {
+				return exploreLoadedMsg{err: fmt.Errorf("%s is not spent offchain", outpoint)}
+			}
+			txid = vtxos[0].SpentBy
+		}
+
+		tx, err := client.getVirtualTx(txid)
+		if err != nil {
+			return exploreLoadedMsg{err: err}
+		}
+		p, err := parsePsbt(tx)
+		if err != nil {
+			return exploreLoadedMsg{err: fmt.Errorf("server returned an invalid tx: %w", err)}
+		}
+		return exploreLoadedMsg{packet: p}
+	}
+}
+
+// buildOutline decodes a transaction into collapsible sections, with the same decoders as psbt decode
+func (m *exploreModel) buildOutline(p *psbt.Packet) []*exploreNode {
+	tx := p.UnsignedTx
+	txid := tx.TxID()
+	classification := classifyPsbt(p)
+	xpubs := parseGlobalXpubs(p)
+
+	outline := []*exploreNode{{
+		title:    "Global",
+		body:     formatPsbtGlobal(p, classification, xpubs),
+		expanded: true,
+	}}
+
+	if m.txTree != nil {
+		treeNode := &exploreNode{
+			title:    fmt.Sprintf("Tree (%d nodes)", countTxTreeNodes(m.txTree)),
+			expanded: true,
+		}
+		treeNode.children = []*exploreNode{exploreTreeNode(m.txTree, "", txid)}
+		outline = append(outline, treeNode)
+	}
+
+	inputs := &exploreNode{
+		title:    fmt.Sprintf("Inputs (%d)", len(tx.TxIn)),
+		expanded: true,
+	}
+	for i, txIn := range tx.TxIn {
+		input := &exploreNode{
+			title: exploreTitle(fmt.Sprintf("[%d] %s", i, txIn.PreviousOutPoint), classification.InputRoles[i]),
+			body:  dropFirstLine(formatPsbtInput(p, i, classification, xpubs, nil)),
+			txid:  txIn.PreviousOutPoint.Hash.String(),
+		}
+		if i < len(p.Inputs) {
+			input.children = exploreInputSections(p, i)
+		}
+		inputs.children = append(inputs.children, input)
+	}
+	outline = append(outline, inputs)
+
+	outputs := &exploreNode{
+		title:    fmt.Sprintf("Outputs (%d)", len(tx.TxOut)),
+		expanded: true,
+	}
+	for i, txOut := range tx.TxOut {
+		output := &exploreNode{
+			title: exploreTitle(fmt.Sprintf("[%d] %d sats", i, txOut.Value), classification.OutputRoles[i]),
+			body:  dropFirstLine(formatPsbtOutput(p, i, classification, xpubs, nil)),
+		}
+		outpoint := wire.OutPoint{Hash: tx.TxHash(), Index: uint32(i)}
+		if spender, ok := m.spenders[outpoint]; ok {
+			output.txid = spender
+		} else if !isAnchorOutput(txOut) {
+			output.spentOutpoint = &outpoint
+		}
+		outputs.children = append(outputs.children, output)
+	}
+	outline = append(outline, outputs)
+
+	outline = append(outline, &exploreNode{
+		title: "Summary",
+		body:  formatPsbtSummary(summarizePsbt(p)),
+	})
+
+	return outline
+}
+
+// exploreInputSections returns the taproot leaves, ARK fields, sweep path and signatures of an input
+func exploreInputSections(p *psbt.Packet, inputIndex int) []*exploreNode {
+	sections := make([]*exploreNode, 0)
+	in := p.Inputs[inputIndex]
+
+	leaves := &exploreNode{}
+	scripts := make([]string, 0)
+	for _, leaf := range in.TaprootLeafScript {
+		scripts = append(scripts, hex.EncodeToString(leaf.Script))
+	}
+	if trees, err := txutils.GetArkPsbtFields(p, inputIndex, txutils.VtxoTaprootTreeField); err == nil {
+		for _, taptree := range trees {
+			scripts = append(scripts, taptree...)
+		}
+	}
+	seen := make(map[string]bool)
+	for _, scriptHex := range scripts {
+		scriptHex = strings.ToLower(scriptHex)
+		if seen[scriptHex] {
+			continue
+		}
+		seen[scriptHex] = true
+		leaves.children = append(leaves.children, exploreLeafNode(len(leaves.children), scriptHex))
+	}
+	if len(leaves.children) > 0 {
+		leaves.title = fmt.Sprintf("Taproot Leaves (%d)", len(leaves.children))
+		sections = append(sections, leaves)
+	}
+
+	if fields := formatArkPsbtFields(p, inputIndex); fields != "" {
+		sections = append(sections, &exploreNode{title: "ARK Fields", body: dropFirstLine(fields)})
+	}
+	if sweep := formatInputSweep(p, inputIndex, SweepOptions{}); sweep != "" {
+		sections = append(sections, &exploreNode{title: "Sweep Path", body: dropFirstLine(sweep)})
+	}
+	if len(in.TaprootKeySpendSig) > 0 || len(in.TaprootScriptSpendSig) > 0 {
+		sections = append(sections, &exploreNode{
+			title: "Signatures",
+			body:  dropFirstLine(formatSignatureChecks(verifyInputSignatures(p, inputIndex))),
+		})
+	}
+
+	return sections
+}
+
+// exploreLeafNode decodes a tapscript leaf as script decode does
+func exploreLeafNode(index int, scriptHex string) *exploreNode {
+	node := &exploreNode{title: fmt.Sprintf("[%d] unknown script", index)}
+	scriptBytes, _ := hex.DecodeString(scriptHex)
+
+	node.body += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("hex:"),
+		valueStyle.Render(scriptHex),
+	)
+	if disasm, err := txscript.DisasmString(scriptBytes); err == nil {
+		node.body += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render("asm:"),
+			valueStyle.Render(disasm),
+		)
+	}
+	if closure, err := script.DecodeClosure(scriptBytes); err == nil {
+		body := formatClosure(closure)
+		node.title = fmt.Sprintf("[%d] %s", index, ansi.Strip(strings.SplitN(body, "\n", 2)[0]))
+		node.body += dropFirstLine(body)
+	}
+	return node
+}
+
+// exploreTreeNode builds the outline of a tx tree node and its children, the current tx being expanded
+func exploreTreeNode(txTree *tree.TxTree, edge, current string) *exploreNode {
+	txid := txTree.Root.UnsignedTx.TxID()
+	node := &exploreNode{
+		title: edge + txid,
+		body:  dropFirstLine(formatTxTreeNode(txTree, edge)),
+		txid:  txid,
+	}
+	if txid == current {
+		node.title += " (current)"
+		node.txid = ""
+	} else if len(txTree.Children) == 0 {
+		node.title += " (leaf)"
+	}
+
+	for _, index := range sortedChildIndexes(txTree) {
+		child := exploreTreeNode(txTree.Children[index], fmt.Sprintf("[%d] ", index), current)
+		node.children = append(node.children, child)
+		if child.expanded || strings.HasSuffix(child.title, "(current)") {
+			node.expanded = true
+		}
+	}
+	return node
+}
+
+// exploreTitle appends the role of an input or output to its title, if known
+func exploreTitle(title, role string) string {
+	if role == "" {
+		return title
+	}
+	return fmt.Sprintf("%s (%s)", title, role)
+}
+
+// dropFirstLine removes the header line of a decoder output, replaced by the node title
+func dropFirstLine(output string) string {
+	_, rest, _ := strings.Cut(strings.TrimLeft(output, "\n"), "\n")
+	return rest
+}
+
+func (m *exploreModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m *exploreModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		m.scroll()
+		return m, nil
+
+	case exploreLoadedMsg:
+		if msg.err != nil {
+			m.status = msg.err.Error()
+			return m, nil
+		}
+		m.addTx(msg.packet)
+		m.open(msg.packet.UnsignedTx.TxID(), true)
+		return m, nil
+
+	case tea.KeyMsg:
+		if m.searching {
+			switch msg.String() {
+			case "enter":
+				m.searching = false
+				m.search.Blur()
+				m.query = m.search.Value()
+				m.applySearch()
+			case "esc":
+				m.searching = false
+				m.search.Blur()
+			default:
+				var cmd tea.Cmd
+				m.search, cmd = m.search.Update(msg)
+				return m, cmd
+			}
+			return m, nil
+		}
+
+		m.status = ""
+		switch msg.String() {
+		case "q", "ctrl+c":
+			return m, tea.Quit
+		case "up", "k":
+			m.cursor--
+		case "down", "j":
+			m.cursor++
+		case "pgup", "ctrl+u":
+			m.cursor -= m.pageSize()
+		case "pgdown", "ctrl+d":
+			m.cursor += m.pageSize()
+		case "home", "g":
+			m.cursor = 0
+		case "end", "G":
+			m.cursor = len(m.rows) - 1
+		case " ":
+			m.toggle()
+		case "enter":
+			if node := m.rows[m.cursor].node; m.canFollow(node) {
+				return m, m.follow(node)
+			}
+			m.toggle()
+		case "right", "l":
+			m.setExpanded(m.rows[m.cursor].node, true)
+		case "left", "h":
+			m.collapse()
+		case "E":
+			setExpandedAll(m.outlines[m.current], true)
+			m.refresh()
+		case "C":
+			setExpandedAll(m.outlines[m.current], false)
+			m.refresh()
+		case "p":
+			return m, m.followParent()
+		case "c":
+			return m, m.followChild()
+		case "b", "backspace":
+			if len(m.history) > 0 {
+				previous := m.history[len(m.history)-1]
+				m.history = m.history[:len(m.history)-1]
+				m.open(previous, false)
+			}
+		case "/":
+			m.searching = true
+			m.search.SetValue("")
+			return m, m.search.Focus()
+		case "n":
+			m.nextMatch(1)
+		case "N":
+			m.nextMatch(-1)
+		case "esc":
+			m.query = ""
+		}
+		m.scroll()
+	}
+	return m, nil
+}
+
+// followParent opens the tx spent by the first input, ie. the parent of a tree node
+func (m *exploreModel) followParent() tea.Cmd {
+	tx := m.txs[m.current].UnsignedTx
+	if len(tx.TxIn) == 0 {
+		return nil
+	}
+	return m.follow(&exploreNode{txid: tx.TxIn[0].PreviousOutPoint.Hash.String()})
+}
+
+// followChild opens the first loaded tx spending an output, or fetches the spender of the first output
+func (m *exploreModel) followChild() tea.Cmd {
+	tx := m.txs[m.current].UnsignedTx
+	for i := range tx.TxOut {
+		if spender, ok := m.spenders[wire.OutPoint{Hash: tx.TxHash(), Index: uint32(i)}]; ok {
+			m.open(spender, true)
+			return nil
+		}
+	}
+	for i, txOut := range tx.TxOut {
+		if !isAnchorOutput(txOut) {
+			return m.follow(&exploreNode{spentOutpoint: &wire.OutPoint{Hash: tx.TxHash(), Index: uint32(i)}})
+		}
+	}
+	return nil
+}
+
+func (m *exploreModel) toggle() {
+	node := m.rows[m.cursor].node
+	m.setExpanded(node, !node.expanded)
+}
+
+// collapse collapses the node under the cursor, moving the cursor to its header
+func (m *exploreModel) collapse() {
+	node := m.rows[m.cursor].node
+	m.setExpanded(node, false)
+	for i, row := range m.rows {
+		if row.header && row.node == node {
+			m.cursor = i
+			return
+		}
+	}
+}
+
+func (m *exploreModel) setExpanded(node *exploreNode, expanded bool) {
+	if node.body == "" && len(node.children) == 0 {
+		return
+	}
+	node.expanded = expanded
+	m.refresh()
+}
+
+func setExpandedAll(nodes []*exploreNode, expanded bool) {
+	for _, node := range nodes {
+		node.expanded = expanded
+		setExpandedAll(node.children, expanded)
+	}
+}
+
+// refresh flattens the visible nodes into rows, keeping the cursor on the same node if possible
+func (m *exploreModel) refresh() {
+	var cursorNode *exploreNode
+	if m.cursor >= 0 && m.cursor < len(m.rows) {
+		cursorNode = m.rows[m.cursor].node
+	}
+
+	m.rows = m.rows[:0]
+	var flatten func(nodes []*exploreNode, depth int)
+	flatten = func(nodes []*exploreNode, depth int) {
+		for _, node := range nodes {
+			m.rows = append(m.rows, exploreRow{node: node, header: true, depth: depth})
+			if !node.expanded {
+				continue
+			}
+			if body := strings.TrimRight(node.body, "\n"); body != "" {
+				for _, line := range strings.Split(body, "\n") {
+					m.rows = append(m.rows, exploreRow{node: node, depth: depth + 1, line: line})
+				}
+			}
+			flatten(node.children, depth+1)
+		}
+	}
+	flatten(m.outlines[m.current], 0)
+
+	if cursorNode != nil && (m.cursor >= len(m.rows) || m.rows[m.cursor].node != cursorNode) {
+		for i, row := range m.rows {
+			if row.header && row.node == cursorNode {
+				m.cursor = i
+				break
+			}
+		}
+	}
+	m.scroll()
+}
+
+// applySearch expands the nodes matching the query and moves the cursor to the first match
+func (m *exploreModel) applySearch() {
+	if m.query == "" {
+		return
+	}
+	query := strings.ToLower(m.query)
+	var expand func(nodes []*exploreNode) bool
+	expand = func(nodes []*exploreNode) bool {
+		found := false
+		for _, node := range nodes {
+			bodyMatch := strings.Contains(strings.ToLower(ansi.Strip(node.body)), query)
+			childMatch := expand(node.children)
+			if bodyMatch || childMatch {
+				node.expanded = true
+			}
+			if bodyMatch || childMatch || strings.Contains(strings.ToLower(node.title), query) {
+				found = true
+			}
+		}
+		return found
+	}
+	if !expand(m.outlines[m.current]) {
+		m.status = fmt.Sprintf("no match for %q", m.query)
+		return
+	}
+	m.refresh()
+	m.cursor--
+	m.nextMatch(1)
+	m.scroll()
+}
+
+// nextMatch moves the cursor to the next (or previous) row matching the query, wrapping around
+func (m *exploreModel) nextMatch(direction int) {
+	if m.query == "" || len(m.rows) == 0 {
+		return
+	}
+	for step := 1; step <= len(m.rows); step++ {
+		i := ((m.cursor+direction*step)%len(m.rows) + len(m.rows)) % len(m.rows)
+		if m.rowMatches(m.rows[i]) {
+			m.cursor = i
+			return
+		}
+	}
+	m.status = fmt.Sprintf("no match for %q", m.query)
+}
+
+func (m *exploreModel) rowMatches(row exploreRow) bool {
+	return m.query != "" && strings.Contains(strings.ToLower(m.rowText(row)), strings.ToLower(m.query))
+}
+
+func (m *exploreModel) rowText(row exploreRow) string {
+	if row.header {
+		return row.node.title
+	}
+	return ansi.Strip(row.line)
+}
+
+func (m *exploreModel) pageSize() int {
+	return max(m.height-3, 1)
+}
+
+// scroll keeps the cursor within the rows and visible
+func (m *exploreModel) scroll() {
+	m.cursor = min(max(m.cursor, 0), max(len(m.rows)-1, 0))
+	page := m.pageSize()
+	if m.cursor < m.offset {
+		m.offset = m.cursor
+	}
+	if m.cursor >= m.offset+page {
+		m.offset = m.cursor - page + 1
+	}
+	m.offset = max(min(m.offset, len(m.rows)-page), 0)
+}
+
+func (m *exploreModel) View() string {
+	p := m.txs[m.current]
+	header := fmt.Sprintf("%s%s  %s",
+		commonLabelStyle.Render("Tx:"),
+		valueStyle.Render(m.current),
+		exploreHelpStyle.Render(fmt.Sprintf("%s, %d loaded, %d back", classifyPsbt(p).Type, len(m.txs), len(m.history))),
+	)
+
+	lines := []string{ansi.Truncate(header, m.width, "…")}
+	for i := m.offset; i < min(m.offset+m.pageSize(), len(m.rows)); i++ {
+		lines = append(lines, ansi.Truncate(m.renderRow(m.rows[i], i == m.cursor), m.width, "…"))
+	}
+	for len(lines) < m.pageSize()+1 {
+		lines = append(lines, "")
+	}
+
+	footer := exploreHelpStyle.Render("↑/↓ move • space toggle • enter open/toggle • p/c parent/child • b back • / search • n/N next/prev • E/C expand/collapse all • q quit")
+	switch {
+	case m.searching:
+		footer = m.search.View()
+	case m.status != "":
+		footer = warningStyle.Render(m.status)
+	case m.query != "":
+		footer = exploreHelpStyle.Render(fmt.Sprintf("search %q • n/N next/prev • esc clear", m.query))
+	}
+	lines = append(lines, "", ansi.Truncate(footer, m.width, "…"))
+
+	return strings.Join(lines, "\n")
+}
+
+func (m *exploreModel) renderRow(row exploreRow, selected bool) string {
+	gutter := "  "
+	if selected {
+		gutter = exploreCursorStyle.Render("▌ ")
+	}
+	indent := strings.Repeat("  ", row.depth)
+
+	if !row.header {
+		line := row.line
+		if m.rowMatches(row) {
+			line = highlightMatches(ansi.Strip(line), m.query)
+		}
+		return gutter + indent + line
+	}
+
+	node := row.node
+	marker := "  "
+	if node.body != "" || len(node.children) > 0 {
+		marker = "▸ "
+		if node.expanded {
+			marker = "▾ "
+		}
+	}
+
+	title := commonLabelStyle.Render(node.title)
+	if row.depth == 0 {
+		title = sectionStyle.Render(node.title)
+	}
+	if m.rowMatches(row) {
+		title = highlightMatches(node.title, m.query)
+	}
+	if selected {
+		title = exploreCursorStyle.Render(node.title)
+	}
+
+	switch {
+	case !m.canFollow(node):
+	case node.txid != "" && strings.Contains(node.title, node.txid):
+		title += exploreLinkStyle.Render(" →")
+	case node.txid != "":
+		title += exploreLinkStyle.Render(" → " + shortTxid(node.txid))
+	default:
+		title += exploreLinkStyle.Render(" → spender")
+	}
+
+	return gutter + indent + marker + title
+}
+
+// highlightMatches highlights the case insensitive occurrences of query in text
+func highlightMatches(text, query string) string {
+	lowerText, lowerQuery := strings.ToLower(text), strings.ToLower(query)
+	var output string
+	for {
+		index := strings.Index(lowerText, lowerQuery)
+		if index < 0 || query == "" {
+			return output + text
+		}
+		output += text[:index] + exploreMatchStyle.Render(text[index:index+len(query)])
+		text, lowerText = text[index+len(query):], lowerText[index+len(query):]
+	}
+}
+
+func shortTxid(txid string) string {
+	if len(txid) <= 16 {
+		return txid
+	}
+	return txid[:8] + "…" + txid[len(txid)-8:]
+}
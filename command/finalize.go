@@ -0,0 +1,265 @@
+package command
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/arkade-os/arkd/pkg/ark-lib/script"
+	"github.com/arkade-os/arkd/pkg/ark-lib/txutils"
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// inputFinalization is the result of the finalization of a single input
+type inputFinalization struct {
+	Input int
+	// Path is "key-spend", "script-spend", "non-taproot" or "already finalized"
+	Path     string
+	LeafHash []byte
+	Err      error
+}
+
+func RunPsbtFinalize(psbtInput string) error {
+	content, err := readArg(psbtInput)
+	if err != nil {
+		return fmt.Errorf("failed to read psbt: %w", err)
+	}
+	p, err := parsePsbt(content)
+	if err != nil {
+		return err
+	}
+
+	results := finalizePsbt(p)
+
+	encoded, err := p.B64Encode()
+	if err != nil {
+		return fmt.Errorf("failed to encode psbt: %w", err)
+	}
+
+	output := formatFinalizations(results)
+	output += fmt.Sprintf("\n%s\n%s\n",
+		sectionStyle.Render("PSBT:"),
+		valueStyle.Render(encoded),
+	)
+	fmt.Print(output)
+
+	if failed := countFailedFinalizations(results); failed > 0 {
+		return fmt.Errorf("%d input(s) could not be finalized", failed)
+	}
+	return nil
+}
+
+func RunPsbtExtract(psbtInput string) error {
+	content, err := readArg(psbtInput)
+	if err != nil {
+		return fmt.Errorf("failed to read psbt: %w", err)
+	}
+	p, err := parsePsbt(content)
+	if err != nil {
+		return err
+	}
+
+	results := finalizePsbt(p)
+	if countFailedFinalizations(results) > 0 {
+		reasons := make([]string, 0)
+		for _, result := range results {
+			if result.Err != nil {
+				reasons = append(reasons, fmt.Sprintf("input [%d]: %v", result.Input, result.Err))
+			}
+		}
+		return fmt.Errorf("failed to assemble witnesses:\n  %s", strings.Join(reasons, "\n  "))
+	}
+
+	tx, err := psbt.Extract(p)
+	if err != nil {
+		return fmt.Errorf("failed to extract transaction: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := tx.Serialize(&buf); err != nil {
+		return fmt.Errorf("failed to serialize transaction: %w", err)
+	}
+
+	var output string
+
+	output += fmt.Sprintf("\n%s\n",
+		sectionStyle.Render("Transaction:"),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("TxId:"),
+		valueStyle.Render(tx.TxHash().String()),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("WTxId:"),
+		valueStyle.Render(tx.WitnessHash().String()),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Size:"),
+		valueStyle.Render(fmt.Sprintf("%d bytes, %d vB", tx.SerializeSize(), txVSize(tx))),
+	)
+	output += fmt.Sprintf("\n%s\n%s\n",
+		sectionStyle.Render("Raw:"),
+		valueStyle.Render(hex.EncodeToString(buf.Bytes())),
+	)
+
+	fmt.Print(output)
+	return nil
+}
+
+// finalizePsbt builds the final witness of every input not finalized yet
+func finalizePsbt(p *psbt.Packet) []inputFinalization {
+	results := make([]inputFinalization, 0, len(p.Inputs))
+	for i := range p.Inputs {
+		result := inputFinalization{Input: i}
+		in := p.Inputs[i]
+
+		switch {
+		case len(in.FinalScriptWitness) > 0 || len(in.FinalScriptSig) > 0:
+			result.Path = "already finalized"
+		case len(in.TaprootKeySpendSig) > 0:
+			result.Path = "key-spend"
+			result.Err = finalizeKeySpend(p, i)
+		case len(in.TaprootLeafScript) > 0:
+			result.Path = "script-spend"
+			result.LeafHash, result.Err = finalizeScriptSpend(p, i)
+		default:
+			// legacy and segwit v0 inputs are handled by the btcd finalizer
+			result.Path = "non-taproot"
+			result.Err = psbt.Finalize(p, i)
+		}
+
+		results = append(results, result)
+	}
+	return results
+}
+
+// finalizeKeySpend sets the key path witness, the key-spend signature alone
+func finalizeKeySpend(p *psbt.Packet, inputIndex int) error {
+	return setFinalWitness(p, inputIndex, wire.TxWitness{p.Inputs[inputIndex].TaprootKeySpendSig})
+}
+
+// finalizeScriptSpend sets the witness of the first leaf whose closure is fully signed,
+// appending the ConditionWitness items for condition closures. It returns the hash of the leaf.
+func finalizeScriptSpend(p *psbt.Packet, inputIndex int) ([]byte, error) {
+	in := p.Inputs[inputIndex]
+
+	args := make(map[string][]byte)
+	conditionWitnesses, err := txutils.GetArkPsbtFields(p, inputIndex, txutils.ConditionWitnessField)
+	if err != nil {
+		return nil, fmt.Errorf("invalid condition witness: %w", err)
+	}
+	if len(conditionWitnesses) > 0 {
+		var conditionWitness bytes.Buffer
+		if err := psbt.WriteTxWitness(&conditionWitness, conditionWitnesses[0]); err != nil {
+			return nil, fmt.Errorf("failed to encode condition witness: %w", err)
+		}
+		args[script.ConditionWitnessKey] = conditionWitness.Bytes()
+	}
+
+	reasons := make([]string, 0, len(in.TaprootLeafScript))
+	for _, leaf := range in.TaprootLeafScript {
+		tapHash := txscript.NewTapLeaf(leaf.LeafVersion, leaf.Script).TapHash()
+
+		closure, err := script.DecodeClosure(leaf.Script)
+		if err != nil {
+			reasons = append(reasons, fmt.Sprintf("leaf %x: unknown closure", tapHash[:]))
+			continue
+		}
+
+		// only the signatures of this leaf
+		leafArgs := make(map[string][]byte, len(args)+len(in.TaprootScriptSpendSig))
+		for key, value := range args {
+			leafArgs[key] = value
+		}
+		for _, sig := range in.TaprootScriptSpendSig {
+			if bytes.Equal(sig.LeafHash, tapHash[:]) {
+				leafArgs[hex.EncodeToString(sig.XOnlyPubKey)] = script.EncodeTaprootSignature(sig.Signature, sig.SigHash)
+			}
+		}
+
+		witness, err := closure.Witness(leaf.ControlBlock, leafArgs)
+		if err != nil {
+			reasons = append(reasons, fmt.Sprintf("leaf %x: %v", tapHash[:], err))
+			continue
+		}
+
+		return tapHash[:], setFinalWitness(p, inputIndex, witness)
+	}
+
+	return nil, fmt.Errorf("%s", strings.Join(reasons, ", "))
+}
+
+// setFinalWitness sets the final witness of the input and strips the fields it makes useless,
+// the utxos and unknown (ARK) fields are kept
+func setFinalWitness(p *psbt.Packet, inputIndex int, witness wire.TxWitness) error {
+	var buf bytes.Buffer
+	if err := psbt.WriteTxWitness(&buf, witness); err != nil {
+		return fmt.Errorf("failed to encode witness: %w", err)
+	}
+
+	in := &p.Inputs[inputIndex]
+	in.FinalScriptWitness = buf.Bytes()
+	in.PartialSigs = nil
+	in.SighashType = 0
+	in.RedeemScript = nil
+	in.WitnessScript = nil
+	in.Bip32Derivation = nil
+	in.TaprootKeySpendSig = nil
+	in.TaprootScriptSpendSig = nil
+	in.TaprootLeafScript = nil
+	in.TaprootBip32Derivation = nil
+	in.TaprootInternalKey = nil
+	in.TaprootMerkleRoot = nil
+	return nil
+}
+
+func countFailedFinalizations(results []inputFinalization) int {
+	failed := 0
+	for _, result := range results {
+		if result.Err != nil {
+			failed++
+		}
+	}
+	return failed
+}
+
+// txVSize returns the virtual size of a signed transaction
+func txVSize(tx *wire.MsgTx) int64 {
+	weight := blockchain.GetTransactionWeight(btcutil.NewTx(tx))
+	return (weight + blockchain.WitnessScaleFactor - 1) / blockchain.WitnessScaleFactor
+}
+
+// formatFinalizations formats the finalization result of each input
+func formatFinalizations(results []inputFinalization) string {
+	var output string
+
+	output += fmt.Sprintf("\n%s\n",
+		sectionStyle.Render(fmt.Sprintf("Inputs (%d):", len(results))),
+	)
+	for _, result := range results {
+		if result.Err != nil {
+			output += fmt.Sprintf("%s%s\n",
+				subLabelStyle.Render(fmt.Sprintf("[%d] %s:", result.Input, result.Path)),
+				invalidStyle.Render(result.Err.Error()),
+			)
+			continue
+		}
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render(fmt.Sprintf("[%d] %s:", result.Input, result.Path)),
+			validStyle.Render("finalized"),
+		)
+		if len(result.LeafHash) > 0 {
+			output += fmt.Sprintf("%s%s\n",
+				subLabelStyle.Render("  LeafHash:"),
+				valueStyle.Render(hex.EncodeToString(result.LeafHash)),
+			)
+		}
+	}
+
+	return output
+}
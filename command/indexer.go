@@ -0,0 +1,178 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// indexerPageSize is the number of vtxos requested per page
+const indexerPageSize = 100
+
+// IndexerOptions selects the arkd server queried by the indexer commands
+type IndexerOptions struct {
+	// Server is the base URL of the arkd REST API, eg. http://localhost:7070
+	Server string
+}
+
+// indexerClient queries the arkd indexer REST API
+type indexerClient struct {
+	baseURL string
+	http    *http.Client
+}
+
+// jsonInt64 decodes the 64 bits integers of the REST API, encoded as strings by the gateway
+type jsonInt64 int64
+
+func (i *jsonInt64) UnmarshalJSON(data []byte) error {
+	value, err := strconv.ParseInt(strings.Trim(string(data), `"`), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid integer %s: %w", data, err)
+	}
+	*i = jsonInt64(value)
+	return nil
+}
+
+func (i jsonInt64) MarshalJSON() ([]byte, error) {
+	return json.Marshal(strconv.FormatInt(int64(i), 10))
+}
+
+type indexerOutpoint struct {
+	Txid string `json:"txid"`
+	Vout uint32 `json:"vout"`
+}
+
+func (o indexerOutpoint) String() string {
+	return fmt.Sprintf("%s:%d", o.Txid, o.Vout)
+}
+
+// indexerVtxo is a vtxo as returned by the indexer
+type indexerVtxo struct {
+	Outpoint        indexerOutpoint `json:"outpoint"`
+	CreatedAt       jsonInt64       `json:"createdAt"`
+	ExpiresAt       jsonInt64       `json:"expiresAt"`
+	Amount          jsonInt64       `json:"amount"`
+	Script          string          `json:"script"`
+	IsPreconfirmed  bool            `json:"isPreconfirmed"`
+	IsSwept         bool            `json:"isSwept"`
+	IsUnrolled      bool            `json:"isUnrolled"`
+	IsSpent         bool            `json:"isSpent"`
+	SpentBy         string          `json:"spentBy,omitempty"`
+	CommitmentTxids []string        `json:"commitmentTxids,omitempty"`
+	SettledBy       string          `json:"settledBy,omitempty"`
+	ArkTxid         string          `json:"arkTxid,omitempty"`
+}
+
+type indexerPage struct {
+	Current int32 `json:"current"`
+	Next    int32 `json:"next"`
+	Total   int32 `json:"total"`
+}
+
+type indexerVtxosResponse struct {
+	Vtxos []indexerVtxo `json:"vtxos"`
+	Page  *indexerPage  `json:"page,omitempty"`
+}
+
+type indexerVirtualTxsResponse struct {
+	Txs  []string     `json:"txs"`
+	Page *indexerPage `json:"page,omitempty"`
+}
+
+// indexerError is the error body returned by the REST gateway
+type indexerError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func newIndexerClient(server string) (*indexerClient, error) {
+	baseURL, err := parseBaseURL(server, "--server")
+	if err != nil {
+		return nil, err
+	}
+
+	return &indexerClient{
+		baseURL: baseURL,
+		http:    &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// parseBaseURL checks the url of a REST API given with flag and trims its trailing slash
+func parseBaseURL(rawURL, flag string) (string, error) {
+	if rawURL == "" {
+		return "", fmt.Errorf("missing url, use %s", flag)
+	}
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return "", fmt.Errorf("invalid %s url %q, expected http(s)://host[:port]", flag, rawURL)
+	}
+	return strings.TrimSuffix(rawURL, "/"), nil
+}
+
+// get sends a GET request and decodes the JSON response into out
+func (c *indexerClient) get(path string, query url.Values, out any) error {
+	endpoint := c.baseURL + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	resp, err := c.http.Get(endpoint)
+	if err != nil {
+		return fmt.Errorf("failed to reach server: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr indexerError
+		if err := json.Unmarshal(body, &apiErr); err == nil && apiErr.Message != "" {
+			return fmt.Errorf("server error (%s): %s", resp.Status, apiErr.Message)
+		}
+		return fmt.Errorf("server error (%s)", resp.Status)
+	}
+
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// getVtxos fetches every page of vtxos matching the query (outpoints or scripts)
+func (c *indexerClient) getVtxos(query url.Values) ([]indexerVtxo, error) {
+	vtxos := make([]indexerVtxo, 0)
+	for index := 0; ; index++ {
+		query.Set("page.size", strconv.Itoa(indexerPageSize))
+		query.Set("page.index", strconv.Itoa(index))
+
+		var resp indexerVtxosResponse
+		if err := c.get("/v1/indexer/vtxos", query, &resp); err != nil {
+			return nil, err
+		}
+		vtxos = append(vtxos, resp.Vtxos...)
+
+		if resp.Page == nil || len(resp.Vtxos) == 0 || int(resp.Page.Next) <= index || resp.Page.Next >= resp.Page.Total {
+			return vtxos, nil
+		}
+	}
+}
+
+// getVirtualTx fetches an offchain transaction (ark, checkpoint or tree tx) as a PSBT
+func (c *indexerClient) getVirtualTx(txid string) (string, error) {
+	var resp indexerVirtualTxsResponse
+	if err := c.get("/v1/indexer/virtualTx/"+url.PathEscape(txid), nil, &resp); err != nil {
+		return "", err
+	}
+	if len(resp.Txs) == 0 || resp.Txs[0] == "" {
+		return "", fmt.Errorf("virtual tx %s not found", txid)
+	}
+	return resp.Txs[0], nil
+}
@@ -0,0 +1,147 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/require"
+)
+
+// newMockArkdServer serves the fixtures like noa mock arkd, counting the requests received
+func newMockArkdServer(t *testing.T, fixtures arkdFixtures) (*indexerClient, *atomic.Int32) {
+	t.Helper()
+
+	handler, err := newMockArkdHandler(fixtures)
+	require.NoError(t, err)
+
+	requests := new(atomic.Int32)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := newIndexerClient(server.URL)
+	require.NoError(t, err)
+	return client, requests
+}
+
+// newVirtualTx returns a base64 encoded PSBT spending the given outpoint
+func newVirtualTx(t *testing.T, prevout wire.OutPoint) (string, string) {
+	t.Helper()
+
+	p, err := psbt.New(
+		[]*wire.OutPoint{&prevout},
+		[]*wire.TxOut{{Value: 1_000, PkScript: []byte{0x51, 0x02, 0x4e, 0x73}}},
+		3, 0, []uint32{wire.MaxTxInSequenceNum},
+	)
+	require.NoError(t, err)
+	encoded, err := p.B64Encode()
+	require.NoError(t, err)
+	return encoded, p.UnsignedTx.TxID()
+}
+
+func TestGetVtxosPagination(t *testing.T) {
+	const nbVtxos = 2*indexerPageSize + 50
+
+	fixtures := arkdFixtures{}
+	for i := range nbVtxos {
+		fixtures.Vtxos = append(fixtures.Vtxos, indexerVtxo{
+			Outpoint: indexerOutpoint{Txid: chainhash.Hash{byte(i), byte(i >> 8)}.String(), Vout: 0},
+			Amount:   jsonInt64(i + 1),
+			Script:   "5120aa",
+		})
+	}
+	fixtures.Vtxos = append(fixtures.Vtxos, indexerVtxo{Script: "5120bb"})
+	client, requests := newMockArkdServer(t, fixtures)
+
+	vtxos, err := client.getVtxos(url.Values{"scripts": {"5120aa"}})
+	require.NoError(t, err)
+	require.Len(t, vtxos, nbVtxos)
+	require.Equal(t, jsonInt64(nbVtxos), vtxos[nbVtxos-1].Amount)
+	require.Equal(t, int32(3), requests.Load())
+
+	requests.Store(0)
+	vtxos, err = client.getVtxos(url.Values{"scripts": {"5120cc"}})
+	require.NoError(t, err)
+	require.Empty(t, vtxos)
+	require.Equal(t, int32(1), requests.Load())
+}
+
+func TestGetVtxosStuckPage(t *testing.T) {
+	// a server always answering the same page must not loop forever
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		writeMockJSON(w, indexerVtxosResponse{
+			Vtxos: []indexerVtxo{{Script: "5120aa"}},
+			Page:  &indexerPage{Current: 0, Next: 0, Total: 10},
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := newIndexerClient(server.URL)
+	require.NoError(t, err)
+	vtxos, err := client.getVtxos(url.Values{"scripts": {"5120aa"}})
+	require.NoError(t, err)
+	require.Len(t, vtxos, 1)
+	require.Equal(t, int32(1), requests.Load())
+}
+
+func TestJSONInt64(t *testing.T) {
+	var vtxo indexerVtxo
+	err := json.Unmarshal([]byte(`{"amount":"9223372036854775807","createdAt":1700000000}`), &vtxo)
+	require.NoError(t, err)
+	require.Equal(t, jsonInt64(9223372036854775807), vtxo.Amount)
+	require.Equal(t, jsonInt64(1700000000), vtxo.CreatedAt)
+
+	err = json.Unmarshal([]byte(`{"amount":"1.5"}`), &vtxo)
+	require.ErrorContains(t, err, "invalid integer")
+
+	encoded, err := json.Marshal(jsonInt64(-1))
+	require.NoError(t, err)
+	require.JSONEq(t, `"-1"`, string(encoded))
+}
+
+func TestIndexerErrors(t *testing.T) {
+	client, _ := newMockArkdServer(t, arkdFixtures{})
+
+	// the message of the gateway error body is shown
+	_, err := client.getVtxos(url.Values{})
+	require.EqualError(t, err, "server error (400 Bad Request): missing outpoints or scripts filter")
+
+	_, err = client.getVirtualTx(chainhash.Hash{1}.String())
+	require.ErrorContains(t, err, "server error (404 Not Found): virtual tx")
+
+	// other error bodies are left out
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "upstream unavailable", http.StatusBadGateway)
+	}))
+	t.Cleanup(server.Close)
+	client, err = newIndexerClient(server.URL)
+	require.NoError(t, err)
+	_, err = client.getInfo()
+	require.EqualError(t, err, "server error (502 Bad Gateway)")
+}
+
+func TestRunTxGetTxidMismatch(t *testing.T) {
+	tx, txid := newVirtualTx(t, wire.OutPoint{Hash: chainhash.Hash{1}})
+	requested := chainhash.Hash{2}.String()
+
+	// a server answering any txid with the same tx
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeMockJSON(w, indexerVirtualTxsResponse{Txs: []string{tx}})
+	}))
+	t.Cleanup(server.Close)
+
+	err := RunTxGet(requested, IndexerOptions{Server: server.URL})
+	require.EqualError(t, err, fmt.Sprintf("server returned tx %s, expected %s", txid, requested))
+}
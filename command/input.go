@@ -0,0 +1,86 @@
+package command
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// readArg returns the content of the file at the given path if it exists,
+// otherwise the argument itself. It lets commands accept both inline values and files.
+func readArg(arg string) (string, error) {
+	info, err := os.Stat(arg)
+	if err != nil || info.IsDir() {
+		return arg, nil
+	}
+
+	content, err := os.ReadFile(arg)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
+// parseOutpoint parses an outpoint formatted as txid:vout
+func parseOutpoint(s string) (*wire.OutPoint, error) {
+	txid, vout, found := strings.Cut(s, ":")
+	if !found {
+		return nil, fmt.Errorf("invalid outpoint %q, expected txid:vout", s)
+	}
+
+	hash, err := chainhash.NewHashFromStr(txid)
+	if err != nil {
+		return nil, fmt.Errorf("invalid txid %q: %w", txid, err)
+	}
+
+	index, err := strconv.ParseUint(vout, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid vout %q: %w", vout, err)
+	}
+
+	return wire.NewOutPoint(hash, uint32(index)), nil
+}
+
+// parseAmount parses an amount in sats
+func parseAmount(s string) (int64, error) {
+	amount, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q: %w", s, err)
+	}
+	if amount < 0 {
+		return 0, fmt.Errorf("invalid amount %q: must be positive", s)
+	}
+	return amount, nil
+}
+
+// parsePubKey parses a hex encoded compressed or x-only public key
+func parsePubKey(s string) (*btcec.PublicKey, error) {
+	keyBytes, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key %q: %w", s, err)
+	}
+
+	if len(keyBytes) == schnorr.PubKeyBytesLen {
+		return schnorr.ParsePubKey(keyBytes)
+	}
+	return btcec.ParsePubKey(keyBytes)
+}
+
+// parseCompressedPubKey parses a hex encoded compressed public key
+func parseCompressedPubKey(s string) (*btcec.PublicKey, error) {
+	keyBytes, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key %q: %w", s, err)
+	}
+	if len(keyBytes) != btcec.PubKeyBytesLenCompressed {
+		return nil, fmt.Errorf("invalid public key %q, expected a 33 bytes compressed key", s)
+	}
+	return btcec.ParsePubKey(keyBytes)
+}
@@ -0,0 +1,562 @@
+package command
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	arklib "github.com/arkade-os/arkd/pkg/ark-lib"
+	"github.com/arkade-os/arkd/pkg/ark-lib/intent"
+	"github.com/arkade-os/arkd/pkg/ark-lib/script"
+	"github.com/arkade-os/arkd/pkg/ark-lib/txutils"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// intentMessageTag is the BIP340 tag used to hash intent messages, unexported by the ark-lib
+// intent package and only used to display the hash, TestIntentMessageTag pins it
+var intentMessageTag = []byte("ark-intent-proof-message")
+
+func RunIntentDecode(proofInput string, messageInput string) error {
+	proofArg, err := readArg(proofInput)
+	if err != nil {
+		return fmt.Errorf("failed to read proof: %w", err)
+	}
+
+	p, err := parsePsbt(proofArg)
+	if err != nil {
+		return err
+	}
+
+	var message string
+	if messageInput != "" {
+		message, err = readArg(messageInput)
+		if err != nil {
+			return fmt.Errorf("failed to read message: %w", err)
+		}
+	}
+
+	var output string
+
+	// Structure
+	output += fmt.Sprintf("\n%s\n",
+		sectionStyle.Render("Proof:"),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("TxId:"),
+		valueStyle.Render(p.UnsignedTx.TxID()),
+	)
+	structureErrs := validateIntentStructure(p, message)
+	if len(structureErrs) == 0 {
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render("Structure:"),
+			validStyle.Render("valid"),
+		)
+	} else {
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render("Structure:"),
+			invalidStyle.Render("invalid"),
+		)
+		for _, structureErr := range structureErrs {
+			output += fmt.Sprintf("%s%s\n",
+				subLabelStyle.Render("  -"),
+				invalidStyle.Render(structureErr),
+			)
+		}
+	}
+
+	if message != "" {
+		messageHash := chainhash.TaggedHash(intentMessageTag, []byte(message))
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render("Message Hash:"),
+			valueStyle.Render(hex.EncodeToString(messageHash[:])),
+		)
+
+		// signatures can only be checked against the message the toSpend tx commits to
+		if len(structureErrs) == 0 {
+			output += fmt.Sprintf("%s%s\n",
+				subLabelStyle.Render("Signatures:"),
+				formatIntentSignatures(p, message),
+			)
+		}
+	}
+
+	// Proven VTXOs, the first input is the toSpend tx
+	nbVtxos := 0
+	if len(p.UnsignedTx.TxIn) > 1 {
+		nbVtxos = len(p.UnsignedTx.TxIn) - 1
+	}
+	output += fmt.Sprintf("\n%s\n",
+		sectionStyle.Render(fmt.Sprintf("Proven VTXOs (%d):", nbVtxos)),
+	)
+	for i := 1; i < len(p.UnsignedTx.TxIn); i++ {
+		output += fmt.Sprintf("%s\n",
+			subLabelStyle.Render(fmt.Sprintf("[%d]:", i-1)),
+		)
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render("  OutPoint:"),
+			valueStyle.Render(p.UnsignedTx.TxIn[i].PreviousOutPoint.String()),
+		)
+		if prevout := inputPrevout(p, i); prevout != nil {
+			output += fmt.Sprintf("%s%s\n",
+				subLabelStyle.Render("  Amount:"),
+				valueStyle.Render(fmt.Sprintf("%d sats", prevout.Value)),
+			)
+			output += fmt.Sprintf("%s%s\n",
+				subLabelStyle.Render("  PkScript:"),
+				valueStyle.Render(hex.EncodeToString(prevout.PkScript)),
+			)
+		}
+		output += formatArkPsbtFields(p, i)
+	}
+
+	// Outputs
+	var registerMessage *intent.RegisterMessage
+	var deleteMessage *intent.DeleteMessage
+	var messageErr error
+	if message != "" {
+		registerMessage, deleteMessage, messageErr = decodeIntentMessage(message)
+	}
+
+	proof := intent.Proof{Packet: *p}
+	if proof.ContainsOutputs() {
+		output += fmt.Sprintf("\n%s\n",
+			sectionStyle.Render(fmt.Sprintf("Requested Outputs (%d):", len(p.UnsignedTx.TxOut))),
+		)
+		for i, txOut := range p.UnsignedTx.TxOut {
+			destination := "unknown (no message)"
+			if registerMessage != nil {
+				destination = "offchain"
+				if slices.Contains(registerMessage.OnchainOutputIndexes, i) {
+					destination = "onchain"
+				}
+			}
+
+			output += fmt.Sprintf("%s\n",
+				subLabelStyle.Render(fmt.Sprintf("[%d]:", i)),
+			)
+			output += fmt.Sprintf("%s%s\n",
+				subLabelStyle.Render("  Destination:"),
+				valueStyle.Render(destination),
+			)
+			output += fmt.Sprintf("%s%s\n",
+				subLabelStyle.Render("  Value:"),
+				valueStyle.Render(fmt.Sprintf("%d sats", txOut.Value)),
+			)
+			output += fmt.Sprintf("%s%s\n",
+				subLabelStyle.Render("  PkScript:"),
+				valueStyle.Render(hex.EncodeToString(txOut.PkScript)),
+			)
+		}
+	} else {
+		output += fmt.Sprintf("\n%s%s\n",
+			sectionStyle.Render("Requested Outputs:"),
+			valueStyle.Render(" none"),
+		)
+	}
+
+	// Message
+	if message != "" {
+		output += fmt.Sprintf("\n%s\n",
+			sectionStyle.Render("Message:"),
+		)
+		switch {
+		case messageErr != nil:
+			output += fmt.Sprintf("%s%s\n",
+				subLabelStyle.Render("Error:"),
+				invalidStyle.Render(messageErr.Error()),
+			)
+		case registerMessage != nil:
+			output += formatRegisterMessage(*registerMessage, time.Now())
+		case deleteMessage != nil:
+			output += formatDeleteMessage(*deleteMessage, time.Now())
+		}
+	}
+
+	fmt.Print(output)
+	return nil
+}
+
+// validateIntentStructure checks the toSpend/toSign structure of an intent proof.
+// If the message is known, the toSpend tx is rebuilt to check the first input commits to it.
+func validateIntentStructure(p *psbt.Packet, message string) []string {
+	errs := make([]string, 0)
+	tx := p.UnsignedTx
+
+	if len(tx.TxIn) < 2 {
+		errs = append(errs, intent.ErrInvalidTxNumberOfInputs.Error())
+		return errs
+	}
+	if len(tx.TxOut) == 0 {
+		errs = append(errs, intent.ErrInvalidTxNumberOfOutputs.Error())
+	}
+
+	for i := range tx.TxIn {
+		if i >= len(p.Inputs) || p.Inputs[i].WitnessUtxo == nil {
+			errs = append(errs, fmt.Sprintf("input [%d]: %s", i, intent.ErrMissingWitnessUtxo))
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+
+	toSpendPrevout := p.Inputs[0].WitnessUtxo
+	firstVtxoPrevout := p.Inputs[1].WitnessUtxo
+	if toSpendPrevout.Value != 0 {
+		errs = append(errs, "toSpend output must have a zero value")
+	}
+	if !bytes.Equal(toSpendPrevout.PkScript, firstVtxoPrevout.PkScript) {
+		errs = append(errs, "toSpend output script does not match the first proven vtxo script")
+	}
+	if tx.TxIn[0].PreviousOutPoint.Index != 0 {
+		errs = append(errs, intent.ErrInvalidTxWrongOutputIndex.Error())
+	}
+
+	if message != "" {
+		toSpendHash, err := intentToSpendHash(message, tx.TxIn[1].PreviousOutPoint, firstVtxoPrevout)
+		if err != nil {
+			errs = append(errs, err.Error())
+		} else if tx.TxIn[0].PreviousOutPoint.Hash != toSpendHash {
+			errs = append(errs, fmt.Sprintf("%s, the proof does not commit to the message", intent.ErrInvalidTxWrongTxHash))
+		}
+	}
+
+	return errs
+}
+
+// intentToSpendHash returns the txid of the BIP322 toSpend tx committing to the message,
+// read from the first input of a proof built by ark-lib for the same first vtxo
+func intentToSpendHash(message string, outpoint wire.OutPoint, prevout *wire.TxOut) (chainhash.Hash, error) {
+	proof, err := intent.New(message, []intent.Input{{OutPoint: &outpoint, WitnessUtxo: prevout}}, nil)
+	if err != nil {
+		return chainhash.Hash{}, fmt.Errorf("failed to rebuild the toSpend tx: %w", err)
+	}
+	return proof.UnsignedTx.TxIn[0].PreviousOutPoint.Hash, nil
+}
+
+// formatIntentSignatures runs the full proof verification if the proof is signed
+func formatIntentSignatures(p *psbt.Packet, message string) string {
+	for _, in := range p.Inputs[1:] {
+		if len(in.TaprootScriptSpendSig) == 0 && len(in.TaprootKeySpendSig) == 0 &&
+			len(in.FinalScriptWitness) == 0 {
+			return warningStyle.Render("unsigned")
+		}
+	}
+
+	proofB64, err := p.B64Encode()
+	if err != nil {
+		return invalidStyle.Render(err.Error())
+	}
+
+	if err := intent.Verify(proofB64, message); err != nil {
+		return invalidStyle.Render(fmt.Sprintf("invalid (%s)", err))
+	}
+	return validStyle.Render("valid")
+}
+
+// decodeIntentMessage decodes a register or delete intent message
+func decodeIntentMessage(message string) (*intent.RegisterMessage, *intent.DeleteMessage, error) {
+	var base intent.BaseMessage
+	if err := json.Unmarshal([]byte(message), &base); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode message: %w", err)
+	}
+
+	switch base.Type {
+	case intent.IntentMessageTypeRegister:
+		var m intent.RegisterMessage
+		if err := m.Decode(message); err != nil {
+			return nil, nil, err
+		}
+		return &m, nil, nil
+	case intent.IntentMessageTypeDelete:
+		var m intent.DeleteMessage
+		if err := m.Decode(message); err != nil {
+			return nil, nil, err
+		}
+		return nil, &m, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown intent message type: %q", base.Type)
+	}
+}
+
+// formatRegisterMessage formats a register intent message
+func formatRegisterMessage(m intent.RegisterMessage, now time.Time) string {
+	var output string
+
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Type:"),
+		valueStyle.Render(string(m.Type)),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Valid At:"),
+		valueStyle.Render(formatTimestamp(m.ValidAt)),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Expire At:"),
+		valueStyle.Render(formatTimestamp(m.ExpireAt)),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Status:"),
+		formatIntentStatus(m.ValidAt, m.ExpireAt, now),
+	)
+
+	onchainIndexes := "none"
+	if len(m.OnchainOutputIndexes) > 0 {
+		onchainIndexes = fmt.Sprintf("%v", m.OnchainOutputIndexes)
+	}
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Onchain Outputs:"),
+		valueStyle.Render(onchainIndexes),
+	)
+
+	if len(m.CosignersPublicKeys) > 0 {
+		output += fmt.Sprintf("%s\n",
+			subLabelStyle.Render("Cosigners:"),
+		)
+		for i, cosigner := range m.CosignersPublicKeys {
+			output += fmt.Sprintf("%s%s\n",
+				subLabelStyle.Render(fmt.Sprintf("  [%d]:", i)),
+				valueStyle.Render(cosigner),
+			)
+		}
+	}
+
+	return output
+}
+
+// formatDeleteMessage formats a delete intent message
+func formatDeleteMessage(m intent.DeleteMessage, now time.Time) string {
+	var output string
+
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Type:"),
+		valueStyle.Render(string(m.Type)),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Expire At:"),
+		valueStyle.Render(formatTimestamp(m.ExpireAt)),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Status:"),
+		formatIntentStatus(0, m.ExpireAt, now),
+	)
+
+	return output
+}
+
+// formatTimestamp formats a unix timestamp in seconds, 0 meaning unset
+func formatTimestamp(timestamp int64) string {
+	if timestamp == 0 {
+		return "not set"
+	}
+	return fmt.Sprintf("%s (%d)", time.Unix(timestamp, 0).UTC().Format(time.RFC3339), timestamp)
+}
+
+// formatIntentStatus reports whether the intent is valid at the given time
+func formatIntentStatus(validAt, expireAt int64, now time.Time) string {
+	switch {
+	case expireAt != 0 && now.Unix() >= expireAt:
+		return invalidStyle.Render(fmt.Sprintf("expired %s ago", now.Sub(time.Unix(expireAt, 0)).Round(time.Second)))
+	case validAt != 0 && now.Unix() < validAt:
+		return warningStyle.Render(fmt.Sprintf("not valid yet, valid in %s", time.Unix(validAt, 0).Sub(now).Round(time.Second)))
+	default:
+		return validStyle.Render("valid")
+	}
+}
+
+// IntentNewOptions describes the intent proof to build
+type IntentNewOptions struct {
+	// Vtxos are formatted as txid:vout:taptree:amount
+	Vtxos []string
+	// Outputs are formatted as address:amount, address being an ark or onchain address
+	Outputs []string
+	// Cosigners are the hex encoded public keys signing the vtxo tree
+	Cosigners []string
+	// ValidFor sets the expiration of the intent, 0 means no expiration
+	ValidFor time.Duration
+}
+
+// parseIntentCosigners returns the cosigners as arkd parses them: hex encoded compressed keys
+func parseIntentCosigners(cosigners []string) ([]string, error) {
+	encoded := make([]string, 0, len(cosigners))
+	for i, cosigner := range cosigners {
+		pubKey, err := parseCompressedPubKey(cosigner)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cosigner [%d]: %w", i, err)
+		}
+		encoded = append(encoded, hex.EncodeToString(pubKey.SerializeCompressed()))
+	}
+	return encoded, nil
+}
+
+func RunIntentNew(opts IntentNewOptions) error {
+	if len(opts.Vtxos) == 0 {
+		return fmt.Errorf("at least one vtxo is required")
+	}
+
+	inputs := make([]intent.Input, 0, len(opts.Vtxos))
+	taptrees := make([]txutils.TapTree, 0, len(opts.Vtxos))
+	exitLeaves := make([]*psbt.TaprootTapLeafScript, 0, len(opts.Vtxos))
+	for i, vtxo := range opts.Vtxos {
+		input, taptree, exitLeaf, err := parseIntentVtxo(vtxo)
+		if err != nil {
+			return fmt.Errorf("invalid vtxo [%d]: %w", i, err)
+		}
+		inputs = append(inputs, *input)
+		taptrees = append(taptrees, taptree)
+		exitLeaves = append(exitLeaves, exitLeaf)
+	}
+
+	outputs := make([]*wire.TxOut, 0, len(opts.Outputs))
+	onchainOutputIndexes := make([]int, 0)
+	for i, out := range opts.Outputs {
+		txOut, onchain, err := parseOutput(out)
+		if err != nil {
+			return fmt.Errorf("invalid output [%d]: %w", i, err)
+		}
+		if onchain {
+			onchainOutputIndexes = append(onchainOutputIndexes, i)
+		}
+		outputs = append(outputs, txOut)
+	}
+
+	cosigners, err := parseIntentCosigners(opts.Cosigners)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	message := intent.RegisterMessage{
+		BaseMessage:          intent.BaseMessage{Type: intent.IntentMessageTypeRegister},
+		OnchainOutputIndexes: onchainOutputIndexes,
+		ValidAt:              now.Unix(),
+		CosignersPublicKeys:  cosigners,
+	}
+	if opts.ValidFor > 0 {
+		message.ExpireAt = now.Add(opts.ValidFor).Unix()
+	}
+
+	encodedMessage, err := message.Encode()
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %w", err)
+	}
+
+	proof, err := intent.New(encodedMessage, inputs, outputs)
+	if err != nil {
+		return fmt.Errorf("failed to build proof: %w", err)
+	}
+	ptx := &proof.Packet
+
+	// the toSpend input is signed with the same leaf as the first vtxo
+	ptx.Inputs[0].TaprootLeafScript = []*psbt.TaprootTapLeafScript{exitLeaves[0]}
+	for i := range inputs {
+		ptx.Inputs[i+1].TaprootLeafScript = []*psbt.TaprootTapLeafScript{exitLeaves[i]}
+		if err := txutils.SetArkPsbtField(ptx, i+1, txutils.VtxoTaprootTreeField, taptrees[i]); err != nil {
+			return fmt.Errorf("failed to set taptree field: %w", err)
+		}
+	}
+
+	encodedProof, err := ptx.B64Encode()
+	if err != nil {
+		return fmt.Errorf("failed to encode proof: %w", err)
+	}
+
+	var output string
+
+	output += fmt.Sprintf("\n%s\n%s\n",
+		sectionStyle.Render("Proof:"),
+		valueStyle.Render(encodedProof),
+	)
+	output += fmt.Sprintf("\n%s\n%s\n",
+		sectionStyle.Render("Message:"),
+		valueStyle.Render(encodedMessage),
+	)
+
+	fmt.Print(output)
+	return nil
+}
+
+// parseIntentVtxo parses a vtxo formatted as txid:vout:taptree:amount.
+// The proof spends the vtxo with its exit leaf having the smallest delay,
+// the only path the owner can sign alone.
+func parseIntentVtxo(vtxo string) (*intent.Input, txutils.TapTree, *psbt.TaprootTapLeafScript, error) {
+	parts := strings.Split(vtxo, ":")
+	if len(parts) != 4 {
+		return nil, nil, nil, fmt.Errorf("expected txid:vout:taptree:amount, got %q", vtxo)
+	}
+
+	outpoint, err := parseOutpoint(parts[0] + ":" + parts[1])
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	amount, err := parseAmount(parts[3])
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	taptree, vtxoScript, err := parseTaptree(parts[2])
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	tapKey, tapTree, err := vtxoScript.TapTree()
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to compute tapkey: %w", err)
+	}
+
+	pkScript, err := script.P2TRScript(tapKey)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to create pk script: %w", err)
+	}
+
+	exitDelay, err := vtxoScript.SmallestExitDelay()
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to get exit delay: %w", err)
+	}
+
+	var exitClosure *script.CSVMultisigClosure
+	for _, closure := range vtxoScript.ExitClosures() {
+		if csv, ok := closure.(*script.CSVMultisigClosure); ok && csv.Locktime == *exitDelay {
+			exitClosure = csv
+			break
+		}
+	}
+	if exitClosure == nil {
+		return nil, nil, nil, script.ErrNoExitLeaf
+	}
+
+	exitScript, err := exitClosure.Script()
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to encode exit closure: %w", err)
+	}
+
+	proof, err := tapTree.GetTaprootMerkleProof(txscript.NewBaseTapLeaf(exitScript).TapHash())
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to get exit leaf proof: %w", err)
+	}
+
+	sequence, err := arklib.BIP68Sequence(*exitDelay)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to compute sequence: %w", err)
+	}
+
+	input := &intent.Input{
+		OutPoint:    outpoint,
+		Sequence:    sequence,
+		WitnessUtxo: &wire.TxOut{Value: amount, PkScript: pkScript},
+	}
+	leaf := &psbt.TaprootTapLeafScript{
+		ControlBlock: proof.ControlBlock,
+		Script:       proof.Script,
+		LeafVersion:  txscript.BaseLeafVersion,
+	}
+
+	return input, taptree, leaf, nil
+}
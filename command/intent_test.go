@@ -0,0 +1,77 @@
+package command
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/arkade-os/arkd/pkg/ark-lib/intent"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/require"
+)
+
+// newIntentProof builds an unsigned proof of a single vtxo with ark-lib
+func newIntentProof(t *testing.T, message string) (*intent.Proof, *wire.TxOut) {
+	t.Helper()
+
+	key := newPrivKey(t)
+	pkScript, err := txscript.PayToTaprootScript(txscript.ComputeTaprootKeyNoScript(key.PubKey()))
+	require.NoError(t, err)
+	prevout := &wire.TxOut{Value: 10_000, PkScript: pkScript}
+
+	proof, err := intent.New(message, []intent.Input{{
+		OutPoint:    &wire.OutPoint{Hash: chainhash.Hash{1}, Index: 1},
+		Sequence:    wire.MaxTxInSequenceNum,
+		WitnessUtxo: prevout,
+	}}, nil)
+	require.NoError(t, err)
+	return proof, prevout
+}
+
+func TestValidateIntentStructure(t *testing.T) {
+	message, err := intent.RegisterMessage{
+		BaseMessage: intent.BaseMessage{Type: intent.IntentMessageTypeRegister},
+		ValidAt:     1_700_000_000,
+	}.Encode()
+	require.NoError(t, err)
+
+	proof, _ := newIntentProof(t, message)
+	require.Empty(t, validateIntentStructure(&proof.Packet, message))
+	require.Empty(t, validateIntentStructure(&proof.Packet, ""))
+
+	errs := validateIntentStructure(&proof.Packet, message+" ")
+	require.Len(t, errs, 1)
+	require.Contains(t, errs[0], intent.ErrInvalidTxWrongTxHash.Error())
+}
+
+// TestIntentMessageTag checks the copied tag against the toSpend tx of a proof built by ark-lib
+func TestIntentMessageTag(t *testing.T) {
+	message := "noa"
+	proof, prevout := newIntentProof(t, message)
+
+	messageHash := chainhash.TaggedHash(intentMessageTag, []byte(message))
+	toSpend := wire.NewMsgTx(0)
+	toSpend.TxIn = []*wire.TxIn{{
+		PreviousOutPoint: wire.OutPoint{Index: 0xffffffff},
+		SignatureScript:  append([]byte{txscript.OP_0, txscript.OP_DATA_32}, messageHash[:]...),
+	}}
+	toSpend.TxOut = []*wire.TxOut{{Value: 0, PkScript: prevout.PkScript}}
+
+	require.Equal(t, toSpend.TxHash(), proof.UnsignedTx.TxIn[0].PreviousOutPoint.Hash)
+}
+
+func TestParseIntentCosigners(t *testing.T) {
+	pubKey := newPrivKey(t).PubKey()
+	compressed := hex.EncodeToString(pubKey.SerializeCompressed())
+
+	cosigners, err := parseIntentCosigners([]string{strings.ToUpper(compressed)})
+	require.NoError(t, err)
+	require.Equal(t, []string{compressed}, cosigners)
+
+	// arkd parses the cosigners as compressed keys
+	_, err = parseIntentCosigners([]string{compressed, hex.EncodeToString(schnorr.SerializePubKey(pubKey))})
+	require.ErrorContains(t, err, "invalid cosigner [1]")
+}
@@ -0,0 +1,197 @@
+package command
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+
+	arklib "github.com/arkade-os/arkd/pkg/ark-lib"
+	"github.com/arkade-os/arkd/pkg/ark-lib/script"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+// DefaultExitDelay is the exit delay of the derived Ark address, about a day in seconds:
+// time based relative locktimes must be a multiple of 512 seconds
+const DefaultExitDelay = 168 * 512
+
+// KeyOptions describes how the addresses of a key are derived
+type KeyOptions struct {
+	Network string
+	// Signer is the server public key, the Ark address is only derived if set
+	Signer string
+	// ExitDelay is the exit delay of the default vtxo script, in blocks below 512 and seconds otherwise
+	ExitDelay uint32
+	// Out is the file the new private key is written to, as WIF
+	Out string
+}
+
+func RunKeyNew(opts KeyOptions) error {
+	network, err := parseArkNetwork(opts.Network)
+	if err != nil {
+		return err
+	}
+
+	privKey, err := btcec.NewPrivateKey()
+	if err != nil {
+		return fmt.Errorf("failed to generate key: %w", err)
+	}
+
+	wif, err := btcutil.NewWIF(privKey, onchainParams(network), true)
+	if err != nil {
+		return fmt.Errorf("failed to encode WIF: %w", err)
+	}
+
+	var output string
+
+	output += fmt.Sprintf("\n%s\n",
+		sectionStyle.Render("Private Key:"),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Hex:"),
+		valueStyle.Render(hex.EncodeToString(privKey.Serialize())),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("WIF:"),
+		valueStyle.Render(wif.String()),
+	)
+
+	if opts.Out != "" {
+		if err := os.WriteFile(opts.Out, []byte(wif.String()+"\n"), 0o600); err != nil {
+			return fmt.Errorf("failed to write %s: %w", opts.Out, err)
+		}
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render("Saved To:"),
+			valueStyle.Render(opts.Out),
+		)
+	}
+
+	keyOutput, err := formatKey(privKey.PubKey(), network, opts)
+	if err != nil {
+		return err
+	}
+	output += keyOutput
+
+	fmt.Print(output)
+	return nil
+}
+
+func RunKeyShow(keyInput string, opts KeyOptions) error {
+	network, err := parseArkNetwork(opts.Network)
+	if err != nil {
+		return err
+	}
+
+	privKey, err := parsePrivKey(keyInput)
+	if err != nil {
+		return err
+	}
+
+	output, err := formatKey(privKey.PubKey(), network, opts)
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(output)
+	return nil
+}
+
+// parsePrivKey parses a WIF or hex encoded private key, inline or from a file
+func parsePrivKey(keyInput string) (*btcec.PrivateKey, error) {
+	content, err := readArg(keyInput)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key: %w", err)
+	}
+
+	if wif, err := btcutil.DecodeWIF(content); err == nil {
+		return wif.PrivKey, nil
+	}
+
+	keyBytes, err := hex.DecodeString(content)
+	if err != nil || len(keyBytes) != btcec.PrivKeyBytesLen {
+		return nil, fmt.Errorf("invalid private key: expected WIF or 32 bytes hex")
+	}
+	privKey, _ := btcec.PrivKeyFromBytes(keyBytes)
+	return privKey, nil
+}
+
+// formatKey formats the public keys of a key and the addresses it controls
+func formatKey(pubKey *btcec.PublicKey, network *arklib.Network, opts KeyOptions) (string, error) {
+	var output string
+
+	taprootKey := txscript.ComputeTaprootKeyNoScript(pubKey)
+
+	output += fmt.Sprintf("\n%s\n",
+		sectionStyle.Render("Public Keys:"),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Compressed:"),
+		valueStyle.Render(hex.EncodeToString(pubKey.SerializeCompressed())),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("X-Only:"),
+		valueStyle.Render(hex.EncodeToString(schnorr.SerializePubKey(pubKey))),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Taproot (BIP86 tweaked):"),
+		valueStyle.Render(hex.EncodeToString(schnorr.SerializePubKey(taprootKey))),
+	)
+
+	onchainAddress, err := btcutil.NewAddressTaproot(schnorr.SerializePubKey(taprootKey), onchainParams(network))
+	if err != nil {
+		return "", fmt.Errorf("failed to encode onchain address: %w", err)
+	}
+
+	output += fmt.Sprintf("\n%s\n",
+		sectionStyle.Render(fmt.Sprintf("Addresses (%s):", network.Name)),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Onchain:"),
+		valueStyle.Render(onchainAddress.EncodeAddress()),
+	)
+
+	if opts.Signer == "" {
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render("Ark:"),
+			warningStyle.Render("unknown, pass --signer to derive the default vtxo address"),
+		)
+		return output, nil
+	}
+
+	signer, err := parsePubKey(opts.Signer)
+	if err != nil {
+		return "", fmt.Errorf("invalid signer: %w", err)
+	}
+
+	exitDelay, err := parseRelativeLocktime(opts.ExitDelay)
+	if err != nil {
+		return "", fmt.Errorf("invalid exit delay: %w", err)
+	}
+
+	vtxoTapKey, _, err := script.NewDefaultVtxoScript(pubKey, signer, exitDelay).TapTree()
+	if err != nil {
+		return "", fmt.Errorf("failed to compute vtxo taptree: %w", err)
+	}
+	address := &arklib.Address{
+		HRP:        network.Addr,
+		Signer:     signer,
+		VtxoTapKey: vtxoTapKey,
+	}
+	encoded, err := address.EncodeV0()
+	if err != nil {
+		return "", fmt.Errorf("failed to encode ark address: %w", err)
+	}
+
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Ark:"),
+		valueStyle.Render(encoded),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("  Exit Delay:"),
+		valueStyle.Render(formatLocktimeDuration(exitDelay)),
+	)
+
+	return output, nil
+}
@@ -0,0 +1,26 @@
+package command
+
+import (
+	"fmt"
+
+	arklib "github.com/arkade-os/arkd/pkg/ark-lib"
+)
+
+// relativeLocktime interprets a CSV value as arkd does: blocks below 512, seconds otherwise
+func relativeLocktime(value uint32) arklib.RelativeLocktime {
+	if value >= 512 {
+		return arklib.RelativeLocktime{Type: arklib.LocktimeTypeSecond, Value: value}
+	}
+	return arklib.RelativeLocktime{Type: arklib.LocktimeTypeBlock, Value: value}
+}
+
+// parseRelativeLocktime is relativeLocktime rejecting the values it can't encode.
+// BIP68 time locks have a 512 seconds granularity, other values would be encoded as a different delay.
+func parseRelativeLocktime(value uint32) (arklib.RelativeLocktime, error) {
+	if value >= 512 && value%512 != 0 {
+		return arklib.RelativeLocktime{}, fmt.Errorf(
+			"invalid relative locktime %d, seconds must be a multiple of 512", value,
+		)
+	}
+	return relativeLocktime(value), nil
+}
@@ -0,0 +1,21 @@
+package command
+
+import (
+	"testing"
+
+	arklib "github.com/arkade-os/arkd/pkg/ark-lib"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRelativeLocktime(t *testing.T) {
+	locktime, err := parseRelativeLocktime(511)
+	require.NoError(t, err)
+	require.Equal(t, arklib.RelativeLocktime{Type: arklib.LocktimeTypeBlock, Value: 511}, locktime)
+
+	locktime, err = parseRelativeLocktime(1024)
+	require.NoError(t, err)
+	require.Equal(t, arklib.RelativeLocktime{Type: arklib.LocktimeTypeSecond, Value: 1024}, locktime)
+
+	_, err = parseRelativeLocktime(1000)
+	require.EqualError(t, err, "invalid relative locktime 1000, seconds must be a multiple of 512")
+}
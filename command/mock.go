@@ -0,0 +1,299 @@
+package command
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+// MockOptions configures the local mock servers
+type MockOptions struct {
+	// Listen is the address the server listens on
+	Listen string
+	// Fixtures is the JSON file holding the data served, empty to serve nothing
+	Fixtures string
+}
+
+// arkdFixtures is the data served by the arkd mock server
+type arkdFixtures struct {
+	// Info is served by GET /v1/info
+	Info  *serverInfo   `json:"info,omitempty"`
+	Vtxos []indexerVtxo `json:"vtxos"`
+	// VirtualTxs are base64 encoded PSBTs, served by txid
+	VirtualTxs []string `json:"virtualTxs"`
+}
+
+// RunMockArkd serves the arkd indexer REST API from fixtures, to use the indexer
+// commands offline (noa vtxo --server http://<listen>)
+func RunMockArkd(opts MockOptions) error {
+	var fixtures arkdFixtures
+	if err := readFixtures(opts.Fixtures, &fixtures); err != nil {
+		return err
+	}
+
+	handler, err := newMockArkdHandler(fixtures)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s%s\n",
+		commonLabelStyle.Render("Mock arkd listening on:"),
+		valueStyle.Render("http://"+opts.Listen),
+	)
+	fmt.Printf("%s%s\n",
+		commonLabelStyle.Render("Serving:"),
+		valueStyle.Render(fmt.Sprintf("%d vtxos, %d virtual txs", len(fixtures.Vtxos), len(fixtures.VirtualTxs))),
+	)
+
+	return http.ListenAndServe(opts.Listen, handler)
+}
+
+// newMockArkdHandler routes the arkd REST API endpoints used by noa to the fixtures
+func newMockArkdHandler(fixtures arkdFixtures) (http.Handler, error) {
+	virtualTxs := make(map[string]string)
+	for i, tx := range fixtures.VirtualTxs {
+		p, err := parsePsbt(tx)
+		if err != nil {
+			return nil, fmt.Errorf("invalid virtual tx [%d]: %w", i, err)
+		}
+		virtualTxs[p.UnsignedTx.TxID()] = tx
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/info", func(w http.ResponseWriter, r *http.Request) {
+		if fixtures.Info == nil {
+			writeMockError(w, http.StatusNotFound, 5, "server info not in fixtures")
+			return
+		}
+		writeMockJSON(w, fixtures.Info)
+	})
+	mux.HandleFunc("GET /v1/indexer/vtxos", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		outpoints := splitQueryValues(query["outpoints"])
+		scripts := splitQueryValues(query["scripts"])
+		if len(outpoints) == 0 && len(scripts) == 0 {
+			writeMockError(w, http.StatusBadRequest, 3, "missing outpoints or scripts filter")
+			return
+		}
+
+		matching := make([]indexerVtxo, 0)
+		for _, vtxo := range fixtures.Vtxos {
+			if slices.Contains(outpoints, vtxo.Outpoint.String()) || slices.Contains(scripts, vtxo.Script) {
+				matching = append(matching, vtxo)
+			}
+		}
+
+		page, pageItems := paginate(matching, query.Get("page.index"), query.Get("page.size"))
+		writeMockJSON(w, indexerVtxosResponse{Vtxos: pageItems, Page: page})
+	})
+	mux.HandleFunc("GET /v1/indexer/virtualTx/{txids}", func(w http.ResponseWriter, r *http.Request) {
+		txs := make([]string, 0)
+		for _, txid := range strings.Split(r.PathValue("txids"), ",") {
+			tx, ok := virtualTxs[txid]
+			if !ok {
+				writeMockError(w, http.StatusNotFound, 5, fmt.Sprintf("virtual tx %s not found", txid))
+				return
+			}
+			txs = append(txs, tx)
+		}
+		writeMockJSON(w, indexerVirtualTxsResponse{Txs: txs})
+	})
+
+	return mux, nil
+}
+
+// esploraFixtures is the data served by the Esplora mock server
+type esploraFixtures struct {
+	// Blocks are served by hash, the highest one being the tip
+	Blocks []esploraBlock     `json:"blocks"`
+	Txs    []esploraTxFixture `json:"txs"`
+}
+
+// esploraTxFixture is a raw transaction served with its confirmation status
+type esploraTxFixture struct {
+	Hex    string        `json:"hex"`
+	Status esploraStatus `json:"status"`
+}
+
+// RunMockEsplora serves the Esplora endpoints used by --esplora from fixtures,
+// to use them offline (noa tx decode --esplora http://<listen>)
+func RunMockEsplora(opts MockOptions) error {
+	var fixtures esploraFixtures
+	if err := readFixtures(opts.Fixtures, &fixtures); err != nil {
+		return err
+	}
+
+	handler, err := newMockEsploraHandler(fixtures)
+	if err != nil {
+		return err
+	}
+
+	tip := esploraTip(fixtures.Blocks)
+	fmt.Printf("%s%s\n",
+		commonLabelStyle.Render("Mock esplora listening on:"),
+		valueStyle.Render("http://"+opts.Listen),
+	)
+	fmt.Printf("%s%s\n",
+		commonLabelStyle.Render("Serving:"),
+		valueStyle.Render(fmt.Sprintf("%d blocks (tip %d), %d txs", len(fixtures.Blocks), tip.Height, len(fixtures.Txs))),
+	)
+
+	return http.ListenAndServe(opts.Listen, handler)
+}
+
+// esploraTip returns the highest block
+func esploraTip(blocks []esploraBlock) esploraBlock {
+	tip := blocks[0]
+	for _, block := range blocks {
+		if block.Height > tip.Height {
+			tip = block
+		}
+	}
+	return tip
+}
+
+// newMockEsploraHandler routes the Esplora endpoints used by noa to the fixtures
+func newMockEsploraHandler(fixtures esploraFixtures) (http.Handler, error) {
+	if len(fixtures.Blocks) == 0 {
+		return nil, fmt.Errorf("fixtures must hold at least one block, the highest being the tip")
+	}
+
+	tip := esploraTip(fixtures.Blocks)
+	blocks := make(map[string]esploraBlock)
+	for _, block := range fixtures.Blocks {
+		blocks[block.ID] = block
+	}
+
+	txs := make(map[string]esploraTx)
+	rawTxs := make(map[string]string)
+	for i, fixture := range fixtures.Txs {
+		txBytes, err := hex.DecodeString(fixture.Hex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tx [%d]: %w", i, err)
+		}
+		var tx wire.MsgTx
+		if err := tx.Deserialize(bytes.NewReader(txBytes)); err != nil {
+			return nil, fmt.Errorf("invalid tx [%d]: %w", i, err)
+		}
+
+		esploraTx := esploraTx{
+			Txid:     tx.TxID(),
+			Version:  tx.Version,
+			Locktime: tx.LockTime,
+			Status:   fixture.Status,
+		}
+		for _, txOut := range tx.TxOut {
+			esploraTx.Vout = append(esploraTx.Vout, esploraVout{
+				ScriptPubKey: hex.EncodeToString(txOut.PkScript),
+				Value:        txOut.Value,
+			})
+		}
+		txs[esploraTx.Txid] = esploraTx
+		rawTxs[esploraTx.Txid] = fixture.Hex
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /blocks/tip/hash", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, tip.ID)
+	})
+	mux.HandleFunc("GET /blocks/tip/height", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, tip.Height)
+	})
+	mux.HandleFunc("GET /block/{hash}", func(w http.ResponseWriter, r *http.Request) {
+		block, ok := blocks[r.PathValue("hash")]
+		if !ok {
+			http.Error(w, "Block not found", http.StatusNotFound)
+			return
+		}
+		writeMockJSON(w, block)
+	})
+	mux.HandleFunc("GET /tx/{txid}", func(w http.ResponseWriter, r *http.Request) {
+		tx, ok := txs[r.PathValue("txid")]
+		if !ok {
+			http.Error(w, "Transaction not found", http.StatusNotFound)
+			return
+		}
+		writeMockJSON(w, tx)
+	})
+	mux.HandleFunc("GET /tx/{txid}/status", func(w http.ResponseWriter, r *http.Request) {
+		tx, ok := txs[r.PathValue("txid")]
+		if !ok {
+			http.Error(w, "Transaction not found", http.StatusNotFound)
+			return
+		}
+		writeMockJSON(w, tx.Status)
+	})
+	mux.HandleFunc("GET /tx/{txid}/hex", func(w http.ResponseWriter, r *http.Request) {
+		raw, ok := rawTxs[r.PathValue("txid")]
+		if !ok {
+			http.Error(w, "Transaction not found", http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, raw)
+	})
+
+	return mux, nil
+}
+
+// readFixtures decodes the JSON fixtures file, leaving out untouched if no file is given
+func readFixtures(path string, out any) error {
+	if path == "" {
+		return nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read fixtures: %w", err)
+	}
+	if err := json.Unmarshal(content, out); err != nil {
+		return fmt.Errorf("failed to decode fixtures: %w", err)
+	}
+	return nil
+}
+
+// splitQueryValues accepts repeated and comma separated query values
+func splitQueryValues(values []string) []string {
+	split := make([]string, 0, len(values))
+	for _, value := range values {
+		split = append(split, strings.Split(value, ",")...)
+	}
+	return split
+}
+
+// paginate returns the requested page of items, all of them if no page size is given
+func paginate[T any](items []T, indexParam, sizeParam string) (*indexerPage, []T) {
+	size, err := strconv.Atoi(sizeParam)
+	if err != nil || size <= 0 {
+		return nil, items
+	}
+	index, err := strconv.Atoi(indexParam)
+	if err != nil || index < 0 {
+		index = 0
+	}
+
+	total := (len(items) + size - 1) / size
+	start := min(index*size, len(items))
+	end := min(start+size, len(items))
+	return &indexerPage{Current: int32(index), Next: int32(min(index+1, total)), Total: int32(total)}, items[start:end]
+}
+
+func writeMockJSON(w http.ResponseWriter, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// writeMockError writes an error as the REST gateway does, with its gRPC code
+func writeMockError(w http.ResponseWriter, status, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(indexerError{Code: code, Message: message})
+}
@@ -0,0 +1,543 @@
+package command
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/arkade-os/arkd/pkg/ark-lib/tree"
+	"github.com/arkade-os/arkd/pkg/ark-lib/txutils"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr/musig2"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+// MusigTweakOptions describes the taproot tweak applied to the aggregated key.
+// The tweak is either the merkle root of the taptree or computed from the single sweep leaf of a tree node.
+type MusigTweakOptions struct {
+	Tweak       string
+	SweepScript string
+}
+
+func RunMusigAggregate(pubKeys []string, opts MusigTweakOptions) error {
+	keys := make([]*btcec.PublicKey, 0, len(pubKeys))
+	for _, pubKey := range pubKeys {
+		key, err := parseMusigPubKey(pubKey)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, key)
+	}
+
+	tweak, err := musigTweak(opts)
+	if err != nil {
+		return err
+	}
+
+	aggregatedKey, err := tree.AggregateKeys(keys, tweak)
+	if err != nil {
+		return fmt.Errorf("failed to aggregate keys: %w", err)
+	}
+
+	pkScript, err := txscript.PayToTaprootScript(aggregatedKey.FinalKey)
+	if err != nil {
+		return fmt.Errorf("failed to compute taproot script: %w", err)
+	}
+
+	var output string
+
+	output += fmt.Sprintf("\n%s\n",
+		sectionStyle.Render("MuSig2 Key Aggregation:"),
+	)
+	output += fmt.Sprintf("%s\n",
+		subLabelStyle.Render("Sorted Keys:"),
+	)
+	for i, key := range sortPubKeys(keys) {
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render(fmt.Sprintf("  [%d]:", i)),
+			valueStyle.Render(hex.EncodeToString(key.SerializeCompressed())),
+		)
+	}
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Aggregated Key:"),
+		valueStyle.Render(hex.EncodeToString(schnorr.SerializePubKey(aggregatedKey.PreTweakedKey))),
+	)
+	if len(tweak) > 0 {
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render("Taproot Tweak:"),
+			valueStyle.Render(hex.EncodeToString(tweak)),
+		)
+	}
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Output Key:"),
+		valueStyle.Render(hex.EncodeToString(schnorr.SerializePubKey(aggregatedKey.FinalKey))),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Script:"),
+		valueStyle.Render(hex.EncodeToString(pkScript)),
+	)
+
+	fmt.Print(output)
+	return nil
+}
+
+// RunMusigNonces decodes the public nonces of a tree signing session, formatted as a JSON map of txid to nonce
+func RunMusigNonces(noncesInput string, treeInputs []string) error {
+	content, err := readArg(noncesInput)
+	if err != nil {
+		return fmt.Errorf("failed to read nonces: %w", err)
+	}
+
+	var nonces tree.TreeNonces
+	if err := json.Unmarshal([]byte(content), &nonces); err != nil {
+		return fmt.Errorf("failed to parse nonces: %w", err)
+	}
+
+	txids := make([]string, 0, len(nonces))
+	for txid := range nonces {
+		txids = append(txids, txid)
+	}
+
+	var output string
+
+	output += fmt.Sprintf("\n%s\n",
+		sectionStyle.Render(fmt.Sprintf("MuSig2 Nonces (%d):", len(nonces))),
+	)
+	for _, txid := range sortedStrings(txids) {
+		pubNonce := nonces[txid].PubNonce
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render("TxId:"),
+			valueStyle.Render(txid),
+		)
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render("  PubNonce:"),
+			valueStyle.Render(hex.EncodeToString(pubNonce[:])),
+		)
+		for i, r := range [][]byte{pubNonce[:33], pubNonce[33:]} {
+			label := fmt.Sprintf("  R%d:", i+1)
+			if _, err := btcec.ParsePubKey(r); err != nil {
+				output += fmt.Sprintf("%s%s\n",
+					subLabelStyle.Render(label),
+					invalidStyle.Render(fmt.Sprintf("%x (invalid point: %s)", r, err)),
+				)
+				continue
+			}
+			output += fmt.Sprintf("%s%s\n",
+				subLabelStyle.Render(label),
+				valueStyle.Render(hex.EncodeToString(r)),
+			)
+		}
+	}
+
+	if len(treeInputs) > 0 {
+		coverage, err := formatMusigTreeCoverage(treeInputs, txids)
+		if err != nil {
+			return err
+		}
+		output += coverage
+	}
+
+	fmt.Print(output)
+	return nil
+}
+
+// RunMusigPartialSigs decodes the partial signatures of a tree signing session,
+// formatted as a JSON map of txid to partial signature
+func RunMusigPartialSigs(sigsInput string, treeInputs []string) error {
+	content, err := readArg(sigsInput)
+	if err != nil {
+		return fmt.Errorf("failed to read partial signatures: %w", err)
+	}
+
+	// TreePartialSigs.UnmarshalJSON doesn't allocate the map
+	sigs := make(tree.TreePartialSigs)
+	if err := json.Unmarshal([]byte(content), &sigs); err != nil {
+		return fmt.Errorf("failed to parse partial signatures: %w", err)
+	}
+
+	txids := make([]string, 0, len(sigs))
+	for txid := range sigs {
+		txids = append(txids, txid)
+	}
+
+	var output string
+
+	output += fmt.Sprintf("\n%s\n",
+		sectionStyle.Render(fmt.Sprintf("MuSig2 Partial Signatures (%d):", len(sigs))),
+	)
+	for _, txid := range sortedStrings(txids) {
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render("TxId:"),
+			valueStyle.Render(txid),
+		)
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render("  S:"),
+			valueStyle.Render(encodePartialSig(sigs[txid])),
+		)
+	}
+
+	if len(treeInputs) > 0 {
+		coverage, err := formatMusigTreeCoverage(treeInputs, txids)
+		if err != nil {
+			return err
+		}
+		output += coverage
+	}
+
+	fmt.Print(output)
+	return nil
+}
+
+// MusigVerifyOptions describes the partial signature verification.
+// The message is either the given sighash or the key path sighash of the first input of the tx.
+type MusigVerifyOptions struct {
+	MusigTweakOptions
+	Signer      string
+	SignerNonce string
+	// AggNonce is the aggregated nonce, computed from Nonces if empty
+	AggNonce string
+	Nonces   []string
+	// Cosigners are read from the tx cosigner fields if empty
+	Cosigners []string
+	Sighash   string
+	Tx        string
+	// Parent is the tx spent by Tx (parent node or commitment tx), tree txs don't carry their prevout
+	Parent string
+}
+
+func RunMusigVerify(partialSigInput string, opts MusigVerifyOptions) error {
+	partialSigBytes, err := hex.DecodeString(partialSigInput)
+	if err != nil {
+		return fmt.Errorf("invalid partial signature: %w", err)
+	}
+	partialSig := &musig2.PartialSignature{}
+	if err := partialSig.Decode(bytes.NewReader(partialSigBytes)); err != nil {
+		return fmt.Errorf("invalid partial signature: %w", err)
+	}
+
+	signer, err := parsePubKey(opts.Signer)
+	if err != nil {
+		return err
+	}
+
+	signerNonce, err := parsePubNonce(opts.SignerNonce)
+	if err != nil {
+		return err
+	}
+
+	var aggNonce [musig2.PubNonceSize]byte
+	if opts.AggNonce != "" {
+		aggNonce, err = parsePubNonce(opts.AggNonce)
+		if err != nil {
+			return err
+		}
+	} else {
+		nonces := make([][musig2.PubNonceSize]byte, 0, len(opts.Nonces))
+		for _, nonce := range opts.Nonces {
+			pubNonce, err := parsePubNonce(nonce)
+			if err != nil {
+				return err
+			}
+			nonces = append(nonces, pubNonce)
+		}
+		if len(nonces) == 0 {
+			return fmt.Errorf("missing aggregated nonce or cosigner nonces")
+		}
+		aggNonce, err = musig2.AggregateNonces(nonces)
+		if err != nil {
+			return fmt.Errorf("failed to aggregate nonces: %w", err)
+		}
+	}
+
+	var tx *psbt.Packet
+	if opts.Tx != "" {
+		txArg, err := readArg(opts.Tx)
+		if err != nil {
+			return fmt.Errorf("failed to read tx: %w", err)
+		}
+		tx, err = parsePsbt(txArg)
+		if err != nil {
+			return err
+		}
+
+		if opts.Parent != "" {
+			if err := setPrevoutFromParent(tx, opts.Parent); err != nil {
+				return err
+			}
+		}
+	}
+
+	cosigners := make([]*btcec.PublicKey, 0, len(opts.Cosigners))
+	for _, cosigner := range opts.Cosigners {
+		key, err := parseMusigPubKey(cosigner)
+		if err != nil {
+			return err
+		}
+		cosigners = append(cosigners, key)
+	}
+	if len(cosigners) == 0 && tx != nil {
+		cosigners, err = txutils.ParseCosignerKeysFromArkPsbt(tx, 0)
+		if err != nil {
+			return fmt.Errorf("failed to parse tx cosigners: %w", err)
+		}
+		cosigners = uniquePubKeys(cosigners)
+	}
+	if len(cosigners) == 0 {
+		return fmt.Errorf("missing cosigners")
+	}
+	if !containsPubKey(cosigners, signer) {
+		return fmt.Errorf("signer is not one of the cosigners")
+	}
+	// an x-only signer key takes the parity of the matching cosigner
+	for _, cosigner := range cosigners {
+		if containsPubKey([]*btcec.PublicKey{cosigner}, signer) {
+			signer = cosigner
+			break
+		}
+	}
+
+	var message []byte
+	switch {
+	case opts.Sighash != "":
+		message, err = hex.DecodeString(opts.Sighash)
+		if err != nil || len(message) != 32 {
+			return fmt.Errorf("invalid sighash %q, expected 32 bytes hex", opts.Sighash)
+		}
+	case tx != nil:
+		message, err = keySpendSighash(tx, 0)
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("missing sighash or tx")
+	}
+
+	tweak, err := musigTweak(opts.MusigTweakOptions)
+	if err != nil {
+		return err
+	}
+
+	signOpts := []musig2.SignOption{musig2.WithSortedKeys()}
+	if len(tweak) > 0 {
+		signOpts = append(signOpts, musig2.WithTaprootSignTweak(tweak))
+	}
+	valid := partialSig.Verify(signerNonce, aggNonce, cosigners, signer, [32]byte(message), signOpts...)
+
+	var output string
+
+	output += fmt.Sprintf("\n%s\n",
+		sectionStyle.Render("MuSig2 Partial Signature:"),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("S:"),
+		valueStyle.Render(encodePartialSig(partialSig)),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Signer:"),
+		valueStyle.Render(hex.EncodeToString(schnorr.SerializePubKey(signer))),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Cosigners:"),
+		valueStyle.Render(fmt.Sprintf("%d", len(cosigners))),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Aggregated Nonce:"),
+		valueStyle.Render(hex.EncodeToString(aggNonce[:])),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Sighash:"),
+		valueStyle.Render(hex.EncodeToString(message)),
+	)
+	if len(tweak) > 0 {
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render("Taproot Tweak:"),
+			valueStyle.Render(hex.EncodeToString(tweak)),
+		)
+	}
+	if valid {
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render("Status:"),
+			validStyle.Render("valid"),
+		)
+	} else {
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render("Status:"),
+			invalidStyle.Render("invalid"),
+		)
+	}
+
+	fmt.Print(output)
+	return nil
+}
+
+// musigTweak returns the taproot tweak to apply to the aggregated key, nil if none
+func musigTweak(opts MusigTweakOptions) ([]byte, error) {
+	if opts.Tweak != "" && opts.SweepScript != "" {
+		return nil, fmt.Errorf("tweak and sweep script are mutually exclusive")
+	}
+
+	if opts.Tweak != "" {
+		tweak, err := hex.DecodeString(opts.Tweak)
+		if err != nil || len(tweak) != 32 {
+			return nil, fmt.Errorf("invalid tweak %q, expected 32 bytes hex", opts.Tweak)
+		}
+		return tweak, nil
+	}
+
+	if opts.SweepScript != "" {
+		sweepScript, err := hex.DecodeString(opts.SweepScript)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sweep script: %w", err)
+		}
+		root := txscript.AssembleTaprootScriptTree(txscript.NewBaseTapLeaf(sweepScript)).RootNode.TapHash()
+		return root[:], nil
+	}
+
+	return nil, nil
+}
+
+// keySpendSighash computes the SIGHASH_DEFAULT key path sighash of the input, as signed by tree cosigners
+func keySpendSighash(p *psbt.Packet, inputIndex int) ([]byte, error) {
+	prevouts, ok := psbtPrevouts(p)
+	if !ok {
+		return nil, fmt.Errorf("missing prevouts, can't compute the sighash (use --parent for tree txs)")
+	}
+
+	fetcher := txscript.NewMultiPrevOutFetcher(prevouts)
+	sighash, err := txscript.CalcTaprootSignatureHash(
+		txscript.NewTxSigHashes(p.UnsignedTx, fetcher),
+		txscript.SigHashDefault, p.UnsignedTx, inputIndex, fetcher,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute sighash: %w", err)
+	}
+	return sighash, nil
+}
+
+// setPrevoutFromParent sets the witness utxo of the first input from the parent tx output it spends
+func setPrevoutFromParent(p *psbt.Packet, parentInput string) error {
+	parentArg, err := readArg(parentInput)
+	if err != nil {
+		return fmt.Errorf("failed to read parent tx: %w", err)
+	}
+	parent, err := parsePsbt(parentArg)
+	if err != nil {
+		return fmt.Errorf("parent tx: %w", err)
+	}
+
+	prevout := p.UnsignedTx.TxIn[0].PreviousOutPoint
+	if prevout.Hash != parent.UnsignedTx.TxHash() || int(prevout.Index) >= len(parent.UnsignedTx.TxOut) {
+		return fmt.Errorf("tx does not spend an output of the parent tx %s", parent.UnsignedTx.TxID())
+	}
+	p.Inputs[0].WitnessUtxo = parent.UnsignedTx.TxOut[prevout.Index]
+	return nil
+}
+
+// parseMusigPubKey parses a compressed public key. MuSig2 key aggregation commits to the
+// parity of the keys, an x-only key could silently give a different aggregated key.
+func parseMusigPubKey(s string) (*btcec.PublicKey, error) {
+	keyBytes, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key %q: %w", s, err)
+	}
+	if len(keyBytes) != btcec.PubKeyBytesLenCompressed {
+		return nil, fmt.Errorf("invalid public key %q, musig2 aggregation requires a 33 bytes compressed key", s)
+	}
+	return btcec.ParsePubKey(keyBytes)
+}
+
+func parsePubNonce(s string) ([musig2.PubNonceSize]byte, error) {
+	var pubNonce [musig2.PubNonceSize]byte
+	nonceBytes, err := hex.DecodeString(s)
+	if err != nil || len(nonceBytes) != musig2.PubNonceSize {
+		return pubNonce, fmt.Errorf("invalid nonce %q, expected %d bytes hex", s, musig2.PubNonceSize)
+	}
+	copy(pubNonce[:], nonceBytes)
+	return pubNonce, nil
+}
+
+func encodePartialSig(sig *musig2.PartialSignature) string {
+	var buf bytes.Buffer
+	if sig == nil || sig.Encode(&buf) != nil {
+		return ""
+	}
+	return hex.EncodeToString(buf.Bytes())
+}
+
+// formatMusigTreeCoverage checks every tree tx has an entry and every entry matches a tree tx
+func formatMusigTreeCoverage(treeInputs []string, txids []string) (string, error) {
+	txTree, err := parseTxTree(treeInputs)
+	if err != nil {
+		return "", err
+	}
+
+	treeTxids := make(map[string]bool)
+	if err := txTree.Apply(func(node *tree.TxTree) (bool, error) {
+		treeTxids[node.Root.UnsignedTx.TxID()] = true
+		return true, nil
+	}); err != nil {
+		return "", err
+	}
+
+	covered := make(map[string]bool, len(txids))
+	issues := make([]string, 0)
+	for _, txid := range sortedStrings(txids) {
+		covered[txid] = true
+		if !treeTxids[txid] {
+			issues = append(issues, fmt.Sprintf("%s is not a tx of the tree", txid))
+		}
+	}
+	missing := make([]string, 0)
+	for txid := range treeTxids {
+		if !covered[txid] {
+			missing = append(missing, txid)
+		}
+	}
+	for _, txid := range sortedStrings(missing) {
+		issues = append(issues, fmt.Sprintf("tree tx %s is missing", txid))
+	}
+
+	var output string
+	output += fmt.Sprintf("\n%s\n",
+		sectionStyle.Render("Tree Coverage:"),
+	)
+	if len(issues) == 0 {
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render("Status:"),
+			validStyle.Render(fmt.Sprintf("complete (%d txs)", len(treeTxids))),
+		)
+		return output, nil
+	}
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Status:"),
+		invalidStyle.Render("incomplete"),
+	)
+	for _, issue := range issues {
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render("  -"),
+			invalidStyle.Render(issue),
+		)
+	}
+	return output, nil
+}
+
+// sortPubKeys sorts the keys as MuSig2 does before aggregating them
+func sortPubKeys(keys []*btcec.PublicKey) []*btcec.PublicKey {
+	sorted := make([]*btcec.PublicKey, len(keys))
+	copy(sorted, keys)
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i].SerializeCompressed(), sorted[j].SerializeCompressed()) < 0
+	})
+	return sorted
+}
+
+func sortedStrings(values []string) []string {
+	sorted := make([]string, len(values))
+	copy(sorted, values)
+	sort.Strings(sorted)
+	return sorted
+}
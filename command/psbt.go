@@ -3,7 +3,6 @@ package command
 import (
 	"bytes"
 	"encoding/base64"
-	"encoding/binary"
 	"encoding/hex"
 	"fmt"
 	"strings"
@@ -14,24 +13,53 @@ import (
 	"github.com/btcsuite/btcd/txscript"
 )
 
-func RunPsbtDecode(psbtInput string) error {
-	var psbtBytes []byte
-	var err error
+// PsbtDecodeOptions tunes the output of RunPsbtDecode
+type PsbtDecodeOptions struct {
+	// Verify checks the taproot signatures of every input
+	Verify bool
+	// Sweep locates the sweep paths of the inputs in time
+	Sweep SweepOptions
+	// Addresses displays the addresses of the outputs and of the inputs with known leaves
+	Addresses AddressOptions
+	// Esplora fetches the missing prevouts and the onchain status of the transaction
+	Esplora EsploraOptions
+	// Server checks the input taptrees, its signer and network being used for the addresses if not given
+	Server ServerCheckOptions
+}
 
-	// Try base64 first (most common for PSBT)
-	psbtBytes, err = base64.StdEncoding.DecodeString(strings.TrimSpace(psbtInput))
+func RunPsbtDecode(psbtInput string, opts PsbtDecodeOptions) error {
+	p, err := parsePsbt(psbtInput)
 	if err != nil {
-		// Fall back to hex
-		psbtBytes, err = hex.DecodeString(strings.TrimSpace(psbtInput))
-		if err != nil {
-			return fmt.Errorf("failed to decode PSBT (tried base64 and hex): %w", err)
+		return err
+	}
+
+	profile, err := loadProfile(opts.Server.Profile)
+	if err != nil {
+		return err
+	}
+	if profile != nil {
+		if opts.Addresses.Signer == "" {
+			opts.Addresses.Signer = profile.Info.SignerPubkey
+		}
+		if opts.Addresses.Network == "" {
+			opts.Addresses.Network = profile.Info.Network
 		}
 	}
 
-	// Parse PSBT
-	p, err := psbt.NewFromRawBytes(bytes.NewReader(psbtBytes), false)
+	resolver, err := newAddressResolver(opts.Addresses)
 	if err != nil {
-		return fmt.Errorf("failed to parse PSBT: %w", err)
+		return err
+	}
+
+	onchain, err := newOnchainContext(opts.Esplora)
+	if err != nil {
+		return err
+	}
+	var filled map[int]string
+	if onchain != nil {
+		if filled, err = onchain.fillPrevouts(p); err != nil {
+			return err
+		}
 	}
 
 	var output string
@@ -41,6 +69,59 @@ func RunPsbtDecode(psbtInput string) error {
 		sectionStyle.Render("Global:"),
 	)
 	tx := p.UnsignedTx
+	classification := classifyPsbt(p)
+	xpubs := parseGlobalXpubs(p)
+	output += formatPsbtGlobal(p, classification, xpubs)
+
+	// Inputs
+	output += fmt.Sprintf("\n%s\n",
+		sectionStyle.Render(fmt.Sprintf("Inputs (%d):", len(tx.TxIn))),
+	)
+	for i := range tx.TxIn {
+		output += formatPsbtInput(p, i, classification, xpubs, resolver)
+
+		if i < len(p.Inputs) {
+			// Decode ARK PSBT fields
+			output += formatArkPsbtFields(p, i)
+			output += formatInputSweep(p, i, opts.Sweep)
+			if profile != nil {
+				output += formatInputServerChecks(p, i, profile)
+			}
+
+			if opts.Verify {
+				output += formatSignatureChecks(verifyInputSignatures(p, i))
+			}
+		}
+	}
+
+	// Outputs
+	output += fmt.Sprintf("\n%s\n",
+		sectionStyle.Render(fmt.Sprintf("Outputs (%d):", len(tx.TxOut))),
+	)
+	for i := range tx.TxOut {
+		output += formatPsbtOutput(p, i, classification, xpubs, resolver)
+	}
+
+	if onchain != nil {
+		onchainOutput, err := onchain.formatOnchainContext(tx, psbtLeafScripts(p), filled)
+		if err != nil {
+			return err
+		}
+		output += onchainOutput
+	}
+
+	output += formatPsbtSummary(summarizePsbt(p))
+
+	fmt.Print(output)
+	return nil
+}
+
+// formatPsbtGlobal formats the detected Ark transaction type and the global fields of a PSBT
+func formatPsbtGlobal(p *psbt.Packet, classification txClassification, xpubs []globalXpub) string {
+	var output string
+
+	tx := p.UnsignedTx
+	output += formatClassification(classification)
 	output += fmt.Sprintf("%s%s\n",
 		subLabelStyle.Render("Version:"),
 		valueStyle.Render(fmt.Sprintf("%d", tx.Version)),
@@ -55,151 +136,274 @@ func RunPsbtDecode(psbtInput string) error {
 			valueStyle.Render(tx.TxHash().String()),
 		)
 	}
-
-	// Inputs
-	output += fmt.Sprintf("\n%s\n",
-		sectionStyle.Render(fmt.Sprintf("Inputs (%d):", len(tx.TxIn))),
-	)
-	for i, txIn := range tx.TxIn {
+	if len(xpubs) > 0 {
 		output += fmt.Sprintf("%s\n",
-			subLabelStyle.Render(fmt.Sprintf("[%d]:", i)),
-		)
-		output += fmt.Sprintf("%s%s\n",
-			subLabelStyle.Render("  PreviousOutPoint:"),
-			valueStyle.Render(txIn.PreviousOutPoint.String()),
-		)
-		output += fmt.Sprintf("%s%s\n",
-			subLabelStyle.Render("  Sequence:"),
-			valueStyle.Render(fmt.Sprintf("%d", txIn.Sequence)),
+			subLabelStyle.Render("Xpubs:"),
 		)
+		for _, xpub := range xpubs {
+			output += fmt.Sprintf("%s%s\n",
+				subLabelStyle.Render("  -"),
+				valueStyle.Render(fmt.Sprintf("[%s]%s", formatKeyOrigin(xpub.Fingerprint, xpub.Path), xpub.Xpub)),
+			)
+		}
+	}
 
-		// PSBT input specific data
-		if i < len(p.Inputs) {
-			in := p.Inputs[i]
-			if in.RedeemScript != nil {
-				output += fmt.Sprintf("%s%s\n",
-					subLabelStyle.Render("  RedeemScript:"),
-					valueStyle.Render(hex.EncodeToString(in.RedeemScript)),
-				)
-			}
-			if in.WitnessScript != nil {
-				output += fmt.Sprintf("%s%s\n",
-					subLabelStyle.Render("  WitnessScript:"),
-					valueStyle.Render(hex.EncodeToString(in.WitnessScript)),
-				)
-			}
-			if len(in.Bip32Derivation) > 0 {
-				output += fmt.Sprintf("%s\n",
-					subLabelStyle.Render("  Bip32Derivation:"),
-				)
-				for j, der := range in.Bip32Derivation {
-					fpBytes := make([]byte, 4)
-					binary.BigEndian.PutUint32(fpBytes, der.MasterKeyFingerprint)
-					output += fmt.Sprintf("%s%s\n",
-						subLabelStyle.Render(fmt.Sprintf("    [%d] MasterFingerprint:", j)),
-						valueStyle.Render(hex.EncodeToString(fpBytes)),
-					)
-					output += fmt.Sprintf("%s%s\n",
-						subLabelStyle.Render(fmt.Sprintf("    [%d] Path:", j)),
-						valueStyle.Render(formatBip32Path(der.Bip32Path)),
-					)
-					output += fmt.Sprintf("%s%s\n",
-						subLabelStyle.Render(fmt.Sprintf("    [%d] PubKey:", j)),
-						valueStyle.Render(hex.EncodeToString(der.PubKey)),
-					)
-				}
-			}
-			if in.NonWitnessUtxo != nil {
-				output += fmt.Sprintf("%s%s\n",
-					subLabelStyle.Render("  NonWitnessUtxo:"),
-					valueStyle.Render("present"),
-				)
-			}
-			if in.WitnessUtxo != nil {
-				output += fmt.Sprintf("%s\n",
-					subLabelStyle.Render("  WitnessUtxo:"),
-				)
-				output += fmt.Sprintf("%s%s\n",
-					subLabelStyle.Render("    Value:"),
-					valueStyle.Render(fmt.Sprintf("%d sats", in.WitnessUtxo.Value)),
-				)
-				output += fmt.Sprintf("%s%s\n",
-					subLabelStyle.Render("    PkScript:"),
-					valueStyle.Render(hex.EncodeToString(in.WitnessUtxo.PkScript)),
-				)
-			}
+	return output
+}
 
-			// Decode ARK PSBT fields
-			output += formatArkPsbtFields(p, i)
+// formatPsbtInput formats an input of a PSBT with its BIP32, UTXO and taproot fields,
+// the ARK PSBT fields being formatted apart by formatArkPsbtFields
+func formatPsbtInput(p *psbt.Packet, inputIndex int, classification txClassification, xpubs []globalXpub, resolver *addressResolver) string {
+	var output string
+
+	txIn := p.UnsignedTx.TxIn[inputIndex]
+	output += fmt.Sprintf("%s\n",
+		subLabelStyle.Render(fmt.Sprintf("[%d]:", inputIndex)),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("  Role:"),
+		valueStyle.Render(classification.InputRoles[inputIndex]),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("  PreviousOutPoint:"),
+		valueStyle.Render(txIn.PreviousOutPoint.String()),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("  Sequence:"),
+		valueStyle.Render(fmt.Sprintf("%d", txIn.Sequence)),
+	)
+
+	// PSBT input specific data
+	if inputIndex < len(p.Inputs) {
+		in := p.Inputs[inputIndex]
+		if in.RedeemScript != nil {
+			output += fmt.Sprintf("%s%s\n",
+				subLabelStyle.Render("  RedeemScript:"),
+				valueStyle.Render(hex.EncodeToString(in.RedeemScript)),
+			)
+		}
+		if in.WitnessScript != nil {
+			output += fmt.Sprintf("%s%s\n",
+				subLabelStyle.Render("  WitnessScript:"),
+				valueStyle.Render(hex.EncodeToString(in.WitnessScript)),
+			)
+		}
+		if len(in.Bip32Derivation) > 0 {
+			output += formatBip32Derivations(in.Bip32Derivation, inputPkScript(in, txIn), in.RedeemScript, xpubs)
+		}
+		if in.NonWitnessUtxo != nil {
+			output += fmt.Sprintf("%s%s\n",
+				subLabelStyle.Render("  NonWitnessUtxo:"),
+				valueStyle.Render("present"),
+			)
+		}
+		if in.WitnessUtxo != nil {
+			output += fmt.Sprintf("%s\n",
+				subLabelStyle.Render("  WitnessUtxo:"),
+			)
+			output += fmt.Sprintf("%s%s\n",
+				subLabelStyle.Render("    Value:"),
+				valueStyle.Render(fmt.Sprintf("%d sats", in.WitnessUtxo.Value)),
+			)
+			output += fmt.Sprintf("%s%s\n",
+				subLabelStyle.Render("    PkScript:"),
+				valueStyle.Render(hex.EncodeToString(in.WitnessUtxo.PkScript)),
+			)
+		}
+
+		output += formatTaprootInputFields(in)
+		if len(in.TaprootBip32Derivation) > 0 {
+			output += formatTaprootBip32Derivations(in.TaprootBip32Derivation, in.TaprootInternalKey, in.TaprootLeafScript, xpubs)
+		}
+		if resolver != nil {
+			output += resolver.formatInputAddresses(p, inputIndex)
 		}
 	}
 
-	// Outputs
-	output += fmt.Sprintf("\n%s\n",
-		sectionStyle.Render(fmt.Sprintf("Outputs (%d):", len(tx.TxOut))),
+	return output
+}
+
+// formatPsbtOutput formats an output of a PSBT with its script, addresses and BIP32 fields
+func formatPsbtOutput(p *psbt.Packet, outputIndex int, classification txClassification, xpubs []globalXpub, resolver *addressResolver) string {
+	var output string
+
+	txOut := p.UnsignedTx.TxOut[outputIndex]
+	output += fmt.Sprintf("%s\n",
+		subLabelStyle.Render(fmt.Sprintf("[%d]:", outputIndex)),
 	)
-	for i, txOut := range tx.TxOut {
-		output += fmt.Sprintf("%s\n",
-			subLabelStyle.Render(fmt.Sprintf("[%d]:", i)),
-		)
-		output += fmt.Sprintf("%s%s\n",
-			subLabelStyle.Render("  Value:"),
-			valueStyle.Render(fmt.Sprintf("%d sats", txOut.Value)),
-		)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("  Role:"),
+		valueStyle.Render(classification.OutputRoles[outputIndex]),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("  Value:"),
+		valueStyle.Render(fmt.Sprintf("%d sats", txOut.Value)),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("  PkScript:"),
+		valueStyle.Render(hex.EncodeToString(txOut.PkScript)),
+	)
+
+	disasm, err := txscript.DisasmString(txOut.PkScript)
+	if err == nil {
 		output += fmt.Sprintf("%s%s\n",
-			subLabelStyle.Render("  PkScript:"),
-			valueStyle.Render(hex.EncodeToString(txOut.PkScript)),
+			subLabelStyle.Render("  Script ASM:"),
+			valueStyle.Render(disasm),
 		)
+	}
+	if resolver != nil {
+		output += resolver.formatOutputAddresses(txOut.PkScript)
+	}
 
-		disasm, err := txscript.DisasmString(txOut.PkScript)
-		if err == nil {
+	// PSBT output specific data
+	if outputIndex < len(p.Outputs) {
+		out := p.Outputs[outputIndex]
+		if out.RedeemScript != nil {
 			output += fmt.Sprintf("%s%s\n",
-				subLabelStyle.Render("  Script ASM:"),
-				valueStyle.Render(disasm),
+				subLabelStyle.Render("  RedeemScript:"),
+				valueStyle.Render(hex.EncodeToString(out.RedeemScript)),
 			)
 		}
+		if out.WitnessScript != nil {
+			output += fmt.Sprintf("%s%s\n",
+				subLabelStyle.Render("  WitnessScript:"),
+				valueStyle.Render(hex.EncodeToString(out.WitnessScript)),
+			)
+		}
+		if len(out.Bip32Derivation) > 0 {
+			output += formatBip32Derivations(out.Bip32Derivation, txOut.PkScript, out.RedeemScript, xpubs)
+		}
+		if len(out.TaprootInternalKey) > 0 {
+			output += fmt.Sprintf("%s%s\n",
+				subLabelStyle.Render("  TaprootInternalKey:"),
+				valueStyle.Render(hex.EncodeToString(out.TaprootInternalKey)),
+			)
+		}
+		if len(out.TaprootBip32Derivation) > 0 {
+			output += formatTaprootBip32Derivations(out.TaprootBip32Derivation, out.TaprootInternalKey, nil, xpubs)
+		}
+	}
 
-		// PSBT output specific data
-		if i < len(p.Outputs) {
-			out := p.Outputs[i]
-			if out.RedeemScript != nil {
-				output += fmt.Sprintf("%s%s\n",
-					subLabelStyle.Render("  RedeemScript:"),
-					valueStyle.Render(hex.EncodeToString(out.RedeemScript)),
-				)
-			}
-			if out.WitnessScript != nil {
+	return output
+}
+
+// parsePsbt decodes a PSBT given as base64 or hex
+func parsePsbt(psbtInput string) (*psbt.Packet, error) {
+	var psbtBytes []byte
+	var err error
+
+	// Try base64 first (most common for PSBT)
+	psbtBytes, err = base64.StdEncoding.DecodeString(strings.TrimSpace(psbtInput))
+	if err != nil {
+		// Fall back to hex
+		psbtBytes, err = hex.DecodeString(strings.TrimSpace(psbtInput))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode PSBT (tried base64 and hex): %w", err)
+		}
+	}
+
+	// Parse PSBT
+	p, err := psbt.NewFromRawBytes(bytes.NewReader(psbtBytes), false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse PSBT: %w", err)
+	}
+
+	return p, nil
+}
+
+// formatTaprootInputFields formats the BIP-371 taproot fields of a PSBT input
+func formatTaprootInputFields(in psbt.PInput) string {
+	var output string
+
+	if len(in.TaprootKeySpendSig) > 0 {
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render("  TaprootKeySpendSig:"),
+			valueStyle.Render(hex.EncodeToString(in.TaprootKeySpendSig)),
+		)
+	}
+	if len(in.TaprootScriptSpendSig) > 0 {
+		output += fmt.Sprintf("%s\n",
+			subLabelStyle.Render("  TaprootScriptSpendSig:"),
+		)
+		for j, sig := range in.TaprootScriptSpendSig {
+			output += fmt.Sprintf("%s%s\n",
+				subLabelStyle.Render(fmt.Sprintf("    [%d] PubKey:", j)),
+				valueStyle.Render(hex.EncodeToString(sig.XOnlyPubKey)),
+			)
+			output += fmt.Sprintf("%s%s\n",
+				subLabelStyle.Render(fmt.Sprintf("    [%d] LeafHash:", j)),
+				valueStyle.Render(hex.EncodeToString(sig.LeafHash)),
+			)
+			output += fmt.Sprintf("%s%s\n",
+				subLabelStyle.Render(fmt.Sprintf("    [%d] Signature:", j)),
+				valueStyle.Render(hex.EncodeToString(sig.Signature)),
+			)
+			output += fmt.Sprintf("%s%s\n",
+				subLabelStyle.Render(fmt.Sprintf("    [%d] SigHash:", j)),
+				valueStyle.Render(formatSigHashType(sig.SigHash)),
+			)
+		}
+	}
+	if len(in.TaprootLeafScript) > 0 {
+		output += fmt.Sprintf("%s\n",
+			subLabelStyle.Render("  TaprootLeafScript:"),
+		)
+		for j, leaf := range in.TaprootLeafScript {
+			output += fmt.Sprintf("%s%s\n",
+				subLabelStyle.Render(fmt.Sprintf("    [%d] ControlBlock:", j)),
+				valueStyle.Render(hex.EncodeToString(leaf.ControlBlock)),
+			)
+			output += fmt.Sprintf("%s%s\n",
+				subLabelStyle.Render(fmt.Sprintf("    [%d] Script:", j)),
+				valueStyle.Render(hex.EncodeToString(leaf.Script)),
+			)
+			disasm, err := txscript.DisasmString(leaf.Script)
+			if err == nil {
 				output += fmt.Sprintf("%s%s\n",
-					subLabelStyle.Render("  WitnessScript:"),
-					valueStyle.Render(hex.EncodeToString(out.WitnessScript)),
+					subLabelStyle.Render(fmt.Sprintf("    [%d] Script ASM:", j)),
+					valueStyle.Render(disasm),
 				)
 			}
-			if len(out.Bip32Derivation) > 0 {
-				output += fmt.Sprintf("%s\n",
-					subLabelStyle.Render("  Bip32Derivation:"),
-				)
-				for j, der := range out.Bip32Derivation {
-					fpBytes := make([]byte, 4)
-					binary.BigEndian.PutUint32(fpBytes, der.MasterKeyFingerprint)
-					output += fmt.Sprintf("%s%s\n",
-						subLabelStyle.Render(fmt.Sprintf("    [%d] MasterFingerprint:", j)),
-						valueStyle.Render(hex.EncodeToString(fpBytes)),
-					)
-					output += fmt.Sprintf("%s%s\n",
-						subLabelStyle.Render(fmt.Sprintf("    [%d] Path:", j)),
-						valueStyle.Render(formatBip32Path(der.Bip32Path)),
-					)
-					output += fmt.Sprintf("%s%s\n",
-						subLabelStyle.Render(fmt.Sprintf("    [%d] PubKey:", j)),
-						valueStyle.Render(hex.EncodeToString(der.PubKey)),
-					)
-				}
-			}
 		}
 	}
+	if len(in.TaprootInternalKey) > 0 {
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render("  TaprootInternalKey:"),
+			valueStyle.Render(hex.EncodeToString(in.TaprootInternalKey)),
+		)
+	}
+	if len(in.TaprootMerkleRoot) > 0 {
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render("  TaprootMerkleRoot:"),
+			valueStyle.Render(hex.EncodeToString(in.TaprootMerkleRoot)),
+		)
+	}
 
-	fmt.Print(output)
-	return nil
+	return output
+}
+
+// formatSigHashType formats a sighash type using its standard name
+func formatSigHashType(t txscript.SigHashType) string {
+	var name string
+	switch t &^ txscript.SigHashAnyOneCanPay {
+	case txscript.SigHashDefault:
+		if t == txscript.SigHashDefault {
+			return "SIGHASH_DEFAULT"
+		}
+		return fmt.Sprintf("0x%02x", uint32(t))
+	case txscript.SigHashAll:
+		name = "SIGHASH_ALL"
+	case txscript.SigHashNone:
+		name = "SIGHASH_NONE"
+	case txscript.SigHashSingle:
+		name = "SIGHASH_SINGLE"
+	default:
+		return fmt.Sprintf("0x%02x", uint32(t))
+	}
+	if t&txscript.SigHashAnyOneCanPay != 0 {
+		name += "|ANYONECANPAY"
+	}
+	return name
 }
 
 func formatBip32Path(path []uint32) string {
@@ -209,7 +413,7 @@ func formatBip32Path(path []uint32) string {
 	pathStr := "m"
 	for _, p := range path {
 		if p >= 0x80000000 {
-			pathStr += fmt.Sprintf("/%d\"", p-0x80000000)
+			pathStr += fmt.Sprintf("/%d'", p-0x80000000)
 		} else {
 			pathStr += fmt.Sprintf("/%d", p)
 		}
@@ -0,0 +1,240 @@
+package command
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/arkade-os/arkd/pkg/ark-lib/txutils"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// PsbtFieldOptions describes the ARK field to set or remove
+type PsbtFieldOptions struct {
+	Input int
+	// Field is the name of the ARK field, as displayed by psbt decode or as its key data (e.g. "expiry")
+	Field string
+	// Value is the hex encoded taptree, the expiry in blocks (below 512) or seconds,
+	// the cosigner public key or the comma separated hex encoded condition witness items
+	Value string
+	// Index is the cosigner index, -1 means after the highest index when setting and all when removing
+	Index int
+}
+
+func RunPsbtSetField(psbtInput string, opts PsbtFieldOptions) error {
+	p, err := readPsbtForEdit(psbtInput, opts.Input)
+	if err != nil {
+		return err
+	}
+
+	name, err := setPsbtField(p, opts)
+	if err != nil {
+		return err
+	}
+
+	return printEditedPsbt(p, fmt.Sprintf("input [%d]: set %s", opts.Input, name))
+}
+
+func RunPsbtRemoveField(psbtInput string, opts PsbtFieldOptions) error {
+	p, err := readPsbtForEdit(psbtInput, opts.Input)
+	if err != nil {
+		return err
+	}
+
+	removed, name, err := removePsbtField(p, opts)
+	if err != nil {
+		return err
+	}
+
+	return printEditedPsbt(p, fmt.Sprintf("input [%d]: removed %d %s field(s)", opts.Input, removed, name))
+}
+
+// setPsbtField sets the ARK field of the input, it returns the display name of the field
+func setPsbtField(p *psbt.Packet, opts PsbtFieldOptions) (string, error) {
+	keyData, name, err := parseArkFieldName(opts.Field)
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case bytes.Equal(keyData, txutils.ArkFieldTaprootTree):
+		taptreeBytes, err := hex.DecodeString(opts.Value)
+		if err != nil {
+			return "", fmt.Errorf("invalid taptree: %w", err)
+		}
+		taptree, err := txutils.DecodeTapTree(taptreeBytes)
+		if err != nil {
+			return "", fmt.Errorf("invalid taptree: %w", err)
+		}
+		err = setArkPsbtField(p, opts.Input, txutils.VtxoTaprootTreeField, taptree)
+		if err != nil {
+			return "", err
+		}
+
+	case bytes.Equal(keyData, txutils.ArkFieldTreeExpiry):
+		value, err := strconv.ParseUint(opts.Value, 10, 32)
+		if err != nil {
+			return "", fmt.Errorf("invalid expiry %q: %w", opts.Value, err)
+		}
+		expiry, err := parseRelativeLocktime(uint32(value))
+		if err != nil {
+			return "", fmt.Errorf("invalid expiry: %w", err)
+		}
+		err = setArkPsbtField(p, opts.Input, txutils.VtxoTreeExpiryField, expiry)
+		if err != nil {
+			return "", err
+		}
+
+	case bytes.Equal(keyData, txutils.ArkFieldCosigner):
+		// the field holds a compressed key, the parity of an x-only key would be lost
+		pubKey, err := parseCompressedPubKey(opts.Value)
+		if err != nil {
+			return "", fmt.Errorf("invalid cosigner: %w", err)
+		}
+		index := opts.Index
+		if index < 0 {
+			cosigners, err := txutils.GetArkPsbtFields(p, opts.Input, txutils.CosignerPublicKeyField)
+			if err != nil {
+				return "", fmt.Errorf("failed to read cosigners: %w", err)
+			}
+			// after the highest index, removed cosigners may leave gaps
+			index = 0
+			for _, cosigner := range cosigners {
+				index = max(index, cosigner.Index+1)
+			}
+		}
+		err = setArkPsbtField(p, opts.Input, txutils.CosignerPublicKeyField, txutils.IndexedCosignerPublicKey{
+			Index:     index,
+			PublicKey: pubKey,
+		})
+		if err != nil {
+			return "", err
+		}
+
+	case bytes.Equal(keyData, txutils.ArkFieldConditionWitness):
+		witness := make(wire.TxWitness, 0)
+		if opts.Value != "" {
+			for _, item := range strings.Split(opts.Value, ",") {
+				itemBytes, err := hex.DecodeString(item)
+				if err != nil {
+					return "", fmt.Errorf("invalid condition witness item %q: %w", item, err)
+				}
+				witness = append(witness, itemBytes)
+			}
+		}
+		err = setArkPsbtField(p, opts.Input, txutils.ConditionWitnessField, witness)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return name, nil
+}
+
+// removePsbtField removes the ARK field of the input, or only the cosigner at opts.Index.
+// It returns how many fields were removed and the display name of the field.
+func removePsbtField(p *psbt.Packet, opts PsbtFieldOptions) (int, string, error) {
+	keyData, name, err := parseArkFieldName(opts.Field)
+	if err != nil {
+		return 0, "", err
+	}
+
+	key := append([]byte{txutils.ArkPsbtFieldKeyType}, keyData...)
+	if opts.Index >= 0 && bytes.Equal(keyData, txutils.ArkFieldCosigner) {
+		key = binary.BigEndian.AppendUint32(key, uint32(opts.Index))
+	}
+
+	removed := removeUnknowns(&p.Inputs[opts.Input], func(unknown *psbt.Unknown) bool {
+		return bytes.HasPrefix(unknown.Key, key)
+	})
+	if removed == 0 {
+		return 0, "", fmt.Errorf("input [%d] has no %s field", opts.Input, name)
+	}
+	return removed, name, nil
+}
+
+// readPsbtForEdit parses the PSBT and checks the input to edit exists
+func readPsbtForEdit(psbtInput string, inputIndex int) (*psbt.Packet, error) {
+	content, err := readArg(psbtInput)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read psbt: %w", err)
+	}
+	p, err := parsePsbt(content)
+	if err != nil {
+		return nil, err
+	}
+	if inputIndex < 0 || inputIndex >= len(p.Inputs) {
+		return nil, fmt.Errorf("input index %d out of range, the psbt has %d inputs", inputIndex, len(p.Inputs))
+	}
+	return p, nil
+}
+
+// parseArkFieldName returns the key data and display name of an ARK field,
+// given by its display name or key data, case insensitive
+func parseArkFieldName(field string) ([]byte, string, error) {
+	names := make([]string, 0, len(arkPsbtFieldNames))
+	for _, arkField := range arkPsbtFieldNames {
+		if strings.EqualFold(field, arkField.Name) || strings.EqualFold(field, string(arkField.KeyData)) {
+			return arkField.KeyData, arkField.Name, nil
+		}
+		names = append(names, arkField.Name)
+	}
+	return nil, "", fmt.Errorf("unknown field %q, expected one of %s", field, strings.Join(names, ", "))
+}
+
+// setArkPsbtField sets the field on the input, replacing the field with the same key if any:
+// a PSBT map can't hold a key twice
+func setArkPsbtField[T any](p *psbt.Packet, inputIndex int, coder txutils.ArkPsbtFieldCoder[T], value T) error {
+	field, err := coder.Encode(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode field: %w", err)
+	}
+
+	removeUnknowns(&p.Inputs[inputIndex], func(unknown *psbt.Unknown) bool {
+		return bytes.Equal(unknown.Key, field.Key)
+	})
+
+	if err := txutils.SetArkPsbtField(p, inputIndex, coder, value); err != nil {
+		return fmt.Errorf("failed to set field: %w", err)
+	}
+	return nil
+}
+
+// removeUnknowns removes the unknown fields of the input matching the predicate, it returns how many were removed
+func removeUnknowns(in *psbt.PInput, match func(*psbt.Unknown) bool) int {
+	kept := make([]*psbt.Unknown, 0, len(in.Unknowns))
+	for _, unknown := range in.Unknowns {
+		if !match(unknown) {
+			kept = append(kept, unknown)
+		}
+	}
+	removed := len(in.Unknowns) - len(kept)
+	in.Unknowns = kept
+	return removed
+}
+
+// printEditedPsbt prints the change made and the updated PSBT
+func printEditedPsbt(p *psbt.Packet, change string) error {
+	encoded, err := p.B64Encode()
+	if err != nil {
+		return fmt.Errorf("failed to encode psbt: %w", err)
+	}
+
+	var output string
+
+	output += fmt.Sprintf("\n%s%s\n",
+		commonLabelStyle.Render("Updated:"),
+		valueStyle.Render(change),
+	)
+	output += fmt.Sprintf("\n%s\n%s\n",
+		sectionStyle.Render("PSBT:"),
+		valueStyle.Render(encoded),
+	)
+
+	fmt.Print(output)
+	return nil
+}
@@ -0,0 +1,138 @@
+package command
+
+import (
+	"encoding/hex"
+	"testing"
+
+	arklib "github.com/arkade-os/arkd/pkg/ark-lib"
+	"github.com/arkade-os/arkd/pkg/ark-lib/txutils"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/require"
+)
+
+// newOddPubKey returns a public key with an odd Y coordinate
+func newOddPubKey(t *testing.T) *btcec.PublicKey {
+	t.Helper()
+
+	for {
+		pubKey := newPrivKey(t).PubKey()
+		if pubKey.SerializeCompressed()[0] == 0x03 {
+			return pubKey
+		}
+	}
+}
+
+func TestParseArkFieldName(t *testing.T) {
+	keyData, name, err := parseArkFieldName("cosignerpublickey")
+	require.NoError(t, err)
+	require.Equal(t, txutils.ArkFieldCosigner, keyData)
+	require.Equal(t, "CosignerPublicKey", name)
+
+	keyData, name, err = parseArkFieldName("expiry")
+	require.NoError(t, err)
+	require.Equal(t, txutils.ArkFieldTreeExpiry, keyData)
+	require.Equal(t, "VtxoTreeExpiry", name)
+
+	_, _, err = parseArkFieldName("sweep")
+	require.ErrorContains(t, err, `unknown field "sweep"`)
+}
+
+func TestSetPsbtFieldCosigner(t *testing.T) {
+	p := newSpendingPsbt(t, []byte{0x51})
+	alice := newOddPubKey(t)
+	bob := newPrivKey(t).PubKey()
+	carol := newPrivKey(t).PubKey()
+
+	setCosigner := func(pubKey *btcec.PublicKey, index int) {
+		_, err := setPsbtField(p, PsbtFieldOptions{
+			Field: "cosigner",
+			Value: hex.EncodeToString(pubKey.SerializeCompressed()),
+			Index: index,
+		})
+		require.NoError(t, err)
+	}
+	cosigners := func() []txutils.IndexedCosignerPublicKey {
+		fields, err := txutils.GetArkPsbtFields(p, 0, txutils.CosignerPublicKeyField)
+		require.NoError(t, err)
+		return fields
+	}
+
+	// without --index, cosigners are appended
+	setCosigner(alice, -1)
+	setCosigner(bob, -1)
+	fields := cosigners()
+	require.Len(t, fields, 2)
+	require.Equal(t, 1, fields[1].Index)
+	require.True(t, alice.IsEqual(fields[0].PublicKey))
+
+	// an index already set is replaced
+	setCosigner(carol, 1)
+	fields = cosigners()
+	require.Len(t, fields, 2)
+	require.True(t, carol.IsEqual(fields[1].PublicKey))
+
+	// an x-only key would be written with an even Y
+	_, err := setPsbtField(p, PsbtFieldOptions{
+		Field: "cosigner",
+		Value: hex.EncodeToString(schnorr.SerializePubKey(alice)),
+		Index: -1,
+	})
+	require.ErrorContains(t, err, "expected a 33 bytes compressed key")
+
+	// --index only removes the cosigner at this index
+	removed, name, err := removePsbtField(p, PsbtFieldOptions{Field: "cosigner", Index: 0})
+	require.NoError(t, err)
+	require.Equal(t, 1, removed)
+	require.Equal(t, "CosignerPublicKey", name)
+	fields = cosigners()
+	require.Len(t, fields, 1)
+	require.Equal(t, 1, fields[0].Index)
+
+	_, _, err = removePsbtField(p, PsbtFieldOptions{Field: "cosigner", Index: 0})
+	require.EqualError(t, err, "input [0] has no CosignerPublicKey field")
+
+	// the next cosigner goes after the highest index, not in the gap
+	setCosigner(alice, -1)
+	fields = cosigners()
+	require.Len(t, fields, 2)
+	require.Equal(t, 2, fields[1].Index)
+
+	removed, _, err = removePsbtField(p, PsbtFieldOptions{Field: "cosigner", Index: -1})
+	require.NoError(t, err)
+	require.Equal(t, 2, removed)
+	require.Empty(t, cosigners())
+}
+
+func TestSetPsbtFieldValues(t *testing.T) {
+	p := newSpendingPsbt(t, []byte{0x51})
+
+	_, err := setPsbtField(p, PsbtFieldOptions{Field: "expiry", Value: "1000"})
+	require.ErrorContains(t, err, "seconds must be a multiple of 512")
+
+	for _, value := range []string{"144", "1024"} {
+		_, err = setPsbtField(p, PsbtFieldOptions{Field: "VtxoTreeExpiry", Value: value})
+		require.NoError(t, err)
+	}
+	// the field is replaced, a PSBT map can't hold a key twice
+	expiries, err := txutils.GetArkPsbtFields(p, 0, txutils.VtxoTreeExpiryField)
+	require.NoError(t, err)
+	require.Equal(t, []arklib.RelativeLocktime{{Type: arklib.LocktimeTypeSecond, Value: 1024}}, expiries)
+	require.Len(t, p.Inputs[0].Unknowns, 1)
+
+	// an empty item is an empty vector
+	_, err = setPsbtField(p, PsbtFieldOptions{Field: "condition", Value: "aa,,bb"})
+	require.NoError(t, err)
+	witnesses, err := txutils.GetArkPsbtFields(p, 0, txutils.ConditionWitnessField)
+	require.NoError(t, err)
+	require.Equal(t, []wire.TxWitness{{{0xaa}, {}, {0xbb}}}, witnesses)
+
+	_, err = setPsbtField(p, PsbtFieldOptions{Field: "condition", Value: "zz"})
+	require.ErrorContains(t, err, `invalid condition witness item "zz"`)
+
+	removed, _, err := removePsbtField(p, PsbtFieldOptions{Field: "expiry", Index: -1})
+	require.NoError(t, err)
+	require.Equal(t, 1, removed)
+	require.Len(t, p.Inputs[0].Unknowns, 1)
+}
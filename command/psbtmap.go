@@ -0,0 +1,203 @@
+package command
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+
+	"github.com/arkade-os/arkd/pkg/ark-lib/txutils"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// psbtMagic is the header of a serialized PSBT
+var psbtMagic = []byte{0x70, 0x73, 0x62, 0x74, 0xff}
+
+// psbtKeyValue is a raw PSBT map entry, the key starting with its type
+type psbtKeyValue struct {
+	Key   []byte
+	Value []byte
+}
+
+// rawPsbt is a PSBT as its raw key-value maps, which lets combine and diff handle
+// every field the same way, including unknown ones such as the ARK fields
+type rawPsbt struct {
+	Global  []psbtKeyValue
+	Inputs  [][]psbtKeyValue
+	Outputs [][]psbtKeyValue
+}
+
+// psbtScope is the map a PSBT field belongs to, used to name its key type
+type psbtScope int
+
+const (
+	psbtScopeGlobal psbtScope = iota
+	psbtScopeInput
+	psbtScopeOutput
+)
+
+var psbtGlobalFieldNames = map[byte]string{
+	byte(psbt.UnsignedTxType): "UnsignedTx",
+	byte(psbt.XpubType):       "Xpub",
+	byte(psbt.VersionType):    "Version",
+}
+
+var psbtInputFieldNames = map[byte]string{
+	byte(psbt.NonWitnessUtxoType):              "NonWitnessUtxo",
+	byte(psbt.WitnessUtxoType):                 "WitnessUtxo",
+	byte(psbt.PartialSigType):                  "PartialSig",
+	byte(psbt.SighashType):                     "SighashType",
+	byte(psbt.RedeemScriptInputType):           "RedeemScript",
+	byte(psbt.WitnessScriptInputType):          "WitnessScript",
+	byte(psbt.Bip32DerivationInputType):        "Bip32Derivation",
+	byte(psbt.FinalScriptSigType):              "FinalScriptSig",
+	byte(psbt.FinalScriptWitnessType):          "FinalScriptWitness",
+	byte(psbt.TaprootKeySpendSignatureType):    "TaprootKeySpendSig",
+	byte(psbt.TaprootScriptSpendSignatureType): "TaprootScriptSpendSig",
+	byte(psbt.TaprootLeafScriptType):           "TaprootLeafScript",
+	byte(psbt.TaprootBip32DerivationInputType): "TaprootBip32Derivation",
+	byte(psbt.TaprootInternalKeyInputType):     "TaprootInternalKey",
+	byte(psbt.TaprootMerkleRootType):           "TaprootMerkleRoot",
+}
+
+var psbtOutputFieldNames = map[byte]string{
+	byte(psbt.RedeemScriptOutputType):           "RedeemScript",
+	byte(psbt.WitnessScriptOutputType):          "WitnessScript",
+	byte(psbt.Bip32DerivationOutputType):        "Bip32Derivation",
+	byte(psbt.TaprootInternalKeyOutputType):     "TaprootInternalKey",
+	byte(psbt.TaprootTapTreeType):               "TaprootTapTree",
+	byte(psbt.TaprootBip32DerivationOutputType): "TaprootBip32Derivation",
+}
+
+// arkPsbtFieldNames names the ARK input fields by their key data, as displayed by psbt decode
+var arkPsbtFieldNames = []struct {
+	KeyData []byte
+	Name    string
+}{
+	{txutils.ArkFieldTaprootTree, "VtxoTaprootTree"},
+	{txutils.ArkFieldTreeExpiry, "VtxoTreeExpiry"},
+	{txutils.ArkFieldCosigner, "CosignerPublicKey"},
+	{txutils.ArkFieldConditionWitness, "ConditionWitness"},
+}
+
+// newRawPsbt splits the serialized packet into its key-value maps
+func newRawPsbt(p *psbt.Packet) (*rawPsbt, error) {
+	var buf bytes.Buffer
+	if err := p.Serialize(&buf); err != nil {
+		return nil, fmt.Errorf("failed to serialize psbt: %w", err)
+	}
+
+	r := bytes.NewReader(buf.Bytes())
+	magic := make([]byte, len(psbtMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || !bytes.Equal(magic, psbtMagic) {
+		return nil, fmt.Errorf("invalid psbt magic")
+	}
+
+	raw := &rawPsbt{}
+	var err error
+	if raw.Global, err = readPsbtMap(r); err != nil {
+		return nil, fmt.Errorf("global map: %w", err)
+	}
+	for i := range p.UnsignedTx.TxIn {
+		inputMap, err := readPsbtMap(r)
+		if err != nil {
+			return nil, fmt.Errorf("input [%d] map: %w", i, err)
+		}
+		raw.Inputs = append(raw.Inputs, inputMap)
+	}
+	for i := range p.UnsignedTx.TxOut {
+		outputMap, err := readPsbtMap(r)
+		if err != nil {
+			return nil, fmt.Errorf("output [%d] map: %w", i, err)
+		}
+		raw.Outputs = append(raw.Outputs, outputMap)
+	}
+
+	return raw, nil
+}
+
+// readPsbtMap reads key-value pairs up to the 0x00 separator
+func readPsbtMap(r io.Reader) ([]psbtKeyValue, error) {
+	entries := make([]psbtKeyValue, 0)
+	for {
+		key, err := wire.ReadVarBytes(r, 0, psbt.MaxPsbtKeyLength, "key")
+		if err != nil {
+			return nil, err
+		}
+		if len(key) == 0 {
+			return entries, nil
+		}
+		value, err := wire.ReadVarBytes(r, 0, psbt.MaxPsbtValueLength, "value")
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, psbtKeyValue{Key: key, Value: value})
+	}
+}
+
+// packet rebuilds the PSBT from its key-value maps
+func (raw *rawPsbt) packet() (*psbt.Packet, error) {
+	var buf bytes.Buffer
+	buf.Write(psbtMagic)
+
+	maps := append([][]psbtKeyValue{raw.Global}, raw.Inputs...)
+	maps = append(maps, raw.Outputs...)
+	for _, entries := range maps {
+		for _, entry := range entries {
+			if err := wire.WriteVarBytes(&buf, 0, entry.Key); err != nil {
+				return nil, err
+			}
+			if err := wire.WriteVarBytes(&buf, 0, entry.Value); err != nil {
+				return nil, err
+			}
+		}
+		buf.WriteByte(0x00)
+	}
+
+	return psbt.NewFromRawBytes(&buf, false)
+}
+
+// findPsbtKey returns the index of the entry with the given key, -1 if not found
+func findPsbtKey(entries []psbtKeyValue, key []byte) int {
+	for i, entry := range entries {
+		if bytes.Equal(entry.Key, key) {
+			return i
+		}
+	}
+	return -1
+}
+
+// psbtFieldName names a raw field from its key type, followed by the hex encoded key data if any
+func psbtFieldName(scope psbtScope, key []byte) string {
+	keyType, keyData := key[0], key[1:]
+
+	var names map[byte]string
+	switch scope {
+	case psbtScopeGlobal:
+		names = psbtGlobalFieldNames
+	case psbtScopeInput:
+		names = psbtInputFieldNames
+	default:
+		names = psbtOutputFieldNames
+	}
+
+	name, ok := names[keyType]
+	if !ok && scope == psbtScopeInput && keyType == txutils.ArkPsbtFieldKeyType {
+		for _, field := range arkPsbtFieldNames {
+			if bytes.HasPrefix(keyData, field.KeyData) {
+				name, ok = field.Name, true
+				keyData = keyData[len(field.KeyData):]
+				break
+			}
+		}
+	}
+	if !ok {
+		name = fmt.Sprintf("Unknown(0x%02x)", keyType)
+	}
+
+	if len(keyData) > 0 {
+		return fmt.Sprintf("%s %s", name, hex.EncodeToString(keyData))
+	}
+	return name
+}
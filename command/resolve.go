@@ -0,0 +1,162 @@
+package command
+
+import (
+	"bytes"
+	"fmt"
+
+	arklib "github.com/arkade-os/arkd/pkg/ark-lib"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// AddressOptions resolves output scripts to addresses
+type AddressOptions struct {
+	// Signer is the server public key, required to encode Ark addresses
+	Signer string
+	// Network selects the address encoding, bitcoin if empty
+	Network string
+}
+
+// addressResolver encodes taproot keys and output scripts as Ark and onchain addresses
+type addressResolver struct {
+	signer  *btcec.PublicKey
+	network *arklib.Network
+	params  *chaincfg.Params
+}
+
+// newAddressResolver returns nil if neither a signer nor a network is given, addresses are not displayed then
+func newAddressResolver(opts AddressOptions) (*addressResolver, error) {
+	if opts.Signer == "" && opts.Network == "" {
+		return nil, nil
+	}
+
+	networkName := opts.Network
+	if networkName == "" {
+		networkName = arklib.Bitcoin.Name
+	}
+	network, err := parseArkNetwork(networkName)
+	if err != nil {
+		return nil, err
+	}
+
+	resolver := &addressResolver{network: network, params: onchainParams(network)}
+	if opts.Signer != "" {
+		resolver.signer, err = parsePubKey(opts.Signer)
+		if err != nil {
+			return nil, fmt.Errorf("invalid signer: %w", err)
+		}
+	}
+	return resolver, nil
+}
+
+// arkAddress encodes the vtxo taproot key as an Ark address, empty without signer
+func (r *addressResolver) arkAddress(vtxoTapKey *btcec.PublicKey) string {
+	if r.signer == nil {
+		return ""
+	}
+	address := &arklib.Address{
+		HRP:        r.network.Addr,
+		Signer:     r.signer,
+		VtxoTapKey: vtxoTapKey,
+	}
+	encoded, err := address.EncodeV0()
+	if err != nil {
+		return ""
+	}
+	return encoded
+}
+
+// onchainAddress encodes a standard output script as an address, empty if it has none
+func (r *addressResolver) onchainAddress(pkScript []byte) string {
+	_, addresses, _, err := txscript.ExtractPkScriptAddrs(pkScript, r.params)
+	if err != nil || len(addresses) != 1 {
+		return ""
+	}
+	return addresses[0].EncodeAddress()
+}
+
+// formatOutputAddresses formats the onchain address of an output, and its Ark address if P2TR
+func (r *addressResolver) formatOutputAddresses(pkScript []byte) string {
+	var output string
+
+	if txscript.IsPayToTaproot(pkScript) && !isAnchorOutput(&wire.TxOut{PkScript: pkScript}) {
+		if vtxoTapKey, err := schnorr.ParsePubKey(pkScript[2:]); err == nil {
+			if encoded := r.arkAddress(vtxoTapKey); encoded != "" {
+				output += fmt.Sprintf("%s%s\n",
+					subLabelStyle.Render("  Ark Address:"),
+					valueStyle.Render(encoded),
+				)
+			}
+		}
+	}
+	if encoded := r.onchainAddress(pkScript); encoded != "" {
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render("  Onchain Address:"),
+			valueStyle.Render(encoded),
+		)
+	}
+
+	return output
+}
+
+// formatInputAddresses formats the address spent by a taproot input with known leaves, the taproot
+// key being computed from its TaprootLeafScript, or from its VtxoTaprootTree field
+func (r *addressResolver) formatInputAddresses(p *psbt.Packet, inputIndex int) string {
+	in := p.Inputs[inputIndex]
+
+	var tapKey *btcec.PublicKey
+	source := ""
+	if len(in.TaprootLeafScript) > 0 {
+		leaf := in.TaprootLeafScript[0]
+		controlBlock, err := txscript.ParseControlBlock(leaf.ControlBlock)
+		if err != nil {
+			return ""
+		}
+		tapKey = txscript.ComputeTaprootOutputKey(controlBlock.InternalKey, controlBlock.RootHash(leaf.Script))
+		source = "TaprootLeafScript"
+	} else if vtxoScript, err := inputVtxoScript(p, inputIndex); err == nil {
+		tapKey, _, err = vtxoScript.TapTree()
+		if err != nil {
+			return ""
+		}
+		source = "VtxoTaprootTree"
+	} else {
+		return ""
+	}
+
+	var output string
+
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("  Spends:"),
+		valueStyle.Render(fmt.Sprintf("taproot key from %s", source)),
+	)
+	if encoded := r.arkAddress(tapKey); encoded != "" {
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render("    Ark Address:"),
+			valueStyle.Render(encoded),
+		)
+	}
+	if address, err := btcutil.NewAddressTaproot(schnorr.SerializePubKey(tapKey), r.params); err == nil {
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render("    Onchain Address:"),
+			valueStyle.Render(address.EncodeAddress()),
+		)
+	}
+
+	if in.WitnessUtxo != nil {
+		pkScript, err := txscript.PayToTaprootScript(tapKey)
+		if err == nil && !bytes.Equal(pkScript, in.WitnessUtxo.PkScript) {
+			output += fmt.Sprintf("%s%s\n",
+				subLabelStyle.Render("    Warning:"),
+				warningStyle.Render("does not match the WitnessUtxo script"),
+			)
+		}
+	}
+
+	return output
+}
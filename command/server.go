@@ -0,0 +1,409 @@
+package command
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	arklib "github.com/arkade-os/arkd/pkg/ark-lib"
+	"github.com/arkade-os/arkd/pkg/ark-lib/script"
+	"github.com/arkade-os/arkd/pkg/ark-lib/txutils"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+)
+
+// ServerInfoOptions selects the arkd server queried and the profile its info is cached in
+type ServerInfoOptions struct {
+	// Server is the base URL of the arkd REST API, the one cached in the profile if empty
+	Server string
+	// Profile is the name of the config profile, DefaultProfile if empty
+	Profile string
+}
+
+// ServerCheckOptions validates decoded addresses and scripts against a server cached by noa server info
+type ServerCheckOptions struct {
+	// Profile is the name of the config profile to check against. If empty, the default profile
+	// is used when it is cached, nothing is checked otherwise.
+	Profile string
+}
+
+// serverInfo is the server configuration returned by GET /v1/info
+type serverInfo struct {
+	Version             string             `json:"version"`
+	SignerPubkey        string             `json:"signerPubkey"`
+	ForfeitPubkey       string             `json:"forfeitPubkey"`
+	ForfeitAddress      string             `json:"forfeitAddress"`
+	CheckpointTapscript string             `json:"checkpointTapscript"`
+	Network             string             `json:"network"`
+	SessionDuration     jsonInt64          `json:"sessionDuration"`
+	UnilateralExitDelay jsonInt64          `json:"unilateralExitDelay"`
+	BoardingExitDelay   jsonInt64          `json:"boardingExitDelay"`
+	Dust                jsonInt64          `json:"dust"`
+	UtxoMinAmount       jsonInt64          `json:"utxoMinAmount"`
+	UtxoMaxAmount       jsonInt64          `json:"utxoMaxAmount"`
+	VtxoMinAmount       jsonInt64          `json:"vtxoMinAmount"`
+	VtxoMaxAmount       jsonInt64          `json:"vtxoMaxAmount"`
+	DeprecatedSigners   []deprecatedSigner `json:"deprecatedSigners,omitempty"`
+}
+
+// deprecatedSigner is a former signer key of the server, still accepted until its cutoff date
+type deprecatedSigner struct {
+	Pubkey     string    `json:"pubkey"`
+	CutoffDate jsonInt64 `json:"cutoffDate"`
+}
+
+// getInfo fetches the server configuration
+func (c *indexerClient) getInfo() (*serverInfo, error) {
+	var info serverInfo
+	if err := c.get("/v1/info", nil, &info); err != nil {
+		return nil, err
+	}
+	if _, err := parsePubKey(info.SignerPubkey); err != nil {
+		return nil, fmt.Errorf("server returned an invalid signer key: %w", err)
+	}
+	if _, err := parseArkNetwork(info.Network); err != nil {
+		return nil, fmt.Errorf("server returned an invalid network: %w", err)
+	}
+	if _, _, err := info.exitDelays(); err != nil {
+		return nil, fmt.Errorf("server returned an invalid exit delay: %w", err)
+	}
+	return &info, nil
+}
+
+// exitDelays returns the unilateral and boarding exit delays of the server
+func (i serverInfo) exitDelays() (arklib.RelativeLocktime, arklib.RelativeLocktime, error) {
+	unilateral, err := parseRelativeLocktime(uint32(i.UnilateralExitDelay))
+	if err != nil {
+		return arklib.RelativeLocktime{}, arklib.RelativeLocktime{}, err
+	}
+	boarding, err := parseRelativeLocktime(uint32(i.BoardingExitDelay))
+	if err != nil {
+		return arklib.RelativeLocktime{}, arklib.RelativeLocktime{}, err
+	}
+	return unilateral, boarding, nil
+}
+
+func RunServerInfo(opts ServerInfoOptions) error {
+	name := opts.Profile
+	if name == "" {
+		name = DefaultProfile
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	server := opts.Server
+	if server == "" {
+		profile, ok := config.Profiles[name]
+		if !ok {
+			return fmt.Errorf("profile %q not found, use --server", name)
+		}
+		server = profile.Server
+	}
+
+	client, err := newIndexerClient(server)
+	if err != nil {
+		return err
+	}
+	info, err := client.getInfo()
+	if err != nil {
+		return err
+	}
+	unilateralExitDelay, boardingExitDelay, err := info.exitDelays()
+	if err != nil {
+		return err
+	}
+
+	config.Profiles[name] = &serverProfile{
+		Name:      name,
+		Server:    client.baseURL,
+		FetchedAt: time.Now().Unix(),
+		Info:      *info,
+	}
+	path, err := saveConfig(config)
+	if err != nil {
+		return err
+	}
+
+	var output string
+
+	output += fmt.Sprintf("\n%s\n",
+		sectionStyle.Render("Server:"),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("URL:"),
+		valueStyle.Render(client.baseURL),
+	)
+	if info.Version != "" {
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render("Version:"),
+			valueStyle.Render(info.Version),
+		)
+	}
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Network:"),
+		valueStyle.Render(info.Network),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Signer Key:"),
+		valueStyle.Render(info.SignerPubkey),
+	)
+	if info.ForfeitPubkey != "" {
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render("Forfeit Key:"),
+			valueStyle.Render(info.ForfeitPubkey),
+		)
+	}
+	if info.ForfeitAddress != "" {
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render("Forfeit Address:"),
+			valueStyle.Render(info.ForfeitAddress),
+		)
+	}
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Unilateral Exit Delay:"),
+		valueStyle.Render(formatLocktimeDuration(unilateralExitDelay)),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Boarding Exit Delay:"),
+		valueStyle.Render(formatLocktimeDuration(boardingExitDelay)),
+	)
+	if info.SessionDuration > 0 {
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render("Session Duration:"),
+			valueStyle.Render(fmt.Sprintf("%d seconds", info.SessionDuration)),
+		)
+	}
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Dust:"),
+		valueStyle.Render(fmt.Sprintf("%d sats", info.Dust)),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("VTXO Amount:"),
+		valueStyle.Render(formatAmountRange(info.VtxoMinAmount, info.VtxoMaxAmount)),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("UTXO Amount:"),
+		valueStyle.Render(formatAmountRange(info.UtxoMinAmount, info.UtxoMaxAmount)),
+	)
+	if info.CheckpointTapscript != "" {
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render("Checkpoint Tapscript:"),
+			valueStyle.Render(info.CheckpointTapscript),
+		)
+	}
+	for i, signer := range info.DeprecatedSigners {
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render(fmt.Sprintf("Deprecated Signer [%d]:", i)),
+			valueStyle.Render(fmt.Sprintf("%s (cutoff %s)", signer.Pubkey, formatTimestamp(int64(signer.CutoffDate)))),
+		)
+	}
+
+	output += fmt.Sprintf("\n%s\n",
+		sectionStyle.Render("Profile:"),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Name:"),
+		valueStyle.Render(name),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Config:"),
+		valueStyle.Render(path),
+	)
+
+	fmt.Print(output)
+	return nil
+}
+
+// formatAmountRange formats the amount limits of the server, a negative maximum meaning no limit
+func formatAmountRange(minAmount, maxAmount jsonInt64) string {
+	if maxAmount < 0 {
+		return fmt.Sprintf("min %d sats, no max", minAmount)
+	}
+	return fmt.Sprintf("min %d sats, max %d sats", minAmount, maxAmount)
+}
+
+// matchSigner reports whether the key is the server signer or a deprecated one, returned if so
+func (s *serverProfile) matchSigner(key *btcec.PublicKey) (bool, *deprecatedSigner) {
+	xOnly := schnorr.SerializePubKey(key)
+	if signer, err := parsePubKey(s.Info.SignerPubkey); err == nil && bytes.Equal(schnorr.SerializePubKey(signer), xOnly) {
+		return true, nil
+	}
+	for _, deprecated := range s.Info.DeprecatedSigners {
+		signer, err := parsePubKey(deprecated.Pubkey)
+		if err == nil && bytes.Equal(schnorr.SerializePubKey(signer), xOnly) {
+			return true, &deprecated
+		}
+	}
+	return false, nil
+}
+
+// formatSignerCheck checks a key against the server signer, deprecated signers being flagged
+func (s *serverProfile) formatSignerCheck(key *btcec.PublicKey) string {
+	ok, deprecated := s.matchSigner(key)
+	switch {
+	case !ok:
+		return invalidStyle.Render(fmt.Sprintf("not the server signer, expected %s", s.Info.SignerPubkey))
+	case deprecated != nil:
+		return warningStyle.Render(fmt.Sprintf("deprecated server signer (cutoff %s)", formatTimestamp(int64(deprecated.CutoffDate))))
+	default:
+		return validStyle.Render("server signer")
+	}
+}
+
+// formatExitDelayCheck checks an exit delay against the unilateral and boarding exit delays of the server
+func (s *serverProfile) formatExitDelayCheck(delay arklib.RelativeLocktime) string {
+	unilateral, boarding, err := s.Info.exitDelays()
+	if err != nil {
+		return invalidStyle.Render(fmt.Sprintf("invalid server exit delay in the profile: %v", err))
+	}
+	switch delay {
+	case unilateral:
+		return validStyle.Render(fmt.Sprintf("%s, unilateral exit delay", formatLocktimeDuration(delay)))
+	case boarding:
+		return validStyle.Render(fmt.Sprintf("%s, boarding exit delay", formatLocktimeDuration(delay)))
+	}
+	return invalidStyle.Render(fmt.Sprintf("%s, expected %s (unilateral) or %s (boarding)",
+		formatLocktimeDuration(delay), formatLocktimeDuration(unilateral), formatLocktimeDuration(boarding)))
+}
+
+// formatServerAddressChecks checks the signer and the network of an Ark address against the server
+func formatServerAddressChecks(address *arklib.Address, profile *serverProfile) string {
+	var output string
+
+	output += fmt.Sprintf("%s\n",
+		sectionStyle.Render(fmt.Sprintf("Server Checks (%s):", profile.Name)),
+	)
+	if address.Signer != nil {
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render("signer:"),
+			profile.formatSignerCheck(address.Signer),
+		)
+	}
+
+	network, err := parseArkNetwork(profile.Info.Network)
+	status := invalidStyle.Render(fmt.Sprintf("unknown server network %q", profile.Info.Network))
+	if err == nil {
+		if network.Addr == address.HRP {
+			status = validStyle.Render(profile.Info.Network)
+		} else {
+			status = invalidStyle.Render(fmt.Sprintf("HRP %s, server network %s expects %s", address.HRP, network.Name, network.Addr))
+		}
+	}
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("network:"),
+		status,
+	)
+
+	return output
+}
+
+// formatServerTaptreeChecks checks the leaves of a taptree against the server: the forfeit leaves
+// must be cosigned by the signer and the exit leaves must use one of its exit delays. The checkpoint
+// tapscript of the server and the sweep leaf of batch outputs and tree nodes are recognized.
+func formatServerTaptreeChecks(taptree []string, profile *serverProfile, indent string) string {
+	var output string
+
+	for i, scriptHex := range taptree {
+		label := subLabelStyle.Render(fmt.Sprintf("%s[%d]:", indent, i))
+		if profile.Info.CheckpointTapscript != "" && strings.EqualFold(scriptHex, profile.Info.CheckpointTapscript) {
+			output += fmt.Sprintf("%s%s\n", label, validStyle.Render("server checkpoint tapscript"))
+			continue
+		}
+
+		scriptBytes, err := hex.DecodeString(scriptHex)
+		if err != nil {
+			output += fmt.Sprintf("%s%s\n", label, invalidStyle.Render("invalid script hex"))
+			continue
+		}
+		closure, err := script.DecodeClosure(scriptBytes)
+		if err != nil {
+			output += fmt.Sprintf("%s%s\n", label, warningStyle.Render("unknown closure, not checked"))
+			continue
+		}
+
+		if sweep, ok := sweepClosure(closure); ok && len(taptree) == 1 {
+			output += fmt.Sprintf("%s%s\n", label, valueStyle.Render("sweep"))
+			output += fmt.Sprintf("%s%s\n",
+				subLabelStyle.Render(indent+"  Sweep Key:"),
+				profile.formatSweepKeyCheck(sweep.PubKeys[0]),
+			)
+			continue
+		}
+
+		var (
+			kind  string
+			keys  []*btcec.PublicKey
+			delay *arklib.RelativeLocktime
+		)
+		switch c := closure.(type) {
+		case *script.MultisigClosure:
+			kind, keys = "forfeit", c.PubKeys
+		case *script.CLTVMultisigClosure:
+			kind, keys = "forfeit (CLTV)", c.PubKeys
+		case *script.ConditionMultisigClosure:
+			kind, keys = "forfeit (condition)", c.PubKeys
+		case *script.CSVMultisigClosure:
+			kind, delay = "exit", &c.Locktime
+		case *script.ConditionCSVMultisigClosure:
+			kind, delay = "exit (condition)", &c.Locktime
+		default:
+			output += fmt.Sprintf("%s%s\n", label, warningStyle.Render("unknown closure, not checked"))
+			continue
+		}
+		output += fmt.Sprintf("%s%s\n", label, valueStyle.Render(kind))
+
+		if delay != nil {
+			output += fmt.Sprintf("%s%s\n",
+				subLabelStyle.Render(indent+"  Exit Delay:"),
+				profile.formatExitDelayCheck(*delay),
+			)
+			continue
+		}
+
+		// the signer is any of the keys, the other ones being the owners
+		status := invalidStyle.Render(fmt.Sprintf("server signer %s not found", profile.Info.SignerPubkey))
+		for _, key := range keys {
+			if ok, _ := profile.matchSigner(key); ok {
+				status = profile.formatSignerCheck(key)
+				break
+			}
+		}
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render(indent+"  Signer:"),
+			status,
+		)
+	}
+
+	return output
+}
+
+// formatSweepKeyCheck checks the sweep key of a batch output or tree node, the server signer or forfeit key
+func (s *serverProfile) formatSweepKeyCheck(key *btcec.PublicKey) string {
+	if forfeit, err := parsePubKey(s.Info.ForfeitPubkey); err == nil &&
+		bytes.Equal(schnorr.SerializePubKey(forfeit), schnorr.SerializePubKey(key)) {
+		return validStyle.Render("server forfeit key")
+	}
+	return s.formatSignerCheck(key)
+}
+
+// formatInputServerChecks checks the VtxoTaprootTree field of an input against the server
+func formatInputServerChecks(p *psbt.Packet, inputIndex int, profile *serverProfile) string {
+	trees, err := txutils.GetArkPsbtFields(p, inputIndex, txutils.VtxoTaprootTreeField)
+	if err != nil || len(trees) == 0 {
+		return ""
+	}
+
+	var output string
+
+	output += fmt.Sprintf("%s\n",
+		subLabelStyle.Render(fmt.Sprintf("  Server Checks (%s):", profile.Name)),
+	)
+	output += formatServerTaptreeChecks(trees[0], profile, "    ")
+
+	return output
+}
@@ -0,0 +1,211 @@
+package command
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/arkade-os/arkd/pkg/ark-lib/script"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+// PsbtSignOptions describes the key signing the PSBT and the leaf to sign
+type PsbtSignOptions struct {
+	// Key is the WIF or hex encoded private key, inline or from a file
+	Key string
+	// Leaf restricts signing to the tapscript leaf with the given hash, key-spends are skipped
+	Leaf string
+}
+
+// inputSignature describes a signature added to a PSBT input
+type inputSignature struct {
+	Input int
+	// Path is either "key-spend" or "script-spend"
+	Path     string
+	LeafHash []byte
+}
+
+func RunPsbtSign(psbtInput string, opts PsbtSignOptions) error {
+	content, err := readArg(psbtInput)
+	if err != nil {
+		return fmt.Errorf("failed to read psbt: %w", err)
+	}
+	p, err := parsePsbt(content)
+	if err != nil {
+		return err
+	}
+
+	privKey, err := parsePrivKey(opts.Key)
+	if err != nil {
+		return err
+	}
+
+	var leafHash []byte
+	if opts.Leaf != "" {
+		leafHash, err = hex.DecodeString(opts.Leaf)
+		if err != nil || len(leafHash) != 32 {
+			return fmt.Errorf("invalid leaf hash %q, expected 32 bytes hex", opts.Leaf)
+		}
+	}
+
+	// taproot sighashes commit to the prevouts of all the inputs
+	prevouts, complete := psbtPrevouts(p)
+	if !complete {
+		return fmt.Errorf("missing prevouts, every input must have a witness or non-witness utxo to compute taproot sighashes")
+	}
+	prevoutFetcher := txscript.NewMultiPrevOutFetcher(prevouts)
+	sigHashes := txscript.NewTxSigHashes(p.UnsignedTx, prevoutFetcher)
+
+	signatures := make([]inputSignature, 0)
+	for i := range p.Inputs {
+		added, err := signInput(p, i, privKey, leafHash, sigHashes, prevoutFetcher)
+		if err != nil {
+			return fmt.Errorf("failed to sign input [%d]: %w", i, err)
+		}
+		signatures = append(signatures, added...)
+	}
+
+	if len(signatures) == 0 {
+		return fmt.Errorf("no input can be signed by key %x", schnorr.SerializePubKey(privKey.PubKey()))
+	}
+
+	encoded, err := p.B64Encode()
+	if err != nil {
+		return fmt.Errorf("failed to encode psbt: %w", err)
+	}
+
+	var output string
+
+	output += fmt.Sprintf("\n%s\n",
+		sectionStyle.Render(fmt.Sprintf("Signatures (%d):", len(signatures))),
+	)
+	for _, sig := range signatures {
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render(fmt.Sprintf("[%d]:", sig.Input)),
+			valueStyle.Render(sig.Path),
+		)
+		if len(sig.LeafHash) > 0 {
+			output += fmt.Sprintf("%s%s\n",
+				subLabelStyle.Render("  LeafHash:"),
+				valueStyle.Render(hex.EncodeToString(sig.LeafHash)),
+			)
+		}
+	}
+	output += fmt.Sprintf("\n%s\n%s\n",
+		sectionStyle.Render("PSBT:"),
+		valueStyle.Render(encoded),
+	)
+
+	fmt.Print(output)
+	return nil
+}
+
+// signInput adds the signatures of the key to the input: a script-spend signature for each leaf
+// whose closure includes the key, and a key-spend signature if the key is the taproot internal key
+func signInput(
+	p *psbt.Packet, inputIndex int, privKey *btcec.PrivateKey, leafHash []byte,
+	sigHashes *txscript.TxSigHashes, prevoutFetcher txscript.PrevOutputFetcher,
+) ([]inputSignature, error) {
+	in := &p.Inputs[inputIndex]
+	pubKey := privKey.PubKey()
+	xOnly := schnorr.SerializePubKey(pubKey)
+	signatures := make([]inputSignature, 0)
+
+	sigHashType := txscript.SigHashDefault
+	if in.SighashType != 0 {
+		sigHashType = in.SighashType
+	}
+
+	for _, leaf := range in.TaprootLeafScript {
+		tapLeaf := txscript.NewTapLeaf(leaf.LeafVersion, leaf.Script)
+		tapHash := tapLeaf.TapHash()
+		if leafHash != nil && !bytes.Equal(tapHash[:], leafHash) {
+			continue
+		}
+
+		closure, err := script.DecodeClosure(leaf.Script)
+		if err != nil || !containsPubKey(closurePubKeys(closure), pubKey) {
+			continue
+		}
+
+		sigHash, err := txscript.CalcTapscriptSignaturehash(
+			sigHashes, sigHashType, p.UnsignedTx, inputIndex, prevoutFetcher, tapLeaf,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute sighash: %w", err)
+		}
+		sig, err := schnorr.Sign(privKey, sigHash)
+		if err != nil {
+			return nil, fmt.Errorf("failed to sign: %w", err)
+		}
+
+		scriptSpendSig := &psbt.TaprootScriptSpendSig{
+			XOnlyPubKey: xOnly,
+			LeafHash:    tapHash[:],
+			Signature:   sig.Serialize(),
+			SigHash:     sigHashType,
+		}
+		in.TaprootScriptSpendSig = replaceScriptSpendSig(in.TaprootScriptSpendSig, scriptSpendSig)
+		signatures = append(signatures, inputSignature{
+			Input:    inputIndex,
+			Path:     "script-spend",
+			LeafHash: tapHash[:],
+		})
+	}
+
+	if leafHash != nil {
+		return signatures, nil
+	}
+
+	prevout := inputPrevout(p, inputIndex)
+	if !txscript.IsPayToTaproot(prevout.PkScript) {
+		return signatures, nil
+	}
+
+	// the output key commits to the merkle root if the input has one, otherwise it is a BIP86 key
+	var outputKey *btcec.PublicKey
+	if len(in.TaprootMerkleRoot) > 0 {
+		outputKey = txscript.ComputeTaprootOutputKey(pubKey, in.TaprootMerkleRoot)
+	} else {
+		outputKey = txscript.ComputeTaprootKeyNoScript(pubKey)
+	}
+	if !bytes.Equal(schnorr.SerializePubKey(outputKey), prevout.PkScript[2:]) {
+		return signatures, nil
+	}
+
+	sigHash, err := txscript.CalcTaprootSignatureHash(
+		sigHashes, sigHashType, p.UnsignedTx, inputIndex, prevoutFetcher,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute sighash: %w", err)
+	}
+	sig, err := schnorr.Sign(txscript.TweakTaprootPrivKey(*privKey, in.TaprootMerkleRoot), sigHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign: %w", err)
+	}
+
+	in.TaprootKeySpendSig = sig.Serialize()
+	if sigHashType != txscript.SigHashDefault {
+		in.TaprootKeySpendSig = append(in.TaprootKeySpendSig, byte(sigHashType))
+	}
+	signatures = append(signatures, inputSignature{
+		Input: inputIndex,
+		Path:  "key-spend",
+	})
+
+	return signatures, nil
+}
+
+// replaceScriptSpendSig adds the signature, replacing any previous one of the same key for the same leaf
+func replaceScriptSpendSig(sigs []*psbt.TaprootScriptSpendSig, sig *psbt.TaprootScriptSpendSig) []*psbt.TaprootScriptSpendSig {
+	for i, existing := range sigs {
+		if bytes.Equal(existing.XOnlyPubKey, sig.XOnlyPubKey) && bytes.Equal(existing.LeafHash, sig.LeafHash) {
+			sigs[i] = sig
+			return sigs
+		}
+	}
+	return append(sigs, sig)
+}
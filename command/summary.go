@@ -0,0 +1,304 @@
+package command
+
+import (
+	"fmt"
+
+	"github.com/arkade-os/arkd/pkg/ark-lib/script"
+	"github.com/arkade-os/arkd/pkg/ark-lib/txutils"
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// size of a schnorr signature pushed on the witness stack with SIGHASH_DEFAULT
+	schnorrSigWitnessSize = 1 + 64
+	// size of a P2WPKH witness (signature + compressed public key)
+	p2wpkhWitnessSize = 1 + 1 + 72 + 1 + 33
+	// size of a P2PKH scriptSig (signature + compressed public key)
+	p2pkhScriptSigSize = 1 + 72 + 1 + 33
+	// size of a P2SH-P2WPKH scriptSig, the push of the P2WPKH redeem script
+	p2shP2wpkhScriptSigSize = 1 + 22
+)
+
+// psbtSummary gathers the balance and size information of a PSBT
+type psbtSummary struct {
+	TotalIn  int64
+	TotalOut int64
+	// Fee is only meaningful when Complete is true
+	Fee      int64
+	Complete bool
+	Weight   int64
+	VSize    int64
+	Warnings []string
+}
+
+// summarizePsbt computes the totals, fee and estimated size of the transaction once signed
+func summarizePsbt(p *psbt.Packet) psbtSummary {
+	summary := psbtSummary{Complete: true}
+	tx := p.UnsignedTx
+
+	for i := range tx.TxIn {
+		prevout := inputPrevout(p, i)
+		if prevout == nil {
+			summary.Complete = false
+			summary.Warnings = append(summary.Warnings,
+				fmt.Sprintf("input [%d] has no witness or non-witness utxo, its amount is unknown", i),
+			)
+			continue
+		}
+		summary.TotalIn += prevout.Value
+	}
+
+	for i, txOut := range tx.TxOut {
+		summary.TotalOut += txOut.Value
+
+		if isAnchorOutput(txOut) {
+			if txOut.Value == 0 {
+				summary.Warnings = append(summary.Warnings,
+					fmt.Sprintf("output [%d] is a zero-value P2A anchor, it must be spent by a child in the same package", i),
+				)
+			}
+			continue
+		}
+
+		if threshold := dustThreshold(txOut.PkScript); txOut.Value < threshold {
+			summary.Warnings = append(summary.Warnings,
+				fmt.Sprintf("output [%d] is dust (%d sats < %d sats)", i, txOut.Value, threshold),
+			)
+		}
+	}
+
+	if summary.Complete {
+		summary.Fee = summary.TotalIn - summary.TotalOut
+		if summary.Fee < 0 {
+			summary.Warnings = append(summary.Warnings, "outputs exceed inputs, the fee is negative")
+		}
+	}
+
+	// the unsigned tx serializes empty scriptSigs
+	baseSize := int64(tx.SerializeSizeStripped())
+	witnessSize := int64(0)
+	noWitnessInputs := int64(0)
+	for i := range tx.TxIn {
+		size, ok := estimateInputWitnessSize(p, i)
+		if !ok {
+			summary.Warnings = append(summary.Warnings,
+				fmt.Sprintf("input [%d] witness size is unknown, vsize is underestimated", i),
+			)
+		}
+		if size == 0 {
+			noWitnessInputs++
+		}
+		witnessSize += size
+
+		scriptSigSize, scriptSigOk := estimateInputScriptSigSize(p, i)
+		if ok && !scriptSigOk {
+			summary.Warnings = append(summary.Warnings,
+				fmt.Sprintf("input [%d] scriptSig size is unknown, vsize is underestimated", i),
+			)
+		}
+		baseSize += int64(wire.VarIntSerializeSize(uint64(scriptSigSize))-1) + scriptSigSize
+	}
+
+	summary.Weight = baseSize * blockchain.WitnessScaleFactor
+	if witnessSize > 0 {
+		// segwit marker and flag, and the empty witness count of the inputs without witness
+		summary.Weight += 2 + witnessSize + noWitnessInputs
+	}
+	summary.VSize = (summary.Weight + blockchain.WitnessScaleFactor - 1) / blockchain.WitnessScaleFactor
+
+	return summary
+}
+
+// estimateInputWitnessSize returns the expected serialized witness size of the given input.
+// The final witness is used when present, otherwise the size is derived from the tapscript leaf
+// to be spent or from the prevout script type. Legacy inputs have no witness and return 0,
+// the caller accounts for their empty witness count if the tx has segwit inputs.
+// The boolean is false if no estimation is possible.
+func estimateInputWitnessSize(p *psbt.Packet, inputIndex int) (int64, bool) {
+	in := p.Inputs[inputIndex]
+
+	if len(in.FinalScriptWitness) > 0 {
+		return int64(len(in.FinalScriptWitness)), true
+	}
+
+	if leaf := spentTapLeaf(in); leaf != nil {
+		items := int64(2)
+		size := int64(wire.VarIntSerializeSize(uint64(len(leaf.Script))) + len(leaf.Script))
+		size += int64(wire.VarIntSerializeSize(uint64(len(leaf.ControlBlock))) + len(leaf.ControlBlock))
+
+		closure, err := script.DecodeClosure(leaf.Script)
+		if err == nil {
+			nbSigs := int64(len(closurePubKeys(closure)))
+			items += nbSigs
+			size += nbSigs * schnorrSigWitnessSize
+		}
+
+		conditionWitnesses, err := txutils.GetArkPsbtFields(p, inputIndex, txutils.ConditionWitnessField)
+		if err == nil && len(conditionWitnesses) > 0 {
+			for _, item := range conditionWitnesses[0] {
+				items++
+				size += int64(wire.VarIntSerializeSize(uint64(len(item))) + len(item))
+			}
+		}
+
+		return int64(wire.VarIntSerializeSize(uint64(items))) + size, true
+	}
+
+	prevout := inputPrevout(p, inputIndex)
+	if prevout == nil {
+		return 0, false
+	}
+
+	switch {
+	case isAnchorOutput(prevout):
+		// P2A anchors are spent with an empty witness
+		return 1, true
+	case txscript.IsPayToTaproot(prevout.PkScript):
+		// key path spend
+		return 1 + schnorrSigWitnessSize, true
+	case txscript.IsPayToWitnessPubKeyHash(prevout.PkScript):
+		return p2wpkhWitnessSize, true
+	case txscript.IsWitnessProgram(prevout.PkScript):
+		return 0, false
+	case txscript.IsPayToScriptHash(prevout.PkScript):
+		// nested segwit, the redeem script is the witness program
+		if txscript.IsPayToWitnessPubKeyHash(in.RedeemScript) {
+			return p2wpkhWitnessSize, true
+		}
+		if len(in.RedeemScript) == 0 || txscript.IsWitnessProgram(in.RedeemScript) {
+			return 0, false
+		}
+		return 0, true
+	default:
+		// legacy inputs have no witness
+		return 0, true
+	}
+}
+
+// estimateInputScriptSigSize returns the expected scriptSig size of the given input, without
+// its length prefix. The final scriptSig is used when present, otherwise the size is derived from
+// the prevout script type. The boolean is false if no estimation is possible.
+func estimateInputScriptSigSize(p *psbt.Packet, inputIndex int) (int64, bool) {
+	in := p.Inputs[inputIndex]
+
+	if len(in.FinalScriptSig) > 0 {
+		return int64(len(in.FinalScriptSig)), true
+	}
+	if len(in.FinalScriptWitness) > 0 {
+		return 0, true
+	}
+
+	prevout := inputPrevout(p, inputIndex)
+	if prevout == nil {
+		return 0, false
+	}
+
+	switch {
+	case txscript.IsWitnessProgram(prevout.PkScript):
+		// including P2A anchors
+		return 0, true
+	case txscript.IsPayToPubKeyHash(prevout.PkScript):
+		return p2pkhScriptSigSize, true
+	case txscript.IsPayToScriptHash(prevout.PkScript) && txscript.IsPayToWitnessPubKeyHash(in.RedeemScript):
+		return p2shP2wpkhScriptSigSize, true
+	default:
+		return 0, false
+	}
+}
+
+// spentTapLeaf returns the leaf the input is expected to be spent with: the one signed by
+// the script-spend signatures if any, otherwise the largest leaf so the size is an upper bound
+func spentTapLeaf(in psbt.PInput) *psbt.TaprootTapLeafScript {
+	for _, sig := range in.TaprootScriptSpendSig {
+		if leaf := findTapLeafScript(in, sig.LeafHash); leaf != nil {
+			return leaf
+		}
+	}
+
+	var largest *psbt.TaprootTapLeafScript
+	for _, leaf := range in.TaprootLeafScript {
+		if largest == nil || len(leaf.Script)+len(leaf.ControlBlock) > len(largest.Script)+len(largest.ControlBlock) {
+			largest = leaf
+		}
+	}
+	return largest
+}
+
+// dustThreshold returns the minimum amount of an output with the given script (at 3 sat/vB)
+func dustThreshold(pkScript []byte) int64 {
+	switch {
+	case len(pkScript) > 0 && pkScript[0] == txscript.OP_RETURN:
+		return 0
+	case txscript.IsPayToTaproot(pkScript), txscript.IsPayToWitnessScriptHash(pkScript):
+		return 330
+	case txscript.IsPayToWitnessPubKeyHash(pkScript):
+		return 294
+	case txscript.IsPayToScriptHash(pkScript):
+		return 540
+	default:
+		return 546
+	}
+}
+
+// formatPsbtSummary formats the summary section of a decoded PSBT
+func formatPsbtSummary(summary psbtSummary) string {
+	var output string
+
+	output += fmt.Sprintf("\n%s\n",
+		sectionStyle.Render("Summary:"),
+	)
+
+	totalIn := fmt.Sprintf("%d sats", summary.TotalIn)
+	fee := "unknown"
+	feeRate := "unknown"
+	if !summary.Complete {
+		totalIn += " (incomplete)"
+	} else {
+		fee = fmt.Sprintf("%d sats", summary.Fee)
+		if summary.VSize > 0 {
+			feeRate = fmt.Sprintf("%.2f sat/vB", float64(summary.Fee)/float64(summary.VSize))
+		}
+	}
+
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Total In:"),
+		valueStyle.Render(totalIn),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Total Out:"),
+		valueStyle.Render(fmt.Sprintf("%d sats", summary.TotalOut)),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Fee:"),
+		valueStyle.Render(fee),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Weight:"),
+		valueStyle.Render(fmt.Sprintf("%d WU (estimated)", summary.Weight)),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("VSize:"),
+		valueStyle.Render(fmt.Sprintf("%d vB (estimated)", summary.VSize)),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Fee Rate:"),
+		valueStyle.Render(feeRate),
+	)
+
+	if len(summary.Warnings) > 0 {
+		output += fmt.Sprintf("%s\n",
+			subLabelStyle.Render("Warnings:"),
+		)
+		for _, warning := range summary.Warnings {
+			output += fmt.Sprintf("%s%s\n",
+				subLabelStyle.Render("  -"),
+				warningStyle.Render(warning),
+			)
+		}
+	}
+
+	return output
+}
@@ -0,0 +1,155 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/arkade-os/arkd/pkg/ark-lib/script"
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSummarizePsbtMixedInputs(t *testing.T) {
+	key := newPrivKey(t)
+	taprootScript, err := txscript.PayToTaprootScript(txscript.ComputeTaprootKeyNoScript(key.PubKey()))
+	require.NoError(t, err)
+	legacyScript, err := txscript.NewScriptBuilder().
+		AddOp(txscript.OP_DUP).
+		AddOp(txscript.OP_HASH160).
+		AddData(make([]byte, 20)).
+		AddOp(txscript.OP_EQUALVERIFY).
+		AddOp(txscript.OP_CHECKSIG).
+		Script()
+	require.NoError(t, err)
+
+	p, err := psbt.New(
+		[]*wire.OutPoint{{Hash: chainhash.Hash{1}}, {Hash: chainhash.Hash{2}}},
+		[]*wire.TxOut{{Value: 15_000, PkScript: taprootScript}},
+		2, 0, []uint32{wire.MaxTxInSequenceNum, wire.MaxTxInSequenceNum},
+	)
+	require.NoError(t, err)
+	p.Inputs[0].WitnessUtxo = &wire.TxOut{Value: 10_000, PkScript: taprootScript}
+	p.Inputs[1].WitnessUtxo = &wire.TxOut{Value: 10_000, PkScript: legacyScript}
+
+	summary := summarizePsbt(p)
+	require.True(t, summary.Complete)
+	require.Equal(t, int64(5_000), summary.Fee)
+
+	require.Empty(t, summary.Warnings)
+
+	// the scriptSig of the legacy input, then marker and flag, the key path witness of the taproot
+	// input and the empty witness of the legacy one
+	expectedWeight := (int64(p.UnsignedTx.SerializeSizeStripped())+p2pkhScriptSigSize)*blockchain.WitnessScaleFactor +
+		2 + (1 + schnorrSigWitnessSize) + 1
+	require.Equal(t, expectedWeight, summary.Weight)
+
+	// without segwit inputs there is no witness at all
+	p.Inputs[0].WitnessUtxo = &wire.TxOut{Value: 10_000, PkScript: legacyScript}
+	summary = summarizePsbt(p)
+	require.Equal(t, (int64(p.UnsignedTx.SerializeSizeStripped())+2*p2pkhScriptSigSize)*blockchain.WitnessScaleFactor, summary.Weight)
+}
+
+func TestSummarizePsbtScriptSig(t *testing.T) {
+	key := newPrivKey(t)
+	p2wpkhScript := newP2WPKHScript(t, key.PubKey())
+	p2shScript := func(redeemScript []byte) []byte {
+		pkScript, err := txscript.NewScriptBuilder().
+			AddOp(txscript.OP_HASH160).
+			AddData(btcutil.Hash160(redeemScript)).
+			AddOp(txscript.OP_EQUAL).
+			Script()
+		require.NoError(t, err)
+		return pkScript
+	}
+	multisigScript, err := txscript.MultiSigScript([]*btcutil.AddressPubKey{newAddressPubKey(t, key.PubKey())}, 1)
+	require.NoError(t, err)
+	// a final scriptSig above 252 bytes has a 3 bytes length prefix
+	finalScriptSig := make([]byte, 300)
+
+	tests := []struct {
+		name           string
+		pkScript       []byte
+		redeemScript   []byte
+		finalScriptSig []byte
+		scriptSigSize  int64
+		witnessSize    int64
+		warning        string
+	}{
+		{"P2SH-P2WPKH", p2shScript(p2wpkhScript), p2wpkhScript, nil, 1 + p2shP2wpkhScriptSigSize, p2wpkhWitnessSize, ""},
+		{"final scriptSig", p2shScript(multisigScript), multisigScript, finalScriptSig, 3 + 300, 0, ""},
+		{"P2SH multisig", p2shScript(multisigScript), multisigScript, nil, 1, 0, "input [0] scriptSig size is unknown, vsize is underestimated"},
+		{"P2SH without redeem script", p2shScript(multisigScript), nil, nil, 1, 0, "input [0] witness size is unknown, vsize is underestimated"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p, err := psbt.New(
+				[]*wire.OutPoint{{Hash: chainhash.Hash{1}}},
+				[]*wire.TxOut{{Value: 9_000, PkScript: p2wpkhScript}},
+				2, 0, []uint32{wire.MaxTxInSequenceNum},
+			)
+			require.NoError(t, err)
+			p.Inputs[0].WitnessUtxo = &wire.TxOut{Value: 10_000, PkScript: test.pkScript}
+			p.Inputs[0].RedeemScript = test.redeemScript
+			p.Inputs[0].FinalScriptSig = test.finalScriptSig
+
+			summary := summarizePsbt(p)
+			if test.warning == "" {
+				require.Empty(t, summary.Warnings)
+			} else {
+				require.Equal(t, []string{test.warning}, summary.Warnings)
+			}
+
+			// the unsigned tx serializes the 1 byte length of an empty scriptSig
+			expectedWeight := (int64(p.UnsignedTx.SerializeSizeStripped()) - 1 + test.scriptSigSize) * blockchain.WitnessScaleFactor
+			if test.witnessSize > 0 {
+				expectedWeight += 2 + test.witnessSize
+			}
+			require.Equal(t, expectedWeight, summary.Weight)
+		})
+	}
+}
+
+func newAddressPubKey(t *testing.T, pubKey *btcec.PublicKey) *btcutil.AddressPubKey {
+	t.Helper()
+
+	address, err := btcutil.NewAddressPubKey(pubKey.SerializeCompressed(), &chaincfg.MainNetParams)
+	require.NoError(t, err)
+	return address
+}
+
+func TestSpentTapLeaf(t *testing.T) {
+	alice := newPrivKey(t)
+	bob := newPrivKey(t)
+
+	singleSig, err := (&script.MultisigClosure{PubKeys: []*btcec.PublicKey{alice.PubKey()}}).Script()
+	require.NoError(t, err)
+	multiSig, err := (&script.MultisigClosure{PubKeys: []*btcec.PublicKey{alice.PubKey(), bob.PubKey()}}).Script()
+	require.NoError(t, err)
+
+	p := newScriptSpendPsbt(t, singleSig)
+	in := &p.Inputs[0]
+	in.TaprootLeafScript = append(in.TaprootLeafScript, &psbt.TaprootTapLeafScript{
+		ControlBlock: in.TaprootLeafScript[0].ControlBlock,
+		Script:       multiSig,
+		LeafVersion:  txscript.BaseLeafVersion,
+	})
+
+	// without signatures the largest leaf is an upper bound
+	require.Equal(t, multiSig, spentTapLeaf(*in).Script)
+
+	// the signed leaf is the one spent
+	signScriptSpend(t, p, alice, txscript.SigHashDefault, txscript.SigHashDefault)
+	require.Equal(t, singleSig, spentTapLeaf(*in).Script)
+
+	size, ok := estimateInputWitnessSize(p, 0)
+	require.True(t, ok)
+	expected := int64(1) + schnorrSigWitnessSize +
+		int64(1+len(singleSig)) + int64(1+len(in.TaprootLeafScript[0].ControlBlock))
+	require.Equal(t, expected, size)
+}
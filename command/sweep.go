@@ -0,0 +1,134 @@
+package command
+
+import (
+	"fmt"
+	"time"
+
+	arklib "github.com/arkade-os/arkd/pkg/ark-lib"
+	"github.com/arkade-os/arkd/pkg/ark-lib/script"
+	"github.com/arkade-os/arkd/pkg/ark-lib/txutils"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+// SweepOptions locates a sweep path in time. ConfirmedAt and Current are block heights for
+// block based expiries and unix timestamps for time based ones, zero if unknown.
+type SweepOptions struct {
+	ConfirmedAt int64
+	Current     int64
+}
+
+// sweepClosure returns the closure if it can be a sweep leaf: a single key (the server) after a CSV.
+// Exit leaves have the same shape, callers must check the leaf is the only one of its taptree.
+func sweepClosure(closure script.Closure) (*script.CSVMultisigClosure, bool) {
+	csvClosure, ok := closure.(*script.CSVMultisigClosure)
+	if !ok || len(csvClosure.PubKeys) != 1 {
+		return nil, false
+	}
+	return csvClosure, true
+}
+
+// formatSweepPath formats the sweep key and expiry, and when it becomes valid if the confirmation is known
+func formatSweepPath(closure *script.CSVMultisigClosure, opts SweepOptions, indent string) string {
+	var output string
+
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render(indent+"Sweep Key:"),
+		valueStyle.Render(fmt.Sprintf("%x", schnorr.SerializePubKey(closure.PubKeys[0]))),
+	)
+	output += formatSweepTiming(closure.Locktime, opts, indent)
+
+	return output
+}
+
+// formatSweepTiming formats the sweep expiry, and when it becomes valid if the confirmation is known
+func formatSweepTiming(expiry arklib.RelativeLocktime, opts SweepOptions, indent string) string {
+	var output string
+
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render(indent+"Sweep After:"),
+		valueStyle.Render(formatLocktimeDuration(expiry)+" from confirmation"),
+	)
+
+	if opts.ConfirmedAt <= 0 {
+		return output
+	}
+
+	sweepableAt := opts.ConfirmedAt + int64(expiry.Value)
+	if expiry.Type == arklib.LocktimeTypeSecond {
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render(indent+"Sweepable At:"),
+			valueStyle.Render(fmt.Sprintf("%s (%d)", time.Unix(sweepableAt, 0).UTC().Format(time.RFC3339), sweepableAt)),
+		)
+	} else {
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render(indent+"Sweepable At:"),
+			valueStyle.Render(fmt.Sprintf("height %d", sweepableAt)),
+		)
+	}
+
+	if opts.Current <= 0 {
+		return output
+	}
+
+	if opts.Current >= sweepableAt {
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render(indent+"Sweep Status:"),
+			invalidStyle.Render("sweepable, the server can claim the funds"),
+		)
+		return output
+	}
+
+	remaining := sweepableAt - opts.Current
+	if expiry.Type == arklib.LocktimeTypeSecond {
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render(indent+"Sweep Status:"),
+			validStyle.Render(fmt.Sprintf("not sweepable yet, %s left", time.Duration(remaining)*time.Second)),
+		)
+	} else {
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render(indent+"Sweep Status:"),
+			validStyle.Render(fmt.Sprintf("not sweepable yet, %d blocks left", remaining)),
+		)
+	}
+
+	return output
+}
+
+// formatInputSweep formats the sweep path spent by a PSBT input: a tapscript leaf alone in its
+// taptree, or the expiry of a tree node whose taptree is not part of the PSBT
+func formatInputSweep(p *psbt.Packet, inputIndex int, opts SweepOptions) string {
+	var output string
+
+	for _, leaf := range p.Inputs[inputIndex].TaprootLeafScript {
+		// a control block without merkle path commits to a single leaf taptree
+		if len(leaf.ControlBlock) != txscript.ControlBlockBaseSize {
+			continue
+		}
+		closure, err := script.DecodeClosure(leaf.Script)
+		if err != nil {
+			continue
+		}
+		if sweep, ok := sweepClosure(closure); ok {
+			output += fmt.Sprintf("%s\n",
+				subLabelStyle.Render("  Sweep Path:"),
+			)
+			output += formatSweepPath(sweep, opts, "    ")
+		}
+	}
+
+	if output != "" {
+		return output
+	}
+
+	expiries, err := txutils.GetArkPsbtFields(p, inputIndex, txutils.VtxoTreeExpiryField)
+	if err != nil || len(expiries) == 0 {
+		return ""
+	}
+	output += fmt.Sprintf("%s\n",
+		subLabelStyle.Render("  Sweep Path:"),
+	)
+	output += formatSweepTiming(expiries[0], opts, "    ")
+	return output
+}
@@ -9,7 +9,15 @@ import (
 	"github.com/btcsuite/btcd/txscript"
 )
 
-func RunTaptreeDecode(input string) error {
+// TaptreeDecodeOptions tunes the output of RunTaptreeDecode
+type TaptreeDecodeOptions struct {
+	// Sweep locates the sweep leaf of batch outputs and tree nodes in time
+	Sweep SweepOptions
+	// Server checks the signer keys and exit delays of the leaves
+	Server ServerCheckOptions
+}
+
+func RunTaptreeDecode(input string, opts TaptreeDecodeOptions) error {
 	bytesInput, err := hex.DecodeString(input)
 	if err != nil {
 		return fmt.Errorf("failed to decode input: %w", err)
@@ -19,6 +27,11 @@ func RunTaptreeDecode(input string) error {
 		return fmt.Errorf("failed to decode taptree: %w", err)
 	}
 
+	profile, err := loadProfile(opts.Server.Profile)
+	if err != nil {
+		return err
+	}
+
 	var output string
 
 	// Print scripts in taptree
@@ -46,6 +59,19 @@ func RunTaptreeDecode(input string) error {
 				valueStyle.Render(disasm),
 			)
 		}
+
+		// batch outputs and tree nodes only have the server sweep leaf
+		if len(taptree) == 1 {
+			if closure, err := script.DecodeClosure(scriptBytes); err == nil {
+				if sweep, ok := sweepClosure(closure); ok {
+					output += fmt.Sprintf("%s%s\n",
+						subLabelStyle.Render("  type:"),
+						valueStyle.Render("sweep (batch output or tree node)"),
+					)
+					output += formatSweepPath(sweep, opts.Sweep, "  ")
+				}
+			}
+		}
 	}
 
 	// Get tapkey and create pk script
@@ -84,6 +110,13 @@ func RunTaptreeDecode(input string) error {
 		)
 	}
 
+	if profile != nil {
+		output += fmt.Sprintf("%s\n",
+			sectionStyle.Render(fmt.Sprintf("Server Checks (%s):", profile.Name)),
+		)
+		output += formatServerTaptreeChecks(taptree, profile, "")
+	}
+
 	fmt.Print(output)
 	return nil
 }
@@ -138,3 +171,23 @@ func RunTaptreeEncode(input []string) error {
 	fmt.Print(output)
 	return nil
 }
+
+// parseTaptree decodes a hex encoded taptree into its scripts and vtxo script
+func parseTaptree(input string) (txutils.TapTree, script.VtxoScript, error) {
+	bytesInput, err := hex.DecodeString(input)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decode taptree: %w", err)
+	}
+
+	taptree, err := txutils.DecodeTapTree(bytesInput)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decode taptree: %w", err)
+	}
+
+	vtxoScript, err := script.ParseVtxoScript(taptree)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse vtxo script: %w", err)
+	}
+
+	return taptree, vtxoScript, nil
+}
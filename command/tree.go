@@ -0,0 +1,586 @@
+package command
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/arkade-os/arkd/pkg/ark-lib/script"
+	"github.com/arkade-os/arkd/pkg/ark-lib/tree"
+	"github.com/arkade-os/arkd/pkg/ark-lib/txutils"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/charmbracelet/lipgloss"
+	lipglosstree "github.com/charmbracelet/lipgloss/tree"
+)
+
+var treeEnumeratorStyle = lipgloss.NewStyle().
+	Foreground(lipgloss.Color("240")).
+	MarginRight(1)
+
+func RunTreeDecode(inputs []string) error {
+	txTree, err := parseTxTree(inputs)
+	if err != nil {
+		return err
+	}
+
+	var output string
+
+	output += fmt.Sprintf("\n%s\n",
+		sectionStyle.Render("Tree:"),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Root:"),
+		valueStyle.Render(txTree.Root.UnsignedTx.TxID()),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Nodes:"),
+		valueStyle.Render(fmt.Sprintf("%d", countTxTreeNodes(txTree))),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Leaves:"),
+		valueStyle.Render(fmt.Sprintf("%d", len(txTree.Leaves()))),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Depth:"),
+		valueStyle.Render(fmt.Sprintf("%d", txTreeDepth(txTree))),
+	)
+
+	output += fmt.Sprintf("\n%s\n\n",
+		sectionStyle.Render("Nodes:"),
+	)
+	output += renderTxTree(txTree, "").String()
+
+	fmt.Println(output)
+	return nil
+}
+
+// parseTxTree builds a tx tree either from the flat JSON format emitted by arkd
+// (a list of {txid, tx, children} nodes, as an array or one node per line),
+// or from a list of PSBTs linked together by their outpoints.
+// Each input may be inline or a file.
+func parseTxTree(inputs []string) (*tree.TxTree, error) {
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf("missing tree")
+	}
+
+	contents := make([]string, 0, len(inputs))
+	for _, input := range inputs {
+		content, err := readArg(input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tree: %w", err)
+		}
+		contents = append(contents, content)
+	}
+
+	if len(contents) == 1 && isJSON(contents[0]) {
+		flatTxTree, err := parseFlatTxTree(contents[0])
+		if err != nil {
+			return nil, err
+		}
+		txTree, err := tree.NewTxTree(flatTxTree)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tree: %w", err)
+		}
+		return txTree, nil
+	}
+
+	packets := make([]*psbt.Packet, 0, len(contents))
+	for _, content := range contents {
+		// a file may hold several PSBTs, one per line
+		for _, encoded := range strings.Fields(content) {
+			p, err := parsePsbt(encoded)
+			if err != nil {
+				return nil, err
+			}
+			packets = append(packets, p)
+		}
+	}
+
+	flatTxTree, err := flatTxTreeFromPsbts(packets)
+	if err != nil {
+		return nil, err
+	}
+	txTree, err := tree.NewTxTree(flatTxTree)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tree: %w", err)
+	}
+	return txTree, nil
+}
+
+func isJSON(content string) bool {
+	return strings.HasPrefix(content, "[") || strings.HasPrefix(content, "{")
+}
+
+// parseFlatTxTree parses a flat tx tree formatted as a JSON array of nodes or as JSON lines
+func parseFlatTxTree(content string) (tree.FlatTxTree, error) {
+	var flatTxTree tree.FlatTxTree
+	if strings.HasPrefix(content, "[") {
+		if err := json.Unmarshal([]byte(content), &flatTxTree); err != nil {
+			return nil, fmt.Errorf("failed to parse tree json: %w", err)
+		}
+		return flatTxTree, nil
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(content))
+	for decoder.More() {
+		var node tree.TxTreeNode
+		if err := decoder.Decode(&node); err != nil {
+			return nil, fmt.Errorf("failed to parse tree json: %w", err)
+		}
+		flatTxTree = append(flatTxTree, node)
+	}
+	return flatTxTree, nil
+}
+
+// flatTxTreeFromPsbts links the PSBTs by outpoint: a PSBT is the child of the
+// PSBT whose output it spends with its first input
+func flatTxTreeFromPsbts(packets []*psbt.Packet) (tree.FlatTxTree, error) {
+	byTxid := make(map[string]int, len(packets))
+	for i, p := range packets {
+		txid := p.UnsignedTx.TxID()
+		if _, ok := byTxid[txid]; ok {
+			return nil, fmt.Errorf("duplicated tx %s", txid)
+		}
+		byTxid[txid] = i
+	}
+
+	flatTxTree := make(tree.FlatTxTree, 0, len(packets))
+	for _, p := range packets {
+		encoded, err := p.B64Encode()
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode tx: %w", err)
+		}
+		flatTxTree = append(flatTxTree, tree.TxTreeNode{
+			Txid:     p.UnsignedTx.TxID(),
+			Tx:       encoded,
+			Children: make(map[uint32]string),
+		})
+	}
+
+	for _, p := range packets {
+		if len(p.UnsignedTx.TxIn) == 0 {
+			continue
+		}
+		prevout := p.UnsignedTx.TxIn[0].PreviousOutPoint
+		parent, ok := byTxid[prevout.Hash.String()]
+		if !ok {
+			continue
+		}
+		flatTxTree[parent].Children[prevout.Index] = p.UnsignedTx.TxID()
+	}
+
+	return flatTxTree, nil
+}
+
+// renderTxTree renders a tx tree node and its children, edge is the parent output spent by the node
+func renderTxTree(txTree *tree.TxTree, edge string) *lipglosstree.Tree {
+	rendered := lipglosstree.Root(formatTxTreeNode(txTree, edge)).
+		Enumerator(lipglosstree.RoundedEnumerator).
+		EnumeratorStyle(treeEnumeratorStyle)
+
+	for _, index := range sortedChildIndexes(txTree) {
+		rendered.Child(renderTxTree(txTree.Children[index], fmt.Sprintf("[%d] ", index)))
+	}
+	return rendered
+}
+
+// formatTxTreeNode formats the amount, cosigners and expiry of a tx tree node.
+// Leaves also list their outputs.
+func formatTxTreeNode(txTree *tree.TxTree, edge string) string {
+	p := txTree.Root
+	isLeaf := len(txTree.Children) == 0
+	lines := make([]string, 0)
+
+	header := edge + valueStyle.Render(p.UnsignedTx.TxID())
+	if isLeaf {
+		header += " " + validStyle.Render("(leaf)")
+	}
+	lines = append(lines, header)
+
+	var amount int64
+	for _, txOut := range p.UnsignedTx.TxOut {
+		if !isAnchorOutput(txOut) {
+			amount += txOut.Value
+		}
+	}
+	lines = append(lines, fmt.Sprintf("%s%s",
+		commonLabelStyle.Render("Amount:"),
+		valueStyle.Render(fmt.Sprintf("%d sats", amount)),
+	))
+
+	if len(p.Inputs) > 0 {
+		cosignerKeys, err := txutils.GetArkPsbtFields(p, 0, txutils.CosignerPublicKeyField)
+		if err == nil && len(cosignerKeys) > 0 {
+			lines = append(lines, commonLabelStyle.Render("Cosigners:"))
+			for _, cosignerKey := range cosignerKeys {
+				if cosignerKey.PublicKey == nil {
+					continue
+				}
+				lines = append(lines, fmt.Sprintf("%s%s",
+					commonLabelStyle.Render(fmt.Sprintf("  [%d]:", cosignerKey.Index)),
+					valueStyle.Render(hex.EncodeToString(schnorr.SerializePubKey(cosignerKey.PublicKey))),
+				))
+			}
+		}
+
+		expiries, err := txutils.GetArkPsbtFields(p, 0, txutils.VtxoTreeExpiryField)
+		if err == nil && len(expiries) > 0 {
+			lines = append(lines, fmt.Sprintf("%s%s",
+				commonLabelStyle.Render("Expiry:"),
+				valueStyle.Render(fmt.Sprintf("%d %s", expiries[0].Value, strings.ToLower(formatRelativeLocktimeType(expiries[0].Type)))),
+			))
+		}
+	}
+
+	if isLeaf {
+		for i, txOut := range p.UnsignedTx.TxOut {
+			lines = append(lines, fmt.Sprintf("%s%s",
+				commonLabelStyle.Render(fmt.Sprintf("Output [%d]:", i)),
+				valueStyle.Render(formatTxTreeOutput(txOut)),
+			))
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func formatTxTreeOutput(txOut *wire.TxOut) string {
+	if isAnchorOutput(txOut) {
+		return "anchor"
+	}
+	return fmt.Sprintf("%d sats %x", txOut.Value, txOut.PkScript)
+}
+
+func sortedChildIndexes(txTree *tree.TxTree) []uint32 {
+	indexes := make([]uint32, 0, len(txTree.Children))
+	for index := range txTree.Children {
+		indexes = append(indexes, index)
+	}
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i] < indexes[j] })
+	return indexes
+}
+
+func countTxTreeNodes(txTree *tree.TxTree) int {
+	count := 1
+	for _, child := range txTree.Children {
+		count += countTxTreeNodes(child)
+	}
+	return count
+}
+
+func txTreeDepth(txTree *tree.TxTree) int {
+	depth := 0
+	for _, child := range txTree.Children {
+		depth = max(depth, txTreeDepth(child))
+	}
+	return depth + 1
+}
+
+// batchOutputIndex is the index of the commitment tx output spent by the vtxo tree root
+const batchOutputIndex = 0
+
+// TreeValidateOptions describes the vtxo tree to validate
+type TreeValidateOptions struct {
+	Commitment string
+	Tree       []string
+	// Signer is the hex encoded server public key, inferred from the cosigners if empty
+	Signer string
+	// Vtxos are the expected leaf outputs formatted as address:amount
+	Vtxos []string
+}
+
+func RunTreeValidate(opts TreeValidateOptions) error {
+	commitmentArg, err := readArg(opts.Commitment)
+	if err != nil {
+		return fmt.Errorf("failed to read commitment tx: %w", err)
+	}
+	commitmentTx, err := parsePsbt(commitmentArg)
+	if err != nil {
+		return fmt.Errorf("commitment tx: %w", err)
+	}
+
+	txTree, err := parseTxTree(opts.Tree)
+	if err != nil {
+		return err
+	}
+
+	expectedVtxos := make([]*wire.TxOut, 0, len(opts.Vtxos))
+	for i, vtxo := range opts.Vtxos {
+		txOut, _, err := parseOutput(vtxo)
+		if err != nil {
+			return fmt.Errorf("invalid vtxo [%d]: %w", i, err)
+		}
+		expectedVtxos = append(expectedVtxos, txOut)
+	}
+
+	var signer *btcec.PublicKey
+	signerSource := "--signer"
+	if opts.Signer != "" {
+		signer, err = parsePubKey(opts.Signer)
+		if err != nil {
+			return err
+		}
+	} else {
+		signer, err = inferTreeSigner(txTree, commitmentTx)
+		if err != nil {
+			return fmt.Errorf("failed to infer signer, use --signer: %w", err)
+		}
+		signerSource = "cosigners"
+	}
+
+	var output string
+
+	output += fmt.Sprintf("\n%s\n",
+		sectionStyle.Render("Tree:"),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Commitment:"),
+		valueStyle.Render(commitmentTx.UnsignedTx.TxID()),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Root:"),
+		valueStyle.Render(txTree.Root.UnsignedTx.TxID()),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Signer:"),
+		valueStyle.Render(fmt.Sprintf("%x (%s)", schnorr.SerializePubKey(signer), signerSource)),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Nodes:"),
+		valueStyle.Render(fmt.Sprintf("%d", countTxTreeNodes(txTree))),
+	)
+
+	validationErrs := validateVtxoTree(txTree, commitmentTx, signer, expectedVtxos)
+	if len(validationErrs) == 0 {
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render("Validation:"),
+			validStyle.Render("valid"),
+		)
+	} else {
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render("Validation:"),
+			invalidStyle.Render("invalid"),
+		)
+		for _, validationErr := range validationErrs {
+			output += fmt.Sprintf("%s%s\n",
+				subLabelStyle.Render("  -"),
+				invalidStyle.Render(validationErr),
+			)
+		}
+	}
+
+	fmt.Print(output)
+	return nil
+}
+
+// validateVtxoTree runs the checks of ark-lib's tree.ValidateVtxoTree, reporting every failure
+// instead of the first one. Each node is checked against the expiry it advertises.
+func validateVtxoTree(txTree *tree.TxTree, commitmentTx *psbt.Packet, signer *btcec.PublicKey, expectedVtxos []*wire.TxOut) []string {
+	errs := make([]string, 0)
+
+	if len(commitmentTx.UnsignedTx.TxOut) <= batchOutputIndex {
+		return append(errs, "commitment tx has no batch output")
+	}
+	batchOutput := commitmentTx.UnsignedTx.TxOut[batchOutputIndex]
+
+	root := txTree.Root
+	if len(root.UnsignedTx.TxIn) != 1 {
+		errs = append(errs, fmt.Sprintf("root: expected 1 input, got %d", len(root.UnsignedTx.TxIn)))
+	} else {
+		prevout := root.UnsignedTx.TxIn[0].PreviousOutPoint
+		if prevout.Hash != commitmentTx.UnsignedTx.TxHash() || prevout.Index != batchOutputIndex {
+			errs = append(errs, fmt.Sprintf("root: spends %s instead of the commitment batch output", prevout))
+		}
+	}
+
+	errs = append(errs, validateTxTreeNode(txTree, batchOutput, signer)...)
+	errs = append(errs, validateTreeLeaves(txTree, expectedVtxos)...)
+
+	return errs
+}
+
+// validateTxTreeNode checks the node spends the parent output with the script committed to its
+// cosigners and sweep leaf, and that its outputs sum to the parent output amount
+func validateTxTreeNode(txTree *tree.TxTree, parentOutput *wire.TxOut, signer *btcec.PublicKey) []string {
+	errs := make([]string, 0)
+	p := txTree.Root
+	prefix := fmt.Sprintf("node %s", p.UnsignedTx.TxID())
+
+	if p.UnsignedTx.Version != 3 {
+		errs = append(errs, fmt.Sprintf("%s: unexpected version %d, expected 3", prefix, p.UnsignedTx.Version))
+	}
+
+	var sum int64
+	hasAnchor := false
+	for _, txOut := range p.UnsignedTx.TxOut {
+		sum += txOut.Value
+		if isAnchorOutput(txOut) {
+			hasAnchor = true
+		}
+	}
+	if sum != parentOutput.Value {
+		errs = append(errs, fmt.Sprintf("%s: outputs (%d sats) do not sum to the spent output (%d sats)", prefix, sum, parentOutput.Value))
+	}
+	if !hasAnchor {
+		errs = append(errs, fmt.Sprintf("%s: missing anchor output", prefix))
+	}
+
+	if len(p.Inputs) > 0 {
+		expectedPkScript, err := txTreeNodeScript(p, signer)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", prefix, err))
+		} else if !bytes.Equal(expectedPkScript, parentOutput.PkScript) {
+			errs = append(errs, fmt.Sprintf("%s: spent output script does not match the cosigners and sweep leaf", prefix))
+		}
+	}
+
+	for _, index := range sortedChildIndexes(txTree) {
+		child := txTree.Children[index]
+		if int(index) >= len(p.UnsignedTx.TxOut) {
+			errs = append(errs, fmt.Sprintf("%s: child output index %d out of bounds", prefix, index))
+			continue
+		}
+		if len(child.Root.UnsignedTx.TxIn) != 1 {
+			errs = append(errs, fmt.Sprintf("%s: child %s: expected 1 input, got %d", prefix, child.Root.UnsignedTx.TxID(), len(child.Root.UnsignedTx.TxIn)))
+		} else {
+			prevout := child.Root.UnsignedTx.TxIn[0].PreviousOutPoint
+			if prevout.Hash != p.UnsignedTx.TxHash() || prevout.Index != index {
+				errs = append(errs, fmt.Sprintf("%s: child %s does not spend output %d", prefix, child.Root.UnsignedTx.TxID(), index))
+			}
+		}
+		errs = append(errs, validateTxTreeNode(child, p.UnsignedTx.TxOut[index], signer)...)
+	}
+
+	return errs
+}
+
+// txTreeNodeScript computes the taproot script a node input must spend: the MuSig2 aggregate
+// of its cosigners tweaked with the signer sweep leaf, using the node's advertised expiry
+func txTreeNodeScript(p *psbt.Packet, signer *btcec.PublicKey) ([]byte, error) {
+	cosigners, err := txutils.ParseCosignerKeysFromArkPsbt(p, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse cosigners: %w", err)
+	}
+	cosigners = uniquePubKeys(cosigners)
+	if len(cosigners) == 0 {
+		return nil, fmt.Errorf("missing cosigners")
+	}
+
+	expiries, err := txutils.GetArkPsbtFields(p, 0, txutils.VtxoTreeExpiryField)
+	if err != nil || len(expiries) == 0 {
+		return nil, fmt.Errorf("missing expiry")
+	}
+
+	sweepClosure := &script.CSVMultisigClosure{
+		MultisigClosure: script.MultisigClosure{PubKeys: []*btcec.PublicKey{signer}},
+		Locktime:        expiries[0],
+	}
+	sweepScript, err := sweepClosure.Script()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build sweep leaf: %w", err)
+	}
+	tapTreeRoot := txscript.AssembleTaprootScriptTree(txscript.NewBaseTapLeaf(sweepScript)).RootNode.TapHash()
+
+	aggregatedKey, err := tree.AggregateKeys(cosigners, tapTreeRoot.CloneBytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate cosigners: %w", err)
+	}
+	return script.P2TRScript(aggregatedKey.FinalKey)
+}
+
+// validateTreeLeaves checks the leaves only create taproot vtxos next to their anchor,
+// and if given, that they create exactly the expected vtxos
+func validateTreeLeaves(txTree *tree.TxTree, expectedVtxos []*wire.TxOut) []string {
+	errs := make([]string, 0)
+	leafOutputs := make([]*wire.TxOut, 0)
+
+	for _, leaf := range orderedLeaves(txTree) {
+		for i, txOut := range leaf.UnsignedTx.TxOut {
+			if isAnchorOutput(txOut) {
+				continue
+			}
+			if !txscript.IsPayToTaproot(txOut.PkScript) && !script.IsSubDustScript(txOut.PkScript) {
+				errs = append(errs, fmt.Sprintf("leaf %s: output %d is not a vtxo script", leaf.UnsignedTx.TxID(), i))
+			}
+			leafOutputs = append(leafOutputs, txOut)
+		}
+	}
+
+	if len(expectedVtxos) == 0 {
+		return errs
+	}
+
+	matched := make([]bool, len(leafOutputs))
+	for _, expected := range expectedVtxos {
+		found := false
+		for i, txOut := range leafOutputs {
+			if !matched[i] && txOut.Value == expected.Value && bytes.Equal(txOut.PkScript, expected.PkScript) {
+				matched[i] = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			errs = append(errs, fmt.Sprintf("expected vtxo %d sats %x not found in the leaves", expected.Value, expected.PkScript))
+		}
+	}
+	for i, txOut := range leafOutputs {
+		if !matched[i] {
+			errs = append(errs, fmt.Sprintf("unexpected vtxo %d sats %x in the leaves", txOut.Value, txOut.PkScript))
+		}
+	}
+
+	return errs
+}
+
+// inferTreeSigner finds the signer among the root cosigners: the server cosigns every node,
+// and its sweep leaf must produce the batch output script
+func inferTreeSigner(txTree *tree.TxTree, commitmentTx *psbt.Packet) (*btcec.PublicKey, error) {
+	if len(commitmentTx.UnsignedTx.TxOut) <= batchOutputIndex {
+		return nil, fmt.Errorf("commitment tx has no batch output")
+	}
+	batchOutput := commitmentTx.UnsignedTx.TxOut[batchOutputIndex]
+
+	cosigners, err := txutils.ParseCosignerKeysFromArkPsbt(txTree.Root, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse root cosigners: %w", err)
+	}
+
+	for _, candidate := range uniquePubKeys(cosigners) {
+		pkScript, err := txTreeNodeScript(txTree.Root, candidate)
+		if err == nil && bytes.Equal(pkScript, batchOutput.PkScript) {
+			return candidate, nil
+		}
+	}
+	return nil, fmt.Errorf("no root cosigner matches the batch output script")
+}
+
+func uniquePubKeys(pubKeys []*btcec.PublicKey) []*btcec.PublicKey {
+	unique := make([]*btcec.PublicKey, 0, len(pubKeys))
+	for _, pubKey := range pubKeys {
+		if !containsPubKey(unique, pubKey) {
+			unique = append(unique, pubKey)
+		}
+	}
+	return unique
+}
+
+// orderedLeaves returns the leaves of the tree from left to right, ie. by output index
+func orderedLeaves(txTree *tree.TxTree) []*psbt.Packet {
+	if len(txTree.Children) == 0 {
+		return []*psbt.Packet{txTree.Root}
+	}
+
+	leaves := make([]*psbt.Packet, 0)
+	for _, index := range sortedChildIndexes(txTree) {
+		leaves = append(leaves, orderedLeaves(txTree.Children[index])...)
+	}
+	return leaves
+}
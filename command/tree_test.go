@@ -0,0 +1,148 @@
+package command
+
+import (
+	"fmt"
+	"testing"
+
+	arklib "github.com/arkade-os/arkd/pkg/ark-lib"
+	"github.com/arkade-os/arkd/pkg/ark-lib/tree"
+	"github.com/arkade-os/arkd/pkg/ark-lib/txutils"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/require"
+)
+
+const treeTestAmount = 10_000
+
+var treeTestAnchor = &wire.TxOut{Value: 0, PkScript: []byte{txscript.OP_1, txscript.OP_DATA_2, 0x4e, 0x73}}
+
+// treeTestFixtures is a commitment tx and its tree of two nodes: the root and a leaf creating a vtxo
+type treeTestFixtures struct {
+	signer     *btcec.PublicKey
+	commitment *psbt.Packet
+	txTree     *tree.TxTree
+	vtxo       *wire.TxOut
+}
+
+// newTreeNode returns a tree tx spending the outpoint with its cosigners and expiry fields
+func newTreeNode(t *testing.T, prevout wire.OutPoint, cosigners []*btcec.PublicKey, outputs ...*wire.TxOut) *psbt.Packet {
+	t.Helper()
+
+	p, err := psbt.New([]*wire.OutPoint{&prevout}, outputs, 3, 0, []uint32{wire.MaxTxInSequenceNum})
+	require.NoError(t, err)
+	for i, cosigner := range cosigners {
+		err := txutils.SetArkPsbtField(p, 0, txutils.CosignerPublicKeyField, txutils.IndexedCosignerPublicKey{
+			Index:     i,
+			PublicKey: cosigner,
+		})
+		require.NoError(t, err)
+	}
+	err = txutils.SetArkPsbtField(p, 0, txutils.VtxoTreeExpiryField, arklib.RelativeLocktime{
+		Type:  arklib.LocktimeTypeSecond,
+		Value: 1024,
+	})
+	require.NoError(t, err)
+	return p
+}
+
+func newTreeTestFixtures(t *testing.T) treeTestFixtures {
+	t.Helper()
+
+	signer := newPrivKey(t).PubKey()
+	cosigners := []*btcec.PublicKey{signer, newPrivKey(t).PubKey()}
+	vtxoScript, err := txscript.PayToTaprootScript(txscript.ComputeTaprootKeyNoScript(newPrivKey(t).PubKey()))
+	require.NoError(t, err)
+	vtxo := &wire.TxOut{Value: treeTestAmount, PkScript: vtxoScript}
+
+	// the scripts only depend on the node fields, not on the outpoint spent
+	nodeScript := func(p *psbt.Packet) []byte {
+		pkScript, err := txTreeNodeScript(p, signer)
+		require.NoError(t, err)
+		return pkScript
+	}
+	leafScript := nodeScript(newTreeNode(t, wire.OutPoint{}, cosigners))
+
+	root := newTreeNode(t, wire.OutPoint{}, cosigners, &wire.TxOut{Value: treeTestAmount, PkScript: leafScript}, treeTestAnchor)
+	commitment, err := psbt.New(
+		[]*wire.OutPoint{{Hash: chainhash.Hash{1}}},
+		[]*wire.TxOut{{Value: treeTestAmount, PkScript: nodeScript(root)}},
+		3, 0, []uint32{wire.MaxTxInSequenceNum},
+	)
+	require.NoError(t, err)
+	root.UnsignedTx.TxIn[0].PreviousOutPoint = wire.OutPoint{Hash: commitment.UnsignedTx.TxHash(), Index: batchOutputIndex}
+	leaf := newTreeNode(t, wire.OutPoint{Hash: root.UnsignedTx.TxHash()}, cosigners, vtxo, treeTestAnchor)
+
+	return treeTestFixtures{
+		signer:     signer,
+		commitment: commitment,
+		txTree: &tree.TxTree{
+			Root:     root,
+			Children: map[uint32]*tree.TxTree{0: {Root: leaf}},
+		},
+		vtxo: vtxo,
+	}
+}
+
+func TestValidateVtxoTree(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		test := newTreeTestFixtures(t)
+		require.Empty(t, validateVtxoTree(test.txTree, test.commitment, test.signer, nil))
+		require.Empty(t, validateVtxoTree(test.txTree, test.commitment, test.signer, []*wire.TxOut{test.vtxo}))
+
+		signer, err := inferTreeSigner(test.txTree, test.commitment)
+		require.NoError(t, err)
+		require.True(t, test.signer.IsEqual(signer))
+	})
+
+	t.Run("child without input", func(t *testing.T) {
+		test := newTreeTestFixtures(t)
+		leaf := test.txTree.Children[0].Root
+		leaf.UnsignedTx.TxIn = nil
+		leaf.Inputs = nil
+
+		errs := validateVtxoTree(test.txTree, test.commitment, test.signer, nil)
+		require.Contains(t, errs, "node "+test.txTree.Root.UnsignedTx.TxID()+": child "+leaf.UnsignedTx.TxID()+": expected 1 input, got 0")
+	})
+
+	t.Run("child spending another output", func(t *testing.T) {
+		test := newTreeTestFixtures(t)
+		leaf := test.txTree.Children[0].Root
+		leaf.UnsignedTx.TxIn[0].PreviousOutPoint.Index = 1
+
+		errs := validateVtxoTree(test.txTree, test.commitment, test.signer, nil)
+		require.Equal(t, []string{
+			"node " + test.txTree.Root.UnsignedTx.TxID() + ": child " + leaf.UnsignedTx.TxID() + " does not spend output 0",
+		}, errs)
+	})
+
+	t.Run("amounts and anchor", func(t *testing.T) {
+		test := newTreeTestFixtures(t)
+		leaf := test.txTree.Children[0].Root
+		leaf.UnsignedTx.TxOut = []*wire.TxOut{{Value: treeTestAmount - 1, PkScript: test.vtxo.PkScript}}
+
+		prefix := "node " + leaf.UnsignedTx.TxID() + ": "
+		errs := validateVtxoTree(test.txTree, test.commitment, test.signer, []*wire.TxOut{test.vtxo})
+		require.Contains(t, errs, prefix+"outputs (9999 sats) do not sum to the spent output (10000 sats)")
+		require.Contains(t, errs, prefix+"missing anchor output")
+		require.Contains(t, errs, fmt.Sprintf("unexpected vtxo 9999 sats %x in the leaves", test.vtxo.PkScript))
+	})
+
+	t.Run("wrong signer", func(t *testing.T) {
+		test := newTreeTestFixtures(t)
+		errs := validateVtxoTree(test.txTree, test.commitment, newPrivKey(t).PubKey(), nil)
+		require.Len(t, errs, 2)
+		require.Contains(t, errs[0], "spent output script does not match the cosigners and sweep leaf")
+	})
+
+	t.Run("root with two inputs", func(t *testing.T) {
+		test := newTreeTestFixtures(t)
+		root := test.txTree.Root
+		root.UnsignedTx.TxIn = append(root.UnsignedTx.TxIn, root.UnsignedTx.TxIn[0])
+
+		errs := validateVtxoTree(test.txTree, test.commitment, test.signer, nil)
+		require.Contains(t, errs, "root: expected 1 input, got 2")
+	})
+}
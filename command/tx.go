@@ -0,0 +1,388 @@
+package command
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/arkade-os/arkd/pkg/ark-lib/script"
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// taprootSpend is the taproot spend path detected from an input witness
+type taprootSpend struct {
+	// ScriptPath is false for key path spends
+	ScriptPath   bool
+	Signature    []byte
+	LeafScript   []byte
+	ControlBlock *txscript.ControlBlock
+	Annex        []byte
+}
+
+// TxDecodeOptions tunes the output of RunTxDecode
+type TxDecodeOptions struct {
+	// Esplora fetches the prevouts and the onchain status of the transaction
+	Esplora EsploraOptions
+}
+
+func RunTxDecode(txInput string, opts TxDecodeOptions) error {
+	tx, err := parseTx(txInput)
+	if err != nil {
+		return err
+	}
+
+	onchain, err := newOnchainContext(opts.Esplora)
+	if err != nil {
+		return err
+	}
+
+	weight := blockchain.GetTransactionWeight(btcutil.NewTx(tx))
+
+	// the prevouts tell which inputs are taproot spends, nil if unknown
+	prevouts := make([]*wire.TxOut, len(tx.TxIn))
+	if onchain != nil {
+		for i, txIn := range tx.TxIn {
+			prevouts[i], _, err = onchain.prevout(txIn.PreviousOutPoint)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	var output string
+
+	output += fmt.Sprintf("\n%s\n",
+		sectionStyle.Render("Global:"),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Version:"),
+		valueStyle.Render(fmt.Sprintf("%d", tx.Version)),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("LockTime:"),
+		valueStyle.Render(fmt.Sprintf("%d", tx.LockTime)),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("TxId:"),
+		valueStyle.Render(tx.TxHash().String()),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("WTxId:"),
+		valueStyle.Render(tx.WitnessHash().String()),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Size:"),
+		valueStyle.Render(fmt.Sprintf("%d bytes", tx.SerializeSize())),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Weight:"),
+		valueStyle.Render(fmt.Sprintf("%d WU", weight)),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("VSize:"),
+		valueStyle.Render(fmt.Sprintf("%d vB", txVSize(tx))),
+	)
+
+	// Inputs
+	output += fmt.Sprintf("\n%s\n",
+		sectionStyle.Render(fmt.Sprintf("Inputs (%d):", len(tx.TxIn))),
+	)
+	for i, txIn := range tx.TxIn {
+		output += fmt.Sprintf("%s\n",
+			subLabelStyle.Render(fmt.Sprintf("[%d]:", i)),
+		)
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render("  PreviousOutPoint:"),
+			valueStyle.Render(txIn.PreviousOutPoint.String()),
+		)
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render("  Sequence:"),
+			valueStyle.Render(fmt.Sprintf("%d", txIn.Sequence)),
+		)
+		if len(txIn.SignatureScript) > 0 {
+			output += fmt.Sprintf("%s%s\n",
+				subLabelStyle.Render("  ScriptSig:"),
+				valueStyle.Render(hex.EncodeToString(txIn.SignatureScript)),
+			)
+		}
+		if len(txIn.Witness) == 0 {
+			continue
+		}
+
+		output += fmt.Sprintf("%s\n",
+			subLabelStyle.Render(fmt.Sprintf("  Witness (%d):", len(txIn.Witness))),
+		)
+		for j, item := range txIn.Witness {
+			output += fmt.Sprintf("%s%s\n",
+				subLabelStyle.Render(fmt.Sprintf("    [%d]:", j)),
+				valueStyle.Render(formatWitnessItem(item)),
+			)
+		}
+
+		if spend, ok := detectTaprootSpend(txIn.Witness, prevouts[i]); ok {
+			output += formatTaprootSpend(spend)
+		}
+	}
+
+	// Outputs
+	output += fmt.Sprintf("\n%s\n",
+		sectionStyle.Render(fmt.Sprintf("Outputs (%d):", len(tx.TxOut))),
+	)
+	for i, txOut := range tx.TxOut {
+		output += fmt.Sprintf("%s\n",
+			subLabelStyle.Render(fmt.Sprintf("[%d]:", i)),
+		)
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render("  Type:"),
+			valueStyle.Render(classifyOutputScript(txOut.PkScript)),
+		)
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render("  Value:"),
+			valueStyle.Render(fmt.Sprintf("%d sats", txOut.Value)),
+		)
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render("  PkScript:"),
+			valueStyle.Render(hex.EncodeToString(txOut.PkScript)),
+		)
+		disasm, err := txscript.DisasmString(txOut.PkScript)
+		if err == nil {
+			output += fmt.Sprintf("%s%s\n",
+				subLabelStyle.Render("  Script ASM:"),
+				valueStyle.Render(disasm),
+			)
+		}
+	}
+
+	if onchain != nil {
+		onchainOutput, err := onchain.formatOnchainContext(tx, witnessLeafScripts(tx, prevouts), nil)
+		if err != nil {
+			return err
+		}
+		feeOutput, err := onchain.formatFee(tx)
+		if err != nil {
+			return err
+		}
+		output += onchainOutput + feeOutput
+	}
+
+	fmt.Print(output)
+	return nil
+}
+
+// parseTx decodes a hex encoded raw transaction, inline or from a file
+func parseTx(txInput string) (*wire.MsgTx, error) {
+	content, err := readArg(txInput)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transaction: %w", err)
+	}
+
+	txBytes, err := hex.DecodeString(strings.TrimSpace(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode transaction hex: %w", err)
+	}
+
+	var tx wire.MsgTx
+	if err := tx.Deserialize(bytes.NewReader(txBytes)); err != nil {
+		return nil, fmt.Errorf("failed to parse transaction: %w", err)
+	}
+	return &tx, nil
+}
+
+// detectTaprootSpend recognizes taproot key path and script path witnesses (BIP341).
+// A witness is only recognized as a script path spend if its last item is a valid control block.
+// Without the prevout, the witness must also be shaped as a tapscript spend: a base leaf version,
+// and a valid leaf script if the control block has no merkle path.
+func detectTaprootSpend(witness wire.TxWitness, prevout *wire.TxOut) (*taprootSpend, bool) {
+	if prevout != nil && !txscript.IsPayToTaproot(prevout.PkScript) {
+		return nil, false
+	}
+	// a P2WPKH witness is a signature and a compressed public key, which parses as a control block
+	if prevout == nil && isP2WPKHWitness(witness) {
+		return nil, false
+	}
+
+	spend := &taprootSpend{}
+
+	// an annex is the last item if it starts with 0x50, and there are at least two items
+	if len(witness) >= 2 {
+		last := witness[len(witness)-1]
+		if len(last) > 0 && last[0] == txscript.TaprootAnnexTag {
+			spend.Annex = last
+			witness = witness[:len(witness)-1]
+		}
+	}
+
+	if len(witness) == 1 {
+		sigLen := len(witness[0])
+		if sigLen != schnorr.SignatureSize && sigLen != schnorr.SignatureSize+1 {
+			return nil, false
+		}
+		spend.Signature = witness[0]
+		return spend, true
+	}
+
+	if len(witness) < 2 {
+		return nil, false
+	}
+
+	controlBlock, err := txscript.ParseControlBlock(witness[len(witness)-1])
+	if err != nil {
+		return nil, false
+	}
+	leafScript := witness[len(witness)-2]
+	if prevout == nil {
+		if controlBlock.LeafVersion != txscript.BaseLeafVersion {
+			return nil, false
+		}
+		if len(controlBlock.InclusionProof) == 0 && !isValidScript(leafScript) {
+			return nil, false
+		}
+	}
+
+	spend.ScriptPath = true
+	spend.ControlBlock = controlBlock
+	spend.LeafScript = leafScript
+	return spend, true
+}
+
+// isP2WPKHWitness reports whether a witness is shaped as a P2WPKH spend
+func isP2WPKHWitness(witness wire.TxWitness) bool {
+	return len(witness) == 2 && btcec.IsCompressedPubKey(witness[1])
+}
+
+// isValidScript reports whether a script is non-empty and parses as a sequence of opcodes
+func isValidScript(script []byte) bool {
+	if len(script) == 0 {
+		return false
+	}
+	tokenizer := txscript.MakeScriptTokenizer(0, script)
+	for tokenizer.Next() {
+	}
+	return tokenizer.Err() == nil
+}
+
+// formatTaprootSpend formats the spend path of a taproot input, decoding the revealed leaf
+func formatTaprootSpend(spend *taprootSpend) string {
+	var output string
+
+	if !spend.ScriptPath {
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render("  Spend:"),
+			valueStyle.Render("taproot key path"),
+		)
+		sigHashType := txscript.SigHashDefault
+		if len(spend.Signature) == schnorr.SignatureSize+1 {
+			sigHashType = txscript.SigHashType(spend.Signature[schnorr.SignatureSize])
+		}
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render("  SigHash:"),
+			valueStyle.Render(formatSigHashType(sigHashType)),
+		)
+		return output
+	}
+
+	tapLeaf := txscript.NewTapLeaf(spend.ControlBlock.LeafVersion, spend.LeafScript)
+	tapHash := tapLeaf.TapHash()
+	rootHash := spend.ControlBlock.RootHash(spend.LeafScript)
+	outputKey := txscript.ComputeTaprootOutputKey(spend.ControlBlock.InternalKey, rootHash)
+
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("  Spend:"),
+		valueStyle.Render("taproot script path"),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("  LeafHash:"),
+		valueStyle.Render(hex.EncodeToString(tapHash[:])),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("  Leaf Script:"),
+		valueStyle.Render(hex.EncodeToString(spend.LeafScript)),
+	)
+	disasm, err := txscript.DisasmString(spend.LeafScript)
+	if err == nil {
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render("  Leaf Script ASM:"),
+			valueStyle.Render(disasm),
+		)
+	}
+
+	output += fmt.Sprintf("%s\n",
+		subLabelStyle.Render("  Control Block:"),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("    Leaf Version:"),
+		valueStyle.Render(fmt.Sprintf("0x%02x", uint8(spend.ControlBlock.LeafVersion))),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("    Internal Key:"),
+		valueStyle.Render(hex.EncodeToString(schnorr.SerializePubKey(spend.ControlBlock.InternalKey))),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("    Merkle Path:"),
+		valueStyle.Render(fmt.Sprintf("%d hashes", len(spend.ControlBlock.InclusionProof)/32)),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("    Merkle Root:"),
+		valueStyle.Render(hex.EncodeToString(rootHash)),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("    Output Key:"),
+		valueStyle.Render(hex.EncodeToString(schnorr.SerializePubKey(outputKey))),
+	)
+	if len(spend.Annex) > 0 {
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render("  Annex:"),
+			valueStyle.Render(hex.EncodeToString(spend.Annex)),
+		)
+	}
+
+	if closure, err := script.DecodeClosure(spend.LeafScript); err == nil {
+		output += sectionStyle.Render("      Closure: ")
+		output += formatClosure(closure)
+	}
+
+	return output
+}
+
+// formatWitnessItem formats a witness stack item, an empty item being the empty vector
+func formatWitnessItem(item []byte) string {
+	if len(item) == 0 {
+		return "<empty>"
+	}
+	return hex.EncodeToString(item)
+}
+
+// classifyOutputScript returns the standard type of an output script
+func classifyOutputScript(pkScript []byte) string {
+	if isAnchorOutput(&wire.TxOut{PkScript: pkScript}) {
+		return "P2A anchor"
+	}
+	if len(pkScript) > 0 && pkScript[0] == txscript.OP_RETURN {
+		return "OP_RETURN"
+	}
+
+	switch txscript.GetScriptClass(pkScript) {
+	case txscript.WitnessV1TaprootTy:
+		return "P2TR"
+	case txscript.WitnessV0PubKeyHashTy:
+		return "P2WPKH"
+	case txscript.WitnessV0ScriptHashTy:
+		return "P2WSH"
+	case txscript.PubKeyHashTy:
+		return "P2PKH"
+	case txscript.ScriptHashTy:
+		return "P2SH"
+	case txscript.PubKeyTy:
+		return "P2PK"
+	case txscript.MultiSigTy:
+		return "bare multisig"
+	default:
+		return "non-standard"
+	}
+}
@@ -0,0 +1,117 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/arkade-os/arkd/pkg/ark-lib/script"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/require"
+)
+
+// newScriptSpendWitness returns the witness of a script path spend of a single leaf taptree,
+// and the P2TR script it spends
+func newScriptSpendWitness(
+	t *testing.T, leafScript []byte, leafVersion txscript.TapscriptLeafVersion,
+) (wire.TxWitness, []byte) {
+	t.Helper()
+
+	leaf := txscript.NewTapLeaf(leafVersion, leafScript)
+	tapTree := txscript.AssembleTaprootScriptTree(leaf)
+	rootHash := tapTree.RootNode.TapHash()
+	outputKey := txscript.ComputeTaprootOutputKey(script.UnspendableKey(), rootHash[:])
+	pkScript, err := txscript.PayToTaprootScript(outputKey)
+	require.NoError(t, err)
+
+	controlBlock := tapTree.LeafMerkleProofs[0].ToControlBlock(script.UnspendableKey())
+	controlBlock.LeafVersion = leafVersion
+	controlBlockBytes, err := controlBlock.ToBytes()
+	require.NoError(t, err)
+	return wire.TxWitness{make([]byte, 64), leafScript, controlBlockBytes}, pkScript
+}
+
+func TestDetectTaprootSpend(t *testing.T) {
+	key := newPrivKey(t)
+	closure := &script.MultisigClosure{PubKeys: []*btcec.PublicKey{key.PubKey()}}
+	leafScript, err := closure.Script()
+	require.NoError(t, err)
+
+	scriptSpend, taprootScript := newScriptSpendWitness(t, leafScript, txscript.BaseLeafVersion)
+	futureLeafSpend, futureTaprootScript := newScriptSpendWitness(t, leafScript, 0xc2)
+	// a truncated push is not a valid script
+	invalidLeafSpend, invalidTaprootScript := newScriptSpendWitness(t, []byte{txscript.OP_DATA_32, 0x01}, txscript.BaseLeafVersion)
+
+	p2wpkhScript := newP2WPKHScript(t, key.PubKey())
+	// a DER signature with its sighash byte and the compressed public key
+	p2wpkhWitness := wire.TxWitness{make([]byte, 72), key.PubKey().SerializeCompressed()}
+	taprootPrevout := &wire.TxOut{PkScript: taprootScript}
+
+	tests := []struct {
+		name       string
+		witness    wire.TxWitness
+		prevout    *wire.TxOut
+		detected   bool
+		scriptPath bool
+	}{
+		{"P2WPKH", p2wpkhWitness, nil, false, false},
+		{"P2WPKH with prevout", p2wpkhWitness, &wire.TxOut{PkScript: p2wpkhScript}, false, false},
+		{"key path", wire.TxWitness{make([]byte, 64)}, nil, true, false},
+		{"key path with sighash", wire.TxWitness{make([]byte, 65)}, taprootPrevout, true, false},
+		{"key path of a non taproot prevout", wire.TxWitness{make([]byte, 64)}, &wire.TxOut{PkScript: p2wpkhScript}, false, false},
+		{"script path", scriptSpend, nil, true, true},
+		{"script path with prevout", scriptSpend, taprootPrevout, true, true},
+		{"script path with annex", append(scriptSpend, []byte{txscript.TaprootAnnexTag, 0x01}), nil, true, true},
+		{"unknown leaf version", futureLeafSpend, nil, false, false},
+		{"unknown leaf version with prevout", futureLeafSpend, &wire.TxOut{PkScript: futureTaprootScript}, true, true},
+		{"invalid leaf script", invalidLeafSpend, nil, false, false},
+		{"invalid leaf script with prevout", invalidLeafSpend, &wire.TxOut{PkScript: invalidTaprootScript}, true, true},
+		{"empty witness", nil, nil, false, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			spend, ok := detectTaprootSpend(test.witness, test.prevout)
+			require.Equal(t, test.detected, ok)
+			if ok {
+				require.Equal(t, test.scriptPath, spend.ScriptPath)
+			}
+		})
+	}
+}
+
+func TestWitnessLeafScripts(t *testing.T) {
+	key := newPrivKey(t)
+	closure := &script.MultisigClosure{PubKeys: []*btcec.PublicKey{key.PubKey()}}
+	leafScript, err := closure.Script()
+	require.NoError(t, err)
+	scriptSpend, _ := newScriptSpendWitness(t, leafScript, txscript.BaseLeafVersion)
+
+	tx := wire.NewMsgTx(2)
+	tx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Hash: chainhash.Hash{1}},
+		Witness:          wire.TxWitness{make([]byte, 72), key.PubKey().SerializeCompressed()},
+	})
+	tx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Hash: chainhash.Hash{2}},
+		Witness:          scriptSpend,
+	})
+
+	// the signature of the P2WPKH input is not a revealed leaf
+	leaves := witnessLeafScripts(tx, make([]*wire.TxOut, len(tx.TxIn)))
+	require.Equal(t, map[int][][]byte{1: {leafScript}}, leaves)
+}
+
+func newP2WPKHScript(t *testing.T, pubKey *btcec.PublicKey) []byte {
+	t.Helper()
+
+	address, err := btcutil.NewAddressWitnessPubKeyHash(
+		btcutil.Hash160(pubKey.SerializeCompressed()), &chaincfg.MainNetParams,
+	)
+	require.NoError(t, err)
+	pkScript, err := txscript.PayToAddrScript(address)
+	require.NoError(t, err)
+	return pkScript
+}
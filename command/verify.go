@@ -0,0 +1,289 @@
+package command
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/arkade-os/arkd/pkg/ark-lib/script"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	sigStatusValid          = "valid"
+	sigStatusInvalid        = "invalid"
+	sigStatusMissingPrevout = "missing-prevout"
+	// sigStatusUnknownLeaf is a valid signature of a leaf that is not an Ark closure,
+	// the signing key cannot be checked against the closure
+	sigStatusUnknownLeaf = "unknown-leaf"
+)
+
+// signatureCheck is the result of the verification of a single taproot signature
+type signatureCheck struct {
+	// Path is either "key-spend" or "script-spend"
+	Path     string
+	PubKey   []byte
+	LeafHash []byte
+	Status   string
+	Reason   string
+}
+
+// psbtPrevouts collects the prevouts of all inputs from their witness or non-witness utxo,
+// the boolean is false if at least one of them is missing
+func psbtPrevouts(p *psbt.Packet) (map[wire.OutPoint]*wire.TxOut, bool) {
+	prevouts := make(map[wire.OutPoint]*wire.TxOut)
+	complete := true
+
+	for i, txIn := range p.UnsignedTx.TxIn {
+		prevout := inputPrevout(p, i)
+		if prevout == nil {
+			complete = false
+			continue
+		}
+		prevouts[txIn.PreviousOutPoint] = prevout
+	}
+
+	return prevouts, complete
+}
+
+// inputPrevout returns the output spent by the given input, or nil if the psbt does not carry it
+func inputPrevout(p *psbt.Packet, inputIndex int) *wire.TxOut {
+	if inputIndex >= len(p.Inputs) {
+		return nil
+	}
+
+	in := p.Inputs[inputIndex]
+	if in.WitnessUtxo != nil {
+		return in.WitnessUtxo
+	}
+
+	if in.NonWitnessUtxo != nil {
+		outpoint := p.UnsignedTx.TxIn[inputIndex].PreviousOutPoint
+		if in.NonWitnessUtxo.TxHash() == outpoint.Hash &&
+			int(outpoint.Index) < len(in.NonWitnessUtxo.TxOut) {
+			return in.NonWitnessUtxo.TxOut[outpoint.Index]
+		}
+	}
+
+	return nil
+}
+
+// verifyInputSignatures checks every taproot key-spend and script-spend signature of the given input.
+// Taproot sighashes commit to all the prevouts of the transaction, if some are missing
+// the signatures are reported as missing-prevout.
+func verifyInputSignatures(p *psbt.Packet, inputIndex int) []signatureCheck {
+	in := p.Inputs[inputIndex]
+	checks := make([]signatureCheck, 0)
+
+	prevouts, complete := psbtPrevouts(p)
+	prevoutFetcher := txscript.NewMultiPrevOutFetcher(prevouts)
+	// the sighash midstate cannot be computed without all the prevouts
+	var sigHashes *txscript.TxSigHashes
+	if complete {
+		sigHashes = txscript.NewTxSigHashes(p.UnsignedTx, prevoutFetcher)
+	}
+
+	if len(in.TaprootKeySpendSig) > 0 {
+		check := signatureCheck{Path: "key-spend"}
+		prevout := inputPrevout(p, inputIndex)
+
+		switch {
+		case !complete:
+			check.Status = sigStatusMissingPrevout
+		case !txscript.IsPayToTaproot(prevout.PkScript):
+			check.Status = sigStatusInvalid
+			check.Reason = "prevout is not a taproot output"
+		default:
+			check.PubKey = prevout.PkScript[2:]
+			check.Status, check.Reason = verifyKeySpendSig(p, inputIndex, sigHashes, prevoutFetcher)
+		}
+
+		checks = append(checks, check)
+	}
+
+	for _, sig := range in.TaprootScriptSpendSig {
+		check := signatureCheck{
+			Path:     "script-spend",
+			PubKey:   sig.XOnlyPubKey,
+			LeafHash: sig.LeafHash,
+		}
+
+		if !complete {
+			check.Status = sigStatusMissingPrevout
+		} else {
+			check.Status, check.Reason = verifyScriptSpendSig(
+				p, inputIndex, sig, sigHashes, prevoutFetcher,
+			)
+		}
+
+		checks = append(checks, check)
+	}
+
+	return checks
+}
+
+func verifyKeySpendSig(
+	p *psbt.Packet, inputIndex int,
+	sigHashes *txscript.TxSigHashes, prevoutFetcher txscript.PrevOutputFetcher,
+) (string, string) {
+	sig, sigHashType, err := script.ParseTaprootSignature(p.Inputs[inputIndex].TaprootKeySpendSig)
+	if err != nil {
+		return sigStatusInvalid, err.Error()
+	}
+
+	prevout := prevoutFetcher.FetchPrevOutput(p.UnsignedTx.TxIn[inputIndex].PreviousOutPoint)
+	pubKey, err := schnorr.ParsePubKey(prevout.PkScript[2:])
+	if err != nil {
+		return sigStatusInvalid, fmt.Sprintf("invalid taproot output key: %v", err)
+	}
+
+	sigHash, err := txscript.CalcTaprootSignatureHash(
+		sigHashes, sigHashType, p.UnsignedTx, inputIndex, prevoutFetcher,
+	)
+	if err != nil {
+		return sigStatusInvalid, fmt.Sprintf("failed to compute sighash: %v", err)
+	}
+
+	if !sig.Verify(sigHash, pubKey) {
+		return sigStatusInvalid, "signature does not match the taproot output key"
+	}
+
+	return sigStatusValid, ""
+}
+
+func verifyScriptSpendSig(
+	p *psbt.Packet, inputIndex int, sig *psbt.TaprootScriptSpendSig,
+	sigHashes *txscript.TxSigHashes, prevoutFetcher txscript.PrevOutputFetcher,
+) (string, string) {
+	schnorrSig, err := schnorr.ParseSignature(sig.Signature)
+	if err != nil {
+		return sigStatusInvalid, fmt.Sprintf("failed to parse signature: %v", err)
+	}
+
+	pubKey, err := schnorr.ParsePubKey(sig.XOnlyPubKey)
+	if err != nil {
+		return sigStatusInvalid, fmt.Sprintf("failed to parse public key: %v", err)
+	}
+
+	leaf := findTapLeafScript(p.Inputs[inputIndex], sig.LeafHash)
+	if leaf == nil {
+		return sigStatusInvalid, "leaf not found in TaprootLeafScript"
+	}
+
+	// the signing key must be part of the closure revealed in the leaf
+	closure, closureErr := script.DecodeClosure(leaf.Script)
+	if closureErr == nil && !containsPubKey(closurePubKeys(closure), pubKey) {
+		return sigStatusInvalid, "public key is not part of the leaf closure"
+	}
+
+	sigHash, err := txscript.CalcTapscriptSignaturehash(
+		sigHashes, sig.SigHash, p.UnsignedTx, inputIndex, prevoutFetcher,
+		txscript.NewTapLeaf(leaf.LeafVersion, leaf.Script),
+	)
+	if err != nil {
+		return sigStatusInvalid, fmt.Sprintf("failed to compute sighash: %v", err)
+	}
+
+	if !schnorrSig.Verify(sigHash, pubKey) {
+		return sigStatusInvalid, "signature does not match the public key"
+	}
+
+	if closureErr != nil {
+		return sigStatusUnknownLeaf, "unknown leaf, key membership not checked"
+	}
+
+	return sigStatusValid, ""
+}
+
+// findTapLeafScript returns the leaf script of the input matching the given leaf hash
+func findTapLeafScript(in psbt.PInput, leafHash []byte) *psbt.TaprootTapLeafScript {
+	for _, leaf := range in.TaprootLeafScript {
+		tapHash := txscript.NewTapLeaf(leaf.LeafVersion, leaf.Script).TapHash()
+		if bytes.Equal(tapHash[:], leafHash) {
+			return leaf
+		}
+	}
+	return nil
+}
+
+// closurePubKeys returns the public keys expected to sign the given closure
+func closurePubKeys(closure script.Closure) []*btcec.PublicKey {
+	switch c := closure.(type) {
+	case *script.MultisigClosure:
+		return c.PubKeys
+	case *script.CLTVMultisigClosure:
+		return c.PubKeys
+	case *script.CSVMultisigClosure:
+		return c.PubKeys
+	case *script.ConditionMultisigClosure:
+		return c.PubKeys
+	case *script.ConditionCSVMultisigClosure:
+		return c.PubKeys
+	default:
+		return nil
+	}
+}
+
+func containsPubKey(pubKeys []*btcec.PublicKey, pubKey *btcec.PublicKey) bool {
+	xOnly := schnorr.SerializePubKey(pubKey)
+	for _, key := range pubKeys {
+		if key != nil && bytes.Equal(schnorr.SerializePubKey(key), xOnly) {
+			return true
+		}
+	}
+	return false
+}
+
+// formatSignatureChecks formats the verification results of an input's signatures
+func formatSignatureChecks(checks []signatureCheck) string {
+	var output string
+
+	if len(checks) == 0 {
+		return output
+	}
+
+	output += fmt.Sprintf("%s\n",
+		subLabelStyle.Render("  Signatures:"),
+	)
+	for j, check := range checks {
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render(fmt.Sprintf("    [%d] %s:", j, check.Path)),
+			formatSigStatus(check.Status),
+		)
+		if len(check.PubKey) > 0 {
+			output += fmt.Sprintf("%s%s\n",
+				subLabelStyle.Render("        PubKey:"),
+				valueStyle.Render(hex.EncodeToString(check.PubKey)),
+			)
+		}
+		if len(check.LeafHash) > 0 {
+			output += fmt.Sprintf("%s%s\n",
+				subLabelStyle.Render("        LeafHash:"),
+				valueStyle.Render(hex.EncodeToString(check.LeafHash)),
+			)
+		}
+		if check.Reason != "" {
+			output += fmt.Sprintf("%s%s\n",
+				subLabelStyle.Render("        Reason:"),
+				valueStyle.Render(check.Reason),
+			)
+		}
+	}
+
+	return output
+}
+
+func formatSigStatus(status string) string {
+	switch status {
+	case sigStatusValid:
+		return validStyle.Render(status)
+	case sigStatusInvalid:
+		return invalidStyle.Render(status)
+	default:
+		return warningStyle.Render(status)
+	}
+}
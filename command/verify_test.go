@@ -0,0 +1,195 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/arkade-os/arkd/pkg/ark-lib/script"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/require"
+)
+
+// newSpendingPsbt returns a psbt spending a single output locked by the given pkScript
+func newSpendingPsbt(t *testing.T, pkScript []byte) *psbt.Packet {
+	t.Helper()
+
+	prevout := &wire.TxOut{Value: 10_000, PkScript: pkScript}
+	p, err := psbt.New(
+		[]*wire.OutPoint{{Hash: chainhash.Hash{1}, Index: 0}},
+		[]*wire.TxOut{{Value: 9_000, PkScript: pkScript}},
+		2, 0, []uint32{wire.MaxTxInSequenceNum},
+	)
+	require.NoError(t, err)
+	p.Inputs[0].WitnessUtxo = prevout
+	return p
+}
+
+func newPrivKey(t *testing.T) *btcec.PrivateKey {
+	t.Helper()
+
+	key, err := btcec.NewPrivateKey()
+	require.NoError(t, err)
+	return key
+}
+
+// newScriptSpendPsbt returns a psbt spending a taproot output whose single leaf is the given script
+func newScriptSpendPsbt(t *testing.T, leafScript []byte) *psbt.Packet {
+	t.Helper()
+
+	leaf := txscript.NewBaseTapLeaf(leafScript)
+	tapTree := txscript.AssembleTaprootScriptTree(leaf)
+	rootHash := tapTree.RootNode.TapHash()
+	outputKey := txscript.ComputeTaprootOutputKey(script.UnspendableKey(), rootHash[:])
+	pkScript, err := txscript.PayToTaprootScript(outputKey)
+	require.NoError(t, err)
+
+	p := newSpendingPsbt(t, pkScript)
+	controlBlock := tapTree.LeafMerkleProofs[0].ToControlBlock(script.UnspendableKey())
+	controlBlockBytes, err := controlBlock.ToBytes()
+	require.NoError(t, err)
+	p.Inputs[0].TaprootLeafScript = []*psbt.TaprootTapLeafScript{{
+		ControlBlock: controlBlockBytes,
+		Script:       leafScript,
+		LeafVersion:  txscript.BaseLeafVersion,
+	}}
+	return p
+}
+
+// signScriptSpend adds a script-spend signature of the single leaf of the input,
+// declared with the given sighash type
+func signScriptSpend(
+	t *testing.T, p *psbt.Packet, key *btcec.PrivateKey,
+	sigHashType, declaredSigHashType txscript.SigHashType,
+) {
+	t.Helper()
+
+	in := &p.Inputs[0]
+	prevoutFetcher := txscript.NewCannedPrevOutputFetcher(in.WitnessUtxo.PkScript, in.WitnessUtxo.Value)
+	leaf := txscript.NewBaseTapLeaf(in.TaprootLeafScript[0].Script)
+	sigHash, err := txscript.CalcTapscriptSignaturehash(
+		txscript.NewTxSigHashes(p.UnsignedTx, prevoutFetcher),
+		sigHashType, p.UnsignedTx, 0, prevoutFetcher, leaf,
+	)
+	require.NoError(t, err)
+	sig, err := schnorr.Sign(key, sigHash)
+	require.NoError(t, err)
+
+	leafHash := leaf.TapHash()
+	in.TaprootScriptSpendSig = append(in.TaprootScriptSpendSig, &psbt.TaprootScriptSpendSig{
+		XOnlyPubKey: schnorr.SerializePubKey(key.PubKey()),
+		LeafHash:    leafHash[:],
+		Signature:   sig.Serialize(),
+		SigHash:     declaredSigHashType,
+	})
+}
+
+func TestVerifyKeySpendSig(t *testing.T) {
+	key := newPrivKey(t)
+	outputKey := txscript.ComputeTaprootKeyNoScript(key.PubKey())
+	pkScript, err := txscript.PayToTaprootScript(outputKey)
+	require.NoError(t, err)
+
+	p := newSpendingPsbt(t, pkScript)
+	prevoutFetcher := txscript.NewCannedPrevOutputFetcher(pkScript, p.Inputs[0].WitnessUtxo.Value)
+	sigHash, err := txscript.CalcTaprootSignatureHash(
+		txscript.NewTxSigHashes(p.UnsignedTx, prevoutFetcher),
+		txscript.SigHashDefault, p.UnsignedTx, 0, prevoutFetcher,
+	)
+	require.NoError(t, err)
+	sig, err := schnorr.Sign(txscript.TweakTaprootPrivKey(*key, nil), sigHash)
+	require.NoError(t, err)
+	p.Inputs[0].TaprootKeySpendSig = sig.Serialize()
+
+	checks := verifyInputSignatures(p, 0)
+	require.Len(t, checks, 1)
+	require.Equal(t, "key-spend", checks[0].Path)
+	require.Equal(t, sigStatusValid, checks[0].Status, checks[0].Reason)
+	require.Equal(t, schnorr.SerializePubKey(outputKey), checks[0].PubKey)
+
+	// a signature committing to SIGHASH_ALL must carry the sighash byte
+	p.Inputs[0].TaprootKeySpendSig = append(sig.Serialize(), byte(txscript.SigHashAll))
+	checks = verifyInputSignatures(p, 0)
+	require.Equal(t, sigStatusInvalid, checks[0].Status)
+
+	// all prevouts are needed to compute a taproot sighash
+	p.Inputs[0].WitnessUtxo = nil
+	checks = verifyInputSignatures(p, 0)
+	require.Equal(t, sigStatusMissingPrevout, checks[0].Status)
+}
+
+func TestVerifyScriptSpendSig(t *testing.T) {
+	alice := newPrivKey(t)
+	bob := newPrivKey(t)
+	outsider := newPrivKey(t)
+
+	closure := &script.MultisigClosure{PubKeys: []*btcec.PublicKey{alice.PubKey(), bob.PubKey()}}
+	leafScript, err := closure.Script()
+	require.NoError(t, err)
+
+	t.Run("valid", func(t *testing.T) {
+		p := newScriptSpendPsbt(t, leafScript)
+		signScriptSpend(t, p, alice, txscript.SigHashDefault, txscript.SigHashDefault)
+		signScriptSpend(t, p, bob, txscript.SigHashAll, txscript.SigHashAll)
+
+		checks := verifyInputSignatures(p, 0)
+		require.Len(t, checks, 2)
+		for _, check := range checks {
+			require.Equal(t, "script-spend", check.Path)
+			require.Equal(t, sigStatusValid, check.Status, check.Reason)
+		}
+	})
+
+	t.Run("key outside the closure", func(t *testing.T) {
+		p := newScriptSpendPsbt(t, leafScript)
+		signScriptSpend(t, p, outsider, txscript.SigHashDefault, txscript.SigHashDefault)
+
+		checks := verifyInputSignatures(p, 0)
+		require.Len(t, checks, 1)
+		require.Equal(t, sigStatusInvalid, checks[0].Status)
+		require.Equal(t, "public key is not part of the leaf closure", checks[0].Reason)
+	})
+
+	t.Run("wrong sighash type", func(t *testing.T) {
+		p := newScriptSpendPsbt(t, leafScript)
+		signScriptSpend(t, p, alice, txscript.SigHashDefault, txscript.SigHashAll)
+
+		checks := verifyInputSignatures(p, 0)
+		require.Len(t, checks, 1)
+		require.Equal(t, sigStatusInvalid, checks[0].Status)
+		require.Equal(t, "signature does not match the public key", checks[0].Reason)
+	})
+
+	t.Run("unknown leaf", func(t *testing.T) {
+		unknownScript, err := txscript.NewScriptBuilder().
+			AddOp(txscript.OP_1).
+			AddOp(txscript.OP_DROP).
+			AddData(schnorr.SerializePubKey(alice.PubKey())).
+			AddOp(txscript.OP_CHECKSIG).
+			Script()
+		require.NoError(t, err)
+		_, err = script.DecodeClosure(unknownScript)
+		require.Error(t, err)
+
+		p := newScriptSpendPsbt(t, unknownScript)
+		signScriptSpend(t, p, outsider, txscript.SigHashDefault, txscript.SigHashDefault)
+
+		checks := verifyInputSignatures(p, 0)
+		require.Len(t, checks, 1)
+		require.Equal(t, sigStatusUnknownLeaf, checks[0].Status)
+		require.Equal(t, "unknown leaf, key membership not checked", checks[0].Reason)
+	})
+
+	t.Run("leaf not in the psbt", func(t *testing.T) {
+		p := newScriptSpendPsbt(t, leafScript)
+		signScriptSpend(t, p, alice, txscript.SigHashDefault, txscript.SigHashDefault)
+		p.Inputs[0].TaprootScriptSpendSig[0].LeafHash = make([]byte, 32)
+
+		checks := verifyInputSignatures(p, 0)
+		require.Equal(t, sigStatusInvalid, checks[0].Status)
+		require.Equal(t, "leaf not found in TaprootLeafScript", checks[0].Reason)
+	})
+}
@@ -0,0 +1,221 @@
+package command
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"sort"
+
+	arklib "github.com/arkade-os/arkd/pkg/ark-lib"
+)
+
+func RunVtxo(outpointInput string, opts IndexerOptions) error {
+	outpoint, err := parseOutpoint(outpointInput)
+	if err != nil {
+		return err
+	}
+
+	client, err := newIndexerClient(opts.Server)
+	if err != nil {
+		return err
+	}
+
+	vtxos, err := client.getVtxos(url.Values{"outpoints": {outpoint.String()}})
+	if err != nil {
+		return err
+	}
+	if len(vtxos) == 0 {
+		return fmt.Errorf("vtxo %s not found", outpoint)
+	}
+	vtxo := vtxos[0]
+
+	var output string
+
+	output += fmt.Sprintf("\n%s\n",
+		sectionStyle.Render("VTXO:"),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Outpoint:"),
+		valueStyle.Render(vtxo.Outpoint.String()),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Amount:"),
+		valueStyle.Render(fmt.Sprintf("%d sats", vtxo.Amount)),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Script:"),
+		valueStyle.Render(vtxo.Script),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Status:"),
+		formatVtxoStatus(vtxo),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Created At:"),
+		valueStyle.Render(formatTimestamp(int64(vtxo.CreatedAt))),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Expires At:"),
+		valueStyle.Render(formatTimestamp(int64(vtxo.ExpiresAt))),
+	)
+
+	if vtxo.ArkTxid != "" || len(vtxo.CommitmentTxids) > 0 || vtxo.SpentBy != "" || vtxo.SettledBy != "" {
+		output += fmt.Sprintf("\n%s\n",
+			sectionStyle.Render("Transactions:"),
+		)
+	}
+	if vtxo.ArkTxid != "" {
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render("Ark Tx:"),
+			valueStyle.Render(vtxo.ArkTxid),
+		)
+	}
+	for i, txid := range vtxo.CommitmentTxids {
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render(fmt.Sprintf("Commitment Tx [%d]:", i)),
+			valueStyle.Render(txid),
+		)
+	}
+	if vtxo.SpentBy != "" {
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render("Spent By:"),
+			valueStyle.Render(vtxo.SpentBy),
+		)
+	}
+	if vtxo.SettledBy != "" {
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render("Settled By:"),
+			valueStyle.Render(vtxo.SettledBy),
+		)
+	}
+
+	fmt.Print(output)
+	return nil
+}
+
+func RunAddressHistory(addressArk string, opts IndexerOptions) error {
+	decoded, err := arklib.DecodeAddressV0(addressArk)
+	if err != nil {
+		return fmt.Errorf("failed to decode address: %w", err)
+	}
+	pkScript, err := decoded.GetPkScript()
+	if err != nil {
+		return fmt.Errorf("failed to get address script: %w", err)
+	}
+
+	client, err := newIndexerClient(opts.Server)
+	if err != nil {
+		return err
+	}
+
+	vtxos, err := client.getVtxos(url.Values{"scripts": {hex.EncodeToString(pkScript)}})
+	if err != nil {
+		return err
+	}
+	sort.SliceStable(vtxos, func(i, j int) bool {
+		return vtxos[i].CreatedAt < vtxos[j].CreatedAt
+	})
+
+	var received, spendable int64
+	for _, vtxo := range vtxos {
+		received += int64(vtxo.Amount)
+		if isVtxoSpendable(vtxo) {
+			spendable += int64(vtxo.Amount)
+		}
+	}
+
+	var output string
+
+	output += fmt.Sprintf("\n%s%s\n",
+		addressLabelStyle.Render("Address:"),
+		valueStyle.Render(addressArk),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Script:"),
+		valueStyle.Render(hex.EncodeToString(pkScript)),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Total Received:"),
+		valueStyle.Render(fmt.Sprintf("%d sats", received)),
+	)
+	output += fmt.Sprintf("%s%s\n",
+		subLabelStyle.Render("Spendable:"),
+		valueStyle.Render(fmt.Sprintf("%d sats", spendable)),
+	)
+
+	output += fmt.Sprintf("\n%s\n",
+		sectionStyle.Render(fmt.Sprintf("VTXOs (%d):", len(vtxos))),
+	)
+	for i, vtxo := range vtxos {
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render(fmt.Sprintf("[%d]:", i)),
+			valueStyle.Render(vtxo.Outpoint.String()),
+		)
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render("  Amount:"),
+			valueStyle.Render(fmt.Sprintf("%d sats", vtxo.Amount)),
+		)
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render("  Created At:"),
+			valueStyle.Render(formatTimestamp(int64(vtxo.CreatedAt))),
+		)
+		output += fmt.Sprintf("%s%s\n",
+			subLabelStyle.Render("  Status:"),
+			formatVtxoStatus(vtxo),
+		)
+	}
+
+	fmt.Print(output)
+	return nil
+}
+
+func RunTxGet(txid string, opts IndexerOptions) error {
+	client, err := newIndexerClient(opts.Server)
+	if err != nil {
+		return err
+	}
+
+	tx, err := client.getVirtualTx(txid)
+	if err != nil {
+		return err
+	}
+
+	p, err := parsePsbt(tx)
+	if err != nil {
+		return fmt.Errorf("server returned an invalid tx: %w", err)
+	}
+	if p.UnsignedTx.TxID() != txid {
+		return fmt.Errorf("server returned tx %s, expected %s", p.UnsignedTx.TxID(), txid)
+	}
+
+	fmt.Printf("\n%s\n%s\n",
+		sectionStyle.Render("PSBT:"),
+		valueStyle.Render(tx),
+	)
+	return RunPsbtDecode(tx, PsbtDecodeOptions{})
+}
+
+// isVtxoSpendable reports whether the vtxo can still be spent offchain
+func isVtxoSpendable(vtxo indexerVtxo) bool {
+	return !vtxo.IsSpent && !vtxo.IsSwept && !vtxo.IsUnrolled
+}
+
+// formatVtxoStatus formats the lifecycle status of a vtxo
+func formatVtxoStatus(vtxo indexerVtxo) string {
+	switch {
+	case vtxo.IsUnrolled:
+		return warningStyle.Render("unrolled")
+	case vtxo.IsSwept && vtxo.IsSpent:
+		return invalidStyle.Render("swept")
+	case vtxo.IsSwept:
+		return warningStyle.Render("swept, recoverable")
+	case vtxo.IsSpent && vtxo.SettledBy != "":
+		return valueStyle.Render("settled")
+	case vtxo.IsSpent:
+		return valueStyle.Render("spent")
+	case vtxo.IsPreconfirmed:
+		return validStyle.Render("spendable (preconfirmed)")
+	default:
+		return validStyle.Render("spendable")
+	}
+}
@@ -3,10 +3,55 @@ package main
 import (
 	"fmt"
 	"os"
+	"strconv"
 
 	"github.com/louisinger/noa/command"
 )
 
+const intentNewUsage = "Usage: noa intent new --vtxo <txid:vout:taptree:amount> ... [--output <address:amount> ...] [--cosigner <pubkey> ...] [--valid-for <duration>]"
+
+const arkTxBuildUsage = "Usage: noa ark-tx build --input <txid:vout,amount,taptree,leaf> ... --output <address:amount> ... --unroll-script <hex>"
+
+const treeValidateUsage = "Usage: noa tree validate --commitment <psbt> --tree <file> [--signer <pubkey>] [--vtxo <address:amount> ...]"
+
+const exitPathUsage = "Usage: noa exit path --tree <file> --vtxo <txid:vout> [--taptree <hex>] [--fee-rate <sat/vB>] [--out <dir>]"
+
+const connectorsDecodeUsage = "Usage: noa connectors decode <tree_json | psbt ...> [--forfeits <file> ...]"
+
+const musigVerifyUsage = "Usage: noa musig verify <partial_sig> --signer <pubkey> --signer-nonce <hex> (--aggnonce <hex> | --nonce <hex> ...) (--sighash <hex> | --tx <psbt> [--parent <psbt>]) [--cosigner <pubkey> ...] [--tweak <hex> | --sweep-script <hex>]"
+
+const psbtDecodeUsage = "Usage: noa psbt decode [--verify] [--confirmed-at <height|time>] [--current <height|time>] [--signer <pubkey>] [--network <name>] [--esplora <url>] [--profile <name>] <psbt_base64_or_hex>"
+
+const keyUsage = "Usage: noa key <new|show> [<wif|hex|file>] [--network <name>] [--signer <pubkey>] [--exit-delay <blocks|seconds>] [--out <file>]"
+
+const psbtSignUsage = "Usage: noa psbt sign <psbt> --key <file> [--leaf <hash>]"
+
+const psbtSetFieldUsage = "Usage: noa psbt set-field <psbt> --input <n> --field <name> --value <value> [--index <n>]"
+
+const psbtRemoveFieldUsage = "Usage: noa psbt remove-field <psbt> --input <n> --field <name> [--index <n>]"
+
+const psbtCreateUsage = "Usage: noa psbt create --input <txid:vout:amount:pkscript[:sequence]> ... --output <address:amount> ... [--taptree <input:taptree[:leaf]> ...] [--locktime <n>] [--version <n>]"
+
+const vtxoUsage = "Usage: noa vtxo <txid:vout> --server <url>"
+
+const addressHistoryUsage = "Usage: noa address history <address_ark> --server <url>"
+
+const txDecodeUsage = "Usage: noa tx decode <tx_hex> [--esplora <url>]"
+
+const txGetUsage = "Usage: noa tx get <txid> --server <url>"
+
+const addressUsage = "Usage: noa address <address_ark> [--profile <name>]"
+
+const taptreeDecodeUsage = "Usage: noa taptree decode <input> [--confirmed-at <height|time>] [--current <height|time>] [--profile <name>]"
+
+const serverInfoUsage = "Usage: noa server info [--server <url>] [--profile <name>]"
+
+const exploreUsage = "Usage: noa explore <tree_json | psbt ...> [--server <url>]"
+
+const mockUsage = "Usage: noa mock <arkd|esplora> [--listen <host:port>] [--fixtures <file>]"
+
+const arkTxVerifyUsage = "Usage: noa ark-tx verify <ark_tx> --checkpoint <psbt> ... [--signer <pubkey>] [--network <name>]"
+
 func main() {
 	if len(os.Args) < 2 {
 		printUsage()
@@ -19,11 +64,45 @@ func main() {
 	case "address":
 		if len(os.Args) < 3 {
 			fmt.Println("Error: address command requires an address_ark argument")
-			fmt.Println("Usage: noa address <address_ark>")
+			fmt.Println(addressUsage)
+			os.Exit(1)
+		}
+		if os.Args[2] == "history" {
+			var opts command.IndexerOptions
+			fs := newFlagSet("address history")
+			fs.StringVar(&opts.Server, "server", "", "arkd server url")
+			args, err := parseArgs(fs, os.Args[3:])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				fmt.Println(addressHistoryUsage)
+				os.Exit(1)
+			}
+			if len(args) < 1 || opts.Server == "" {
+				fmt.Println("Error: address history requires an address_ark argument and --server")
+				fmt.Println(addressHistoryUsage)
+				os.Exit(1)
+			}
+			if err := command.RunAddressHistory(args[0], opts); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+		var opts command.ServerCheckOptions
+		fs := newFlagSet("address")
+		fs.StringVar(&opts.Profile, "profile", "", "config profile of the server to check the address against, the default one if cached")
+		args, err := parseArgs(fs, os.Args[2:])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			fmt.Println(addressUsage)
+			os.Exit(1)
+		}
+		if len(args) < 1 {
+			fmt.Println("Error: address command requires an address_ark argument")
+			fmt.Println(addressUsage)
 			os.Exit(1)
 		}
-		arkAddress := os.Args[2]
-		if err := command.RunAddress(arkAddress); err != nil {
+		if err := command.RunAddress(args[0], opts); err != nil {
 			fmt.Printf("Error: %v\n", err)
 			os.Exit(1)
 		}
@@ -71,13 +150,24 @@ func main() {
 		subcmd := os.Args[2]
 		switch subcmd {
 		case "decode":
-			if len(os.Args) < 4 {
+			var opts command.TaptreeDecodeOptions
+			fs := newFlagSet("taptree decode")
+			fs.Int64Var(&opts.Sweep.ConfirmedAt, "confirmed-at", 0, "confirmation height (or unix time for time based sweeps) of the output")
+			fs.Int64Var(&opts.Sweep.Current, "current", 0, "current height (or unix time for time based sweeps)")
+			fs.StringVar(&opts.Server.Profile, "profile", "", "config profile of the server to check the leaves against, the default one if cached")
+			args, err := parseArgs(fs, os.Args[3:])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				fmt.Println(taptreeDecodeUsage)
+				os.Exit(1)
+			}
+			if len(args) < 1 {
 				fmt.Println("Error: taptree decode requires an input argument")
-				fmt.Println("Usage: noa taptree decode <input>")
+				fmt.Println(taptreeDecodeUsage)
 				os.Exit(1)
 			}
-			input := os.Args[3]
-			if err := command.RunTaptreeDecode(input); err != nil {
+			input := args[0]
+			if err := command.RunTaptreeDecode(input, opts); err != nil {
 				fmt.Printf("Error: %v\n", err)
 				os.Exit(1)
 			}
@@ -100,25 +190,661 @@ func main() {
 	case "psbt":
 		if len(os.Args) < 3 {
 			fmt.Println("Error: psbt command requires a subcommand")
-			fmt.Println("Usage: noa psbt decode <psbt_base64_or_hex>")
+			fmt.Println("Usage: noa psbt <decode|create|sign|finalize|extract|combine|diff|set-field|remove-field> [arguments]")
 			os.Exit(1)
 		}
 		subcmd := os.Args[2]
 		switch subcmd {
 		case "decode":
-			if len(os.Args) < 4 {
+			fs := newFlagSet("psbt decode")
+			var opts command.PsbtDecodeOptions
+			fs.BoolVar(&opts.Verify, "verify", false, "verify taproot signatures")
+			fs.Int64Var(&opts.Sweep.ConfirmedAt, "confirmed-at", 0, "confirmation height (or unix time for time based sweeps) of the spent outputs")
+			fs.Int64Var(&opts.Sweep.Current, "current", 0, "current height (or unix time for time based sweeps)")
+			fs.StringVar(&opts.Addresses.Signer, "signer", "", "server public key, to display the ark addresses")
+			fs.StringVar(&opts.Addresses.Network, "network", "", "network of the addresses (default bitcoin)")
+			fs.StringVar(&opts.Esplora.URL, "esplora", "", "esplora api url, to fetch the missing prevouts and the onchain status")
+			fs.StringVar(&opts.Server.Profile, "profile", "", "config profile of the server to check the input taptrees against, the default one if cached")
+			args, err := parseArgs(fs, os.Args[3:])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				fmt.Println(psbtDecodeUsage)
+				os.Exit(1)
+			}
+			if len(args) < 1 {
 				fmt.Println("Error: psbt decode requires a psbt_base64_or_hex argument")
-				fmt.Println("Usage: noa psbt decode <psbt_base64_or_hex>")
+				fmt.Println(psbtDecodeUsage)
 				os.Exit(1)
 			}
-			psbtInput := os.Args[3]
-			if err := command.RunPsbtDecode(psbtInput); err != nil {
+			if err := command.RunPsbtDecode(args[0], opts); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+		case "create":
+			var opts command.PsbtCreateOptions
+			var lockTime uint
+			var version int
+			fs := newFlagSet("psbt create")
+			fs.Var((*stringList)(&opts.Inputs), "input", "input to spend, as txid:vout:amount:pkscript[:sequence]")
+			fs.Var((*stringList)(&opts.Outputs), "output", "output to create, as address:amount")
+			fs.Var((*stringList)(&opts.Taptrees), "taptree", "taptree of an input, as index:taptree[:leaf]")
+			fs.UintVar(&lockTime, "locktime", 0, "transaction locktime")
+			fs.IntVar(&version, "version", 3, "transaction version")
+			args, err := parseArgs(fs, os.Args[3:])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				fmt.Println(psbtCreateUsage)
+				os.Exit(1)
+			}
+			if len(args) > 0 || len(opts.Inputs) == 0 || len(opts.Outputs) == 0 {
+				fmt.Println("Error: psbt create requires at least one --input and one --output")
+				fmt.Println(psbtCreateUsage)
+				os.Exit(1)
+			}
+			opts.LockTime = uint32(lockTime)
+			opts.Version = int32(version)
+			if err := command.RunPsbtCreate(opts); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+		case "sign":
+			var opts command.PsbtSignOptions
+			fs := newFlagSet("psbt sign")
+			fs.StringVar(&opts.Key, "key", "", "private key file (WIF or hex)")
+			fs.StringVar(&opts.Leaf, "leaf", "", "hash of the tapscript leaf to sign")
+			args, err := parseArgs(fs, os.Args[3:])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				fmt.Println(psbtSignUsage)
+				os.Exit(1)
+			}
+			if len(args) < 1 || opts.Key == "" {
+				fmt.Println("Error: psbt sign requires a psbt argument and --key")
+				fmt.Println(psbtSignUsage)
+				os.Exit(1)
+			}
+			if err := command.RunPsbtSign(args[0], opts); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+		case "finalize":
+			if len(os.Args) < 4 {
+				fmt.Println("Error: psbt finalize requires a psbt argument")
+				fmt.Println("Usage: noa psbt finalize <psbt>")
+				os.Exit(1)
+			}
+			if err := command.RunPsbtFinalize(os.Args[3]); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+		case "extract":
+			if len(os.Args) < 4 {
+				fmt.Println("Error: psbt extract requires a psbt argument")
+				fmt.Println("Usage: noa psbt extract <psbt>")
+				os.Exit(1)
+			}
+			if err := command.RunPsbtExtract(os.Args[3]); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+		case "combine":
+			if len(os.Args) < 5 {
+				fmt.Println("Error: psbt combine requires at least two psbts")
+				fmt.Println("Usage: noa psbt combine <psbt> <psbt> [psbt ...]")
+				os.Exit(1)
+			}
+			if err := command.RunPsbtCombine(os.Args[3:]); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+		case "diff":
+			if len(os.Args) < 5 {
+				fmt.Println("Error: psbt diff requires two psbts")
+				fmt.Println("Usage: noa psbt diff <a> <b>")
+				os.Exit(1)
+			}
+			if err := command.RunPsbtDiff(os.Args[3], os.Args[4]); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+		case "set-field", "remove-field":
+			usage := psbtSetFieldUsage
+			if subcmd == "remove-field" {
+				usage = psbtRemoveFieldUsage
+			}
+			var opts command.PsbtFieldOptions
+			fs := newFlagSet("psbt " + subcmd)
+			fs.IntVar(&opts.Input, "input", -1, "index of the input to edit")
+			fs.StringVar(&opts.Field, "field", "", "ARK field: ConditionWitness, CosignerPublicKey, VtxoTaprootTree or VtxoTreeExpiry")
+			fs.IntVar(&opts.Index, "index", -1, "cosigner index")
+			if subcmd == "set-field" {
+				fs.StringVar(&opts.Value, "value", "", "field value")
+			}
+			args, err := parseArgs(fs, os.Args[3:])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				fmt.Println(usage)
+				os.Exit(1)
+			}
+			if len(args) < 1 || opts.Input < 0 || opts.Field == "" {
+				fmt.Printf("Error: psbt %s requires a psbt argument, --input and --field\n", subcmd)
+				fmt.Println(usage)
+				os.Exit(1)
+			}
+			run := command.RunPsbtSetField
+			if subcmd == "remove-field" {
+				run = command.RunPsbtRemoveField
+			}
+			if err := run(args[0], opts); err != nil {
 				fmt.Printf("Error: %v\n", err)
 				os.Exit(1)
 			}
 		default:
 			fmt.Printf("Unknown psbt subcommand: %s\n", subcmd)
-			fmt.Println("Usage: noa psbt decode <psbt_base64_or_hex>")
+			fmt.Println("Usage: noa psbt <decode|create|sign|finalize|extract|combine|diff|set-field|remove-field> [arguments]")
+			os.Exit(1)
+		}
+	case "intent":
+		if len(os.Args) < 3 {
+			fmt.Println("Error: intent command requires a subcommand")
+			fmt.Println("Usage: noa intent <decode|new> [arguments]")
+			os.Exit(1)
+		}
+		subcmd := os.Args[2]
+		switch subcmd {
+		case "decode":
+			fs := newFlagSet("intent decode")
+			message := fs.String("message", "", "intent message (json or file)")
+			args, err := parseArgs(fs, os.Args[3:])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				fmt.Println("Usage: noa intent decode <proof> [--message <json>]")
+				os.Exit(1)
+			}
+			if len(args) < 1 {
+				fmt.Println("Error: intent decode requires a proof argument")
+				fmt.Println("Usage: noa intent decode <proof> [--message <json>]")
+				os.Exit(1)
+			}
+			if err := command.RunIntentDecode(args[0], *message); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+		case "new":
+			var opts command.IntentNewOptions
+			fs := newFlagSet("intent new")
+			fs.Var((*stringList)(&opts.Vtxos), "vtxo", "vtxo to prove, as txid:vout:taptree:amount")
+			fs.Var((*stringList)(&opts.Outputs), "output", "output to register, as address:amount")
+			fs.Var((*stringList)(&opts.Cosigners), "cosigner", "cosigner public key, 33 bytes compressed")
+			fs.DurationVar(&opts.ValidFor, "valid-for", 0, "validity duration of the intent")
+			if _, err := parseArgs(fs, os.Args[3:]); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				fmt.Println(intentNewUsage)
+				os.Exit(1)
+			}
+			if len(opts.Vtxos) == 0 {
+				fmt.Println("Error: intent new requires at least one --vtxo")
+				fmt.Println(intentNewUsage)
+				os.Exit(1)
+			}
+			if err := command.RunIntentNew(opts); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+		default:
+			fmt.Printf("Unknown intent subcommand: %s\n", subcmd)
+			fmt.Println("Usage: noa intent <decode|new> [arguments]")
+			os.Exit(1)
+		}
+	case "ark-tx":
+		if len(os.Args) < 3 {
+			fmt.Println("Error: ark-tx command requires a subcommand")
+			fmt.Println("Usage: noa ark-tx <build|verify> [arguments]")
+			os.Exit(1)
+		}
+		subcmd := os.Args[2]
+		switch subcmd {
+		case "build":
+			var opts command.ArkTxBuildOptions
+			fs := newFlagSet("ark-tx build")
+			fs.Var((*stringList)(&opts.Inputs), "input", "vtxo to spend, as txid:vout,amount,taptree,leaf")
+			fs.Var((*stringList)(&opts.Outputs), "output", "output to create, as address:amount")
+			fs.StringVar(&opts.UnrollScript, "unroll-script", "", "server unroll closure locking the checkpoint outputs")
+			if _, err := parseArgs(fs, os.Args[3:]); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				fmt.Println(arkTxBuildUsage)
+				os.Exit(1)
+			}
+			if len(opts.Inputs) == 0 || len(opts.Outputs) == 0 || opts.UnrollScript == "" {
+				fmt.Println("Error: ark-tx build requires --input, --output and --unroll-script")
+				fmt.Println(arkTxBuildUsage)
+				os.Exit(1)
+			}
+			if err := command.RunArkTxBuild(opts); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+		case "verify":
+			var opts command.ArkTxVerifyOptions
+			fs := newFlagSet("ark-tx verify")
+			fs.Var((*stringList)(&opts.Checkpoints), "checkpoint", "checkpoint transaction spent by the ark tx")
+			fs.StringVar(&opts.Signer, "signer", "", "server public key")
+			fs.StringVar(&opts.Network, "network", "bitcoin", "ark network of the new vtxo addresses")
+			args, err := parseArgs(fs, os.Args[3:])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				fmt.Println(arkTxVerifyUsage)
+				os.Exit(1)
+			}
+			if len(args) < 1 {
+				fmt.Println("Error: ark-tx verify requires an ark transaction argument")
+				fmt.Println(arkTxVerifyUsage)
+				os.Exit(1)
+			}
+			if err := command.RunArkTxVerify(args[0], opts); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+		default:
+			fmt.Printf("Unknown ark-tx subcommand: %s\n", subcmd)
+			fmt.Println("Usage: noa ark-tx <build|verify> [arguments]")
+			os.Exit(1)
+		}
+	case "tree":
+		if len(os.Args) < 3 {
+			fmt.Println("Error: tree command requires a subcommand")
+			fmt.Println("Usage: noa tree <decode|validate> [arguments]")
+			os.Exit(1)
+		}
+		subcmd := os.Args[2]
+		switch subcmd {
+		case "decode":
+			if len(os.Args) < 4 {
+				fmt.Println("Error: tree decode requires at least one argument")
+				fmt.Println("Usage: noa tree decode <tree_json | psbt ...>")
+				os.Exit(1)
+			}
+			if err := command.RunTreeDecode(os.Args[3:]); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+		case "validate":
+			var opts command.TreeValidateOptions
+			fs := newFlagSet("tree validate")
+			fs.StringVar(&opts.Commitment, "commitment", "", "commitment transaction")
+			fs.Var((*stringList)(&opts.Tree), "tree", "vtxo tree json or psbts")
+			fs.StringVar(&opts.Signer, "signer", "", "server public key")
+			fs.Var((*stringList)(&opts.Vtxos), "vtxo", "expected leaf output, as address:amount")
+			if _, err := parseArgs(fs, os.Args[3:]); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				fmt.Println(treeValidateUsage)
+				os.Exit(1)
+			}
+			if opts.Commitment == "" || len(opts.Tree) == 0 {
+				fmt.Println("Error: tree validate requires --commitment and --tree")
+				fmt.Println(treeValidateUsage)
+				os.Exit(1)
+			}
+			if err := command.RunTreeValidate(opts); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+		default:
+			fmt.Printf("Unknown tree subcommand: %s\n", subcmd)
+			fmt.Println("Usage: noa tree <decode|validate> [arguments]")
+			os.Exit(1)
+		}
+	case "exit":
+		if len(os.Args) < 3 {
+			fmt.Println("Error: exit command requires a subcommand")
+			fmt.Println("Usage: noa exit <path> [arguments]")
+			os.Exit(1)
+		}
+		subcmd := os.Args[2]
+		switch subcmd {
+		case "path":
+			var opts command.ExitPathOptions
+			fs := newFlagSet("exit path")
+			fs.Var((*stringList)(&opts.Tree), "tree", "vtxo tree json or psbts")
+			fs.StringVar(&opts.Vtxo, "vtxo", "", "vtxo outpoint, as txid:vout")
+			fs.StringVar(&opts.Taptree, "taptree", "", "vtxo taptree, to compute the exit delay")
+			fs.Float64Var(&opts.FeeRate, "fee-rate", 1, "fee rate in sat/vB of the CPFP packages")
+			fs.StringVar(&opts.OutDir, "out", "", "directory to export the exit transactions to")
+			if _, err := parseArgs(fs, os.Args[3:]); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				fmt.Println(exitPathUsage)
+				os.Exit(1)
+			}
+			if len(opts.Tree) == 0 || opts.Vtxo == "" {
+				fmt.Println("Error: exit path requires --tree and --vtxo")
+				fmt.Println(exitPathUsage)
+				os.Exit(1)
+			}
+			if err := command.RunExitPath(opts); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+		default:
+			fmt.Printf("Unknown exit subcommand: %s\n", subcmd)
+			fmt.Println("Usage: noa exit <path> [arguments]")
+			os.Exit(1)
+		}
+	case "connectors":
+		if len(os.Args) < 3 {
+			fmt.Println("Error: connectors command requires a subcommand")
+			fmt.Println("Usage: noa connectors <decode> [arguments]")
+			os.Exit(1)
+		}
+		subcmd := os.Args[2]
+		switch subcmd {
+		case "decode":
+			var opts command.ConnectorsDecodeOptions
+			fs := newFlagSet("connectors decode")
+			fs.Var((*stringList)(&opts.Forfeits), "forfeits", "forfeit transactions to match with the connectors")
+			args, err := parseArgs(fs, os.Args[3:])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				fmt.Println(connectorsDecodeUsage)
+				os.Exit(1)
+			}
+			if len(args) < 1 {
+				fmt.Println("Error: connectors decode requires at least one argument")
+				fmt.Println(connectorsDecodeUsage)
+				os.Exit(1)
+			}
+			if err := command.RunConnectorsDecode(args, opts); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+		default:
+			fmt.Printf("Unknown connectors subcommand: %s\n", subcmd)
+			fmt.Println("Usage: noa connectors <decode> [arguments]")
+			os.Exit(1)
+		}
+	case "musig":
+		if len(os.Args) < 3 {
+			fmt.Println("Error: musig command requires a subcommand")
+			fmt.Println("Usage: noa musig <aggregate|nonces|partial-sigs|verify> [arguments]")
+			os.Exit(1)
+		}
+		subcmd := os.Args[2]
+		switch subcmd {
+		case "aggregate":
+			var opts command.MusigTweakOptions
+			fs := newFlagSet("musig aggregate")
+			fs.StringVar(&opts.Tweak, "tweak", "", "taproot merkle root to tweak the aggregated key with")
+			fs.StringVar(&opts.SweepScript, "sweep-script", "", "sweep leaf of a tree node, used as the only taproot leaf")
+			args, err := parseArgs(fs, os.Args[3:])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				fmt.Println("Usage: noa musig aggregate <pubkey> ... [--tweak <hex> | --sweep-script <hex>]")
+				os.Exit(1)
+			}
+			if len(args) < 1 {
+				fmt.Println("Error: musig aggregate requires at least one public key")
+				fmt.Println("Usage: noa musig aggregate <pubkey> ... [--tweak <hex> | --sweep-script <hex>]")
+				os.Exit(1)
+			}
+			if err := command.RunMusigAggregate(args, opts); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+		case "nonces", "partial-sigs":
+			var treeInputs []string
+			fs := newFlagSet("musig " + subcmd)
+			fs.Var((*stringList)(&treeInputs), "tree", "tree the session signs, to check every tx is covered")
+			args, err := parseArgs(fs, os.Args[3:])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				fmt.Printf("Usage: noa musig %s <json> [--tree <file>]\n", subcmd)
+				os.Exit(1)
+			}
+			if len(args) < 1 {
+				fmt.Printf("Error: musig %s requires a json argument\n", subcmd)
+				fmt.Printf("Usage: noa musig %s <json> [--tree <file>]\n", subcmd)
+				os.Exit(1)
+			}
+			run := command.RunMusigNonces
+			if subcmd == "partial-sigs" {
+				run = command.RunMusigPartialSigs
+			}
+			if err := run(args[0], treeInputs); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+		case "verify":
+			var opts command.MusigVerifyOptions
+			fs := newFlagSet("musig verify")
+			fs.StringVar(&opts.Signer, "signer", "", "public key of the partial signature signer")
+			fs.StringVar(&opts.SignerNonce, "signer-nonce", "", "public nonce of the signer")
+			fs.StringVar(&opts.AggNonce, "aggnonce", "", "aggregated nonce")
+			fs.Var((*stringList)(&opts.Nonces), "nonce", "public nonce of a cosigner, aggregated if --aggnonce is omitted")
+			fs.Var((*stringList)(&opts.Cosigners), "cosigner", "cosigner public key")
+			fs.StringVar(&opts.Sighash, "sighash", "", "signed message")
+			fs.StringVar(&opts.Tx, "tx", "", "tree tx whose first input key path sighash is signed")
+			fs.StringVar(&opts.Parent, "parent", "", "parent tx of --tx, providing its prevout")
+			fs.StringVar(&opts.Tweak, "tweak", "", "taproot merkle root")
+			fs.StringVar(&opts.SweepScript, "sweep-script", "", "sweep leaf of the tree node")
+			args, err := parseArgs(fs, os.Args[3:])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				fmt.Println(musigVerifyUsage)
+				os.Exit(1)
+			}
+			if len(args) < 1 || opts.Signer == "" || opts.SignerNonce == "" {
+				fmt.Println("Error: musig verify requires a partial signature, --signer and --signer-nonce")
+				fmt.Println(musigVerifyUsage)
+				os.Exit(1)
+			}
+			if err := command.RunMusigVerify(args[0], opts); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+		default:
+			fmt.Printf("Unknown musig subcommand: %s\n", subcmd)
+			fmt.Println("Usage: noa musig <aggregate|nonces|partial-sigs|verify> [arguments]")
+			os.Exit(1)
+		}
+	case "vtxo":
+		var opts command.IndexerOptions
+		fs := newFlagSet("vtxo")
+		fs.StringVar(&opts.Server, "server", "", "arkd server url")
+		args, err := parseArgs(fs, os.Args[2:])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			fmt.Println(vtxoUsage)
+			os.Exit(1)
+		}
+		if len(args) < 1 || opts.Server == "" {
+			fmt.Println("Error: vtxo requires an outpoint argument and --server")
+			fmt.Println(vtxoUsage)
+			os.Exit(1)
+		}
+		if err := command.RunVtxo(args[0], opts); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	case "server":
+		if len(os.Args) < 3 {
+			fmt.Println("Error: server command requires a subcommand")
+			fmt.Println(serverInfoUsage)
+			os.Exit(1)
+		}
+		subcmd := os.Args[2]
+		switch subcmd {
+		case "info":
+			var opts command.ServerInfoOptions
+			fs := newFlagSet("server info")
+			fs.StringVar(&opts.Server, "server", "", "arkd server url, the cached one of the profile if omitted")
+			fs.StringVar(&opts.Profile, "profile", command.DefaultProfile, "config profile to cache the server info in")
+			if _, err := parseArgs(fs, os.Args[3:]); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				fmt.Println(serverInfoUsage)
+				os.Exit(1)
+			}
+			if err := command.RunServerInfo(opts); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+		default:
+			fmt.Printf("Unknown server subcommand: %s\n", subcmd)
+			fmt.Println(serverInfoUsage)
+			os.Exit(1)
+		}
+	case "explore":
+		var opts command.ExploreOptions
+		fs := newFlagSet("explore")
+		fs.StringVar(&opts.Server, "server", "", "arkd server url, to fetch the parent and child txs missing from the input")
+		args, err := parseArgs(fs, os.Args[2:])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			fmt.Println(exploreUsage)
+			os.Exit(1)
+		}
+		if len(args) < 1 {
+			fmt.Println("Error: explore requires a tree or psbt argument")
+			fmt.Println(exploreUsage)
+			os.Exit(1)
+		}
+		if err := command.RunExplore(args, opts); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	case "mock":
+		if len(os.Args) < 3 {
+			fmt.Println("Error: mock command requires a server name")
+			fmt.Println(mockUsage)
+			os.Exit(1)
+		}
+		subcmd := os.Args[2]
+		switch subcmd {
+		case "arkd", "esplora":
+			var opts command.MockOptions
+			fs := newFlagSet("mock " + subcmd)
+			if subcmd == "arkd" {
+				fs.StringVar(&opts.Listen, "listen", "127.0.0.1:7070", "address to listen on")
+				fs.StringVar(&opts.Fixtures, "fixtures", "", "json file of the server info, vtxos and virtual txs to serve")
+			} else {
+				fs.StringVar(&opts.Listen, "listen", "127.0.0.1:3000", "address to listen on")
+				fs.StringVar(&opts.Fixtures, "fixtures", "", "json file of the blocks and txs to serve")
+			}
+			if _, err := parseArgs(fs, os.Args[3:]); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				fmt.Println(mockUsage)
+				os.Exit(1)
+			}
+			run := command.RunMockArkd
+			if subcmd == "esplora" {
+				run = command.RunMockEsplora
+			}
+			if err := run(opts); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+		default:
+			fmt.Printf("Unknown mock server: %s\n", subcmd)
+			fmt.Println(mockUsage)
+			os.Exit(1)
+		}
+	case "tx":
+		if len(os.Args) < 3 {
+			fmt.Println("Error: tx command requires a subcommand")
+			fmt.Println("Usage: noa tx <decode|get> [arguments]")
+			os.Exit(1)
+		}
+		subcmd := os.Args[2]
+		switch subcmd {
+		case "decode":
+			var opts command.TxDecodeOptions
+			fs := newFlagSet("tx decode")
+			fs.StringVar(&opts.Esplora.URL, "esplora", "", "esplora api url, to fetch the prevouts and the onchain status")
+			args, err := parseArgs(fs, os.Args[3:])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				fmt.Println(txDecodeUsage)
+				os.Exit(1)
+			}
+			if len(args) < 1 {
+				fmt.Println("Error: tx decode requires a tx_hex argument")
+				fmt.Println(txDecodeUsage)
+				os.Exit(1)
+			}
+			if err := command.RunTxDecode(args[0], opts); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+		case "get":
+			var opts command.IndexerOptions
+			fs := newFlagSet("tx get")
+			fs.StringVar(&opts.Server, "server", "", "arkd server url")
+			args, err := parseArgs(fs, os.Args[3:])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				fmt.Println(txGetUsage)
+				os.Exit(1)
+			}
+			if len(args) < 1 || opts.Server == "" {
+				fmt.Println("Error: tx get requires a txid argument and --server")
+				fmt.Println(txGetUsage)
+				os.Exit(1)
+			}
+			if err := command.RunTxGet(args[0], opts); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+		default:
+			fmt.Printf("Unknown tx subcommand: %s\n", subcmd)
+			fmt.Println("Usage: noa tx <decode|get> [arguments]")
+			os.Exit(1)
+		}
+	case "key":
+		if len(os.Args) < 3 {
+			fmt.Println("Error: key command requires a subcommand")
+			fmt.Println(keyUsage)
+			os.Exit(1)
+		}
+		subcmd := os.Args[2]
+		var opts command.KeyOptions
+		fs := newFlagSet("key " + subcmd)
+		fs.StringVar(&opts.Network, "network", "bitcoin", "network of the addresses")
+		fs.StringVar(&opts.Signer, "signer", "", "server public key, to derive the ark address")
+		fs.Func("exit-delay", "exit delay of the ark address, in blocks below 512 and seconds otherwise (a multiple of 512)", func(value string) error {
+			delay, err := strconv.ParseUint(value, 10, 32)
+			opts.ExitDelay = uint32(delay)
+			return err
+		})
+		opts.ExitDelay = command.DefaultExitDelay
+		switch subcmd {
+		case "new":
+			fs.StringVar(&opts.Out, "out", "", "file to save the private key to, as WIF")
+			if _, err := parseArgs(fs, os.Args[3:]); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				fmt.Println(keyUsage)
+				os.Exit(1)
+			}
+			if err := command.RunKeyNew(opts); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+		case "show":
+			args, err := parseArgs(fs, os.Args[3:])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				fmt.Println(keyUsage)
+				os.Exit(1)
+			}
+			if len(args) < 1 {
+				fmt.Println("Error: key show requires a private key argument")
+				fmt.Println(keyUsage)
+				os.Exit(1)
+			}
+			if err := command.RunKeyShow(args[0], opts); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+		default:
+			fmt.Printf("Unknown key subcommand: %s\n", subcmd)
+			fmt.Println(keyUsage)
 			os.Exit(1)
 		}
 	default:
@@ -131,10 +857,40 @@ func main() {
 func printUsage() {
 	fmt.Println("Usage: noa <command> [arguments]")
 	fmt.Println("\nAvailable commands:")
-	fmt.Println("  address <address_ark>")
+	fmt.Println("  address <address_ark> [--profile <name>]")
+	fmt.Println("  address history <address_ark> --server <url>")
 	fmt.Println("  script <script_hex>")
 	fmt.Println("  note fromTxid <txid_string>")
-	fmt.Println("  taptree decode <input>")
+	fmt.Println("  taptree decode <input> [--confirmed-at <height|time>] [--current <height|time>] [--profile <name>]")
 	fmt.Println("  taptree encode <input1> [input2] ...")
-	fmt.Println("  psbt decode <psbt_base64_or_hex>")
+	fmt.Println("  psbt decode [--verify] [--confirmed-at <height|time>] [--current <height|time>] [--signer <pubkey>] [--network <name>] [--esplora <url>] [--profile <name>] <psbt_base64_or_hex>")
+	fmt.Println("  psbt create --input <txid:vout:amount:pkscript[:sequence]> ... --output <address:amount> ... [--taptree <input:taptree[:leaf]> ...] [--locktime <n>] [--version <n>]")
+	fmt.Println("  psbt sign <psbt> --key <file> [--leaf <hash>]")
+	fmt.Println("  psbt finalize <psbt>")
+	fmt.Println("  psbt extract <psbt>")
+	fmt.Println("  psbt combine <psbt> <psbt> [psbt ...]")
+	fmt.Println("  psbt diff <a> <b>")
+	fmt.Println("  psbt set-field <psbt> --input <n> --field <name> --value <value> [--index <n>]")
+	fmt.Println("  psbt remove-field <psbt> --input <n> --field <name> [--index <n>]")
+	fmt.Println("  tx decode <tx_hex> [--esplora <url>]")
+	fmt.Println("  tx get <txid> --server <url>")
+	fmt.Println("  vtxo <txid:vout> --server <url>")
+	fmt.Println("  server info [--server <url>] [--profile <name>]")
+	fmt.Println("  intent decode <proof> [--message <json>]")
+	fmt.Println("  intent new --vtxo <txid:vout:taptree:amount> ... [--output <address:amount> ...] [--cosigner <pubkey> ...] [--valid-for <duration>]")
+	fmt.Println("  ark-tx build --input <txid:vout,amount,taptree,leaf> ... --output <address:amount> ... --unroll-script <hex>")
+	fmt.Println("  ark-tx verify <ark_tx> --checkpoint <psbt> ... [--signer <pubkey>] [--network <name>]")
+	fmt.Println("  tree decode <tree_json | psbt ...>")
+	fmt.Println("  tree validate --commitment <psbt> --tree <file> [--signer <pubkey>] [--vtxo <address:amount> ...]")
+	fmt.Println("  exit path --tree <file> --vtxo <txid:vout> [--taptree <hex>] [--fee-rate <sat/vB>] [--out <dir>]")
+	fmt.Println("  connectors decode <tree_json | psbt ...> [--forfeits <file> ...]")
+	fmt.Println("  musig aggregate <pubkey> ... [--tweak <hex> | --sweep-script <hex>]")
+	fmt.Println("  musig nonces <json> [--tree <file>]")
+	fmt.Println("  musig partial-sigs <json> [--tree <file>]")
+	fmt.Println("  musig verify <partial_sig> --signer <pubkey> --signer-nonce <hex> (--aggnonce <hex> | --nonce <hex> ...) (--sighash <hex> | --tx <psbt> [--parent <psbt>]) [--cosigner <pubkey> ...] [--tweak <hex> | --sweep-script <hex>]")
+	fmt.Println("  key new [--network <name>] [--signer <pubkey>] [--exit-delay <blocks|seconds>] [--out <file>]")
+	fmt.Println("  key show <wif|hex|file> [--network <name>] [--signer <pubkey>] [--exit-delay <blocks|seconds>]")
+	fmt.Println("  explore <tree_json | psbt ...> [--server <url>]")
+	fmt.Println("  mock arkd [--listen <host:port>] [--fixtures <file>]")
+	fmt.Println("  mock esplora [--listen <host:port>] [--fixtures <file>]")
 }